@@ -24,6 +24,7 @@ type (
 	// Condition types
 	BackstageConditionType   = bsv1.BackstageConditionType
 	BackstageConditionReason = bsv1.BackstageConditionReason
+	PluginDependencyState    = bsv1.PluginDependencyState
 
 	// Spec components
 	Flavour             = bsv1.Flavour
@@ -36,12 +37,29 @@ type (
 	RuntimeConfig       = bsv1.RuntimeConfig
 	BackstageDeployment = bsv1.BackstageDeployment
 	Monitoring          = bsv1.Monitoring
+	DynamicPlugins      = bsv1.DynamicPlugins
+	PluginMirror        = bsv1.PluginMirror
+	PluginRegistryAuth  = bsv1.PluginRegistryAuth
+	PluginCache         = bsv1.PluginCache
+	CatalogIndex        = bsv1.CatalogIndex
+	NamedCatalogIndex   = bsv1.NamedCatalogIndex
 
 	// Reference types
-	EnvObjectRef  = bsv1.EnvObjectRef
-	FileObjectRef = bsv1.FileObjectRef
-	PvcRef        = bsv1.PvcRef
-	Env           = bsv1.Env
+	EnvObjectRef   = bsv1.EnvObjectRef
+	EnvObjectItem  = bsv1.EnvObjectItem
+	FileObjectRef  = bsv1.FileObjectRef
+	FileObjectItem = bsv1.FileObjectItem
+	PvcRef         = bsv1.PvcRef
+	Env            = bsv1.Env
+	ReloadStrategy = bsv1.ReloadStrategy
+
+	// Status components
+	DynamicPluginsStatus   = bsv1.DynamicPluginsStatus
+	PluginPackageStatus    = bsv1.PluginPackageStatus
+	PluginDependencyStatus = bsv1.PluginDependencyStatus
+	PluginDependencyObject = bsv1.PluginDependencyObject
+	CatalogIndexStatus     = bsv1.CatalogIndexStatus
+	FlavourStatus          = bsv1.FlavourStatus
 
 	// Other types
 	TLS = bsv1.TLS
@@ -49,11 +67,27 @@ type (
 
 // Condition constants
 const (
-	BackstageConditionTypeDeployed     BackstageConditionType   = bsv1.BackstageConditionTypeDeployed
-	BackstageConditionReasonDeployed   BackstageConditionReason = bsv1.BackstageConditionReasonDeployed
-	BackstageConditionReasonFailed     BackstageConditionReason = bsv1.BackstageConditionReasonFailed
-	BackstageConditionReasonInProgress BackstageConditionReason = bsv1.BackstageConditionReasonInProgress
-	BackstageConditionReasonIdled      BackstageConditionReason = bsv1.BackstageConditionReasonIdled
+	BackstageConditionTypeDeployed            BackstageConditionType   = bsv1.BackstageConditionTypeDeployed
+	BackstageConditionTypeInfrastructureReady BackstageConditionType   = bsv1.BackstageConditionTypeInfrastructureReady
+	BackstageConditionTypeConfigValid         BackstageConditionType   = bsv1.BackstageConditionTypeConfigValid
+	BackstageConditionReasonDeployed          BackstageConditionReason = bsv1.BackstageConditionReasonDeployed
+	BackstageConditionReasonFailed            BackstageConditionReason = bsv1.BackstageConditionReasonFailed
+	BackstageConditionReasonInProgress        BackstageConditionReason = bsv1.BackstageConditionReasonInProgress
+	BackstageConditionReasonIdled             BackstageConditionReason = bsv1.BackstageConditionReasonIdled
+
+	BackstageConditionReasonInfrastructureReady    BackstageConditionReason = bsv1.BackstageConditionReasonInfrastructureReady
+	BackstageConditionReasonInfrastructureNotReady BackstageConditionReason = bsv1.BackstageConditionReasonInfrastructureNotReady
+
+	BackstageConditionReasonConfigValid   BackstageConditionReason = bsv1.BackstageConditionReasonConfigValid
+	BackstageConditionReasonConfigInvalid BackstageConditionReason = bsv1.BackstageConditionReasonConfigInvalid
+
+	PluginDependencyStateApplied PluginDependencyState = bsv1.PluginDependencyStateApplied
+	PluginDependencyStateReady   PluginDependencyState = bsv1.PluginDependencyStateReady
+	PluginDependencyStatePending PluginDependencyState = bsv1.PluginDependencyStatePending
+	PluginDependencyStateError   PluginDependencyState = bsv1.PluginDependencyStateError
+
+	ReloadStrategyRestart ReloadStrategy = bsv1.ReloadStrategyRestart
+	ReloadStrategyLive    ReloadStrategy = bsv1.ReloadStrategyLive
 )
 
 // AddToScheme adds the current API version's types to the scheme.
@@ -1,7 +1,9 @@
 package v1alpha5
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/ptr"
 )
@@ -11,12 +13,33 @@ type BackstageConditionReason string
 type BackstageConditionType string
 
 const (
-	BackstageConditionTypeDeployed BackstageConditionType = "Deployed"
+	BackstageConditionTypeDeployed            BackstageConditionType = "Deployed"
+	BackstageConditionTypeInfrastructureReady BackstageConditionType = "InfrastructureReady"
+	BackstageConditionTypeConfigValid         BackstageConditionType = "ConfigValid"
 
 	BackstageConditionReasonDeployed   BackstageConditionReason = "Deployed"
 	BackstageConditionReasonFailed     BackstageConditionReason = "DeployFailed"
 	BackstageConditionReasonInProgress BackstageConditionReason = "DeployInProgress"
 	BackstageConditionReasonIdled      BackstageConditionReason = "Idled"
+
+	BackstageConditionReasonInfrastructureReady    BackstageConditionReason = "InfrastructureReady"
+	BackstageConditionReasonInfrastructureNotReady BackstageConditionReason = "InfrastructureNotReady"
+
+	BackstageConditionReasonConfigValid   BackstageConditionReason = "ConfigValid"
+	BackstageConditionReasonConfigInvalid BackstageConditionReason = "ConfigInvalid"
+)
+
+type PluginDependencyState string
+
+const (
+	// PluginDependencyStateApplied means the dependency objects are applied, but not all of them are ready yet
+	PluginDependencyStateApplied PluginDependencyState = "Applied"
+	// PluginDependencyStateReady means the dependency objects are applied and ready
+	PluginDependencyStateReady PluginDependencyState = "Ready"
+	// PluginDependencyStatePending means the dependency waits for the CRDs it requires to be established
+	PluginDependencyStatePending PluginDependencyState = "Pending"
+	// PluginDependencyStateError means the dependency failed to apply
+	PluginDependencyStateError PluginDependencyState = "Error"
 )
 
 // BackstageSpec defines the desired state of Backstage
@@ -52,6 +75,13 @@ type BackstageSpec struct {
 	// Multiple flavours can be enabled - configs are merged in the order specified.
 	// +optional
 	Flavours *[]Flavour `json:"flavours,omitempty"`
+
+	// Plugin infrastructure components (e.g. serverless-logic) the instance requires,
+	// in addition to the ones required by the enabled flavours.
+	// Taken into account only if the Operator manages the plugin infrastructure,
+	// the instance is not deployed until these components are ready.
+	// +optional
+	RequiredInfrastructure []string `json:"requiredInfrastructure,omitempty"`
 }
 
 type BackstageDeployment struct {
@@ -129,6 +159,121 @@ type Application struct {
 
 	// Route configuration. Used for OpenShift only.
 	Route *Route `json:"route,omitempty"`
+
+	// Dynamic plugins processing configuration.
+	// +optional
+	DynamicPlugins *DynamicPlugins `json:"dynamicPlugins,omitempty"`
+
+	// Default reload strategy of the ConfigMaps and Secrets mounted as files
+	// (appConfig.configMaps, extraFiles.configMaps and extraFiles.secrets), see FileObjectRef.ReloadStrategy.
+	// Changes of the extra environment variables always restart the Pod.
+	// +optional
+	// +kubebuilder:validation:Enum=restart;live
+	ReloadStrategy ReloadStrategy `json:"reloadStrategy,omitempty"`
+}
+
+type DynamicPlugins struct {
+	// List of registry mirrors applied by the Operator to every resolved plugin package URL,
+	// so the generated list of packages already points at the mirror.
+	// The longest matching prefix wins. Mirrors specified here take precedence over
+	// the Operator-wide defaults (PLUGIN_MIRRORS_backstage environment variable).
+	// Used only if the Operator processes dynamic plugins (OPERATOR_DP_PROCESSING=true).
+	// +optional
+	Mirrors []PluginMirror `json:"mirrors,omitempty"`
+
+	// Registry credentials used by the plugin installer (install-dynamic-plugins init container)
+	// to download plugin packages from private OCI and NPM registries.
+	// The referenced Secrets are watched, changing them restarts the Pod.
+	// +optional
+	RegistryAuth *PluginRegistryAuth `json:"registryAuth,omitempty"`
+
+	// Persistent cache of installed plugins.
+	// If specified, the Operator creates a PersistentVolumeClaim and uses it as the dynamic plugins root
+	// instead of the ephemeral volume, so plugins pinned by digest or integrity are not downloaded again
+	// on Pod restart and plugins removed from the configuration are pruned.
+	// +optional
+	Cache *PluginCache `json:"cache,omitempty"`
+
+	// Catalog index images used by the plugin installer to resolve plugin references.
+	// If specified, the Operator injects them into the install-dynamic-plugins init container
+	// (CATALOG_INDEX_IMAGE and EXTRA_CATALOG_INDEX_IMAGES) pinned to the resolved digest,
+	// taking precedence over the Operator-wide default (RELATED_IMAGE_catalog_index environment variable).
+	// If the Operator processes dynamic plugins (OPERATOR_DP_PROCESSING=true), the catalog index
+	// default configuration is used for resolving the plugin references on the Operator side too.
+	// +optional
+	CatalogIndex *CatalogIndex `json:"catalogIndex,omitempty"`
+}
+
+type CatalogIndex struct {
+	// Primary catalog index image, e.g. "quay.io/rhdh/plugin-catalog-index:1.10".
+	// The image may be pinned by digest (image@sha256:...), otherwise the tag is resolved
+	// to the digest by the Operator. A tag is re-resolved every 5 minutes on reconciliation
+	// and, if it moved, the Backstage Pods are rolled out with the new digest.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Extra catalog index images, merged by the plugin installer after the primary one.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Extra []NamedCatalogIndex `json:"extra,omitempty"`
+}
+
+type NamedCatalogIndex struct {
+	// Name of the catalog index
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Catalog index image, optionally pinned by digest
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+}
+
+type PluginCache struct {
+	// Requested size of the cache volume. Defaults to 2Gi.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// Name of the StorageClass of the cache volume. If not specified, the cluster default StorageClass is used.
+	// +optional
+	StorageClass *string `json:"storageClass,omitempty"`
+
+	// Access modes of the cache volume. Defaults to ReadWriteOnce, which requires all the Backstage Pods
+	// (replicas and the Pods of a rolling update) to run on the same node; use ReadWriteMany otherwise.
+	// +optional
+	// +listType=atomic
+	// +kubebuilder:validation:items:Enum=ReadWriteOnce;ReadWriteMany
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+}
+
+type PluginRegistryAuth struct {
+	// Name of a Secret (usually of kubernetes.io/dockerconfigjson type) containing
+	// OCI registries credentials in the '.dockerconfigjson' key.
+	// It is mounted into the plugin installer and used as the containers auth file (REGISTRY_AUTH_FILE).
+	// +optional
+	DockerConfigSecret string `json:"dockerConfigSecret,omitempty"`
+
+	// Name of a Secret containing NPM registries configuration and credentials in the '.npmrc' key.
+	// It is mounted into the plugin installer and used as the NPM user config (NPM_CONFIG_USERCONFIG),
+	// replacing the default one.
+	// +optional
+	NpmrcSecret string `json:"npmrcSecret,omitempty"`
+}
+
+type PluginMirror struct {
+	// Prefix of the package URL to replace, e.g. "quay.io/rhdh" or "https://registry.npmjs.org".
+	// If the prefix has no scheme, it is matched against the package URL without the scheme.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Prefix string `json:"prefix"`
+
+	// Replacement of the matched prefix, e.g. "registry.localhost:5000/rhdh".
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Replacement string `json:"replacement"`
 }
 
 type AppConfig struct {
@@ -145,6 +290,22 @@ type AppConfig struct {
 	// More details on https://backstage.io/docs/conf/writing/.
 	// +optional
 	ConfigMaps []FileObjectRef `json:"configMaps,omitempty"`
+
+	// Inline app-config content, as structured YAML.
+	// The Operator renders it into a generated ConfigMap mounted under the MountPath and passes it
+	// as the last --config argument, after the default and ConfigMaps app-configs, so it overrides them.
+	// Changing it restarts the Pod.
+	// Do not put sensitive data here, reference environment variables instead.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Type=object
+	Inline *apiextensionsv1.JSON `json:"inline,omitempty"`
+
+	// Publish the effective app-config, merged in the order of the --config arguments, in a read-only ConfigMap
+	// named backstage-appconfig-<cr-name>-effective-appconfig. Each value is annotated with its source ConfigMap and key,
+	// values of sensitive keys are masked and environment variable references are left as is.
+	// +optional
+	PublishEffectiveConfig bool `json:"publishEffectiveConfig,omitempty"`
 }
 
 type ExtraFiles struct {
@@ -160,7 +321,8 @@ type ExtraFiles struct {
 	ConfigMaps []FileObjectRef `json:"configMaps,omitempty"`
 
 	// List of references to Secrets objects mounted as extra files under the MountPath specified.
-	// For each item in this array, a key must be specified that will be mounted as a file.
+	// For each item in this array, if a key is specified, only this key will be mounted as a file.
+	// Otherwise, the whole Secret (or its items) is mounted as a directory, to default-path/Name if no mountPath is specified.
 	// +optional
 	Secrets []FileObjectRef `json:"secrets,omitempty"`
 
@@ -188,6 +350,7 @@ type ExtraEnvs struct {
 	Envs []Env `json:"envs,omitempty"`
 }
 
+// +kubebuilder:validation:XValidation:rule="!(has(self.key) && self.key != \"\" && has(self.items))",message="key and items are mutually exclusive"
 type EnvObjectRef struct {
 	// Name of the object
 	// We support only ConfigMaps and Secrets.
@@ -198,6 +361,17 @@ type EnvObjectRef struct {
 	// +optional
 	Key string `json:"key,omitempty"`
 
+	// Prefix prepended to the names of the environment variables injected from the object,
+	// e.g. GITHUB_ for the GITHUB_CLIENT_ID variable from the CLIENT_ID key.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Keys of the object to inject, each as an individual environment variable named As (the key by default)
+	// with the Prefix. Key must not be specified then.
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	Items []EnvObjectItem `json:"items,omitempty"`
+
 	// If set, the env variable will be injected only in the specified containers, otherwise in backstage container only.
 	// If it contains only "*", it means all containers and no other names are allowed.
 	// +optional
@@ -206,6 +380,19 @@ type EnvObjectRef struct {
 	Containers []string `json:"containers,omitempty"`
 }
 
+// EnvObjectItem maps a key of a ConfigMap or Secret to the environment variable it is injected as
+type EnvObjectItem struct {
+	// Key in the object
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+
+	// Name of the environment variable, without the Prefix of the reference. The Key by default.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z][-._a-zA-Z0-9]*$`
+	As string `json:"as,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:rule="!(has(self.key) && self.key != \"\" && has(self.items))",message="key and items are mutually exclusive"
 type FileObjectRef struct {
 	// Name of the object
 	// Supported ConfigMaps and Secrets
@@ -216,6 +403,20 @@ type FileObjectRef struct {
 	// +optional
 	Key string `json:"key,omitempty"`
 
+	// Keys of the object to mount and the files they are mounted as.
+	// If specified, the object is mounted as a directory (the MountPath if specified, otherwise default-path/Name),
+	// without subPath, containing only these files. Key must not be specified then.
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	Items []FileObjectItem `json:"items,omitempty"`
+
+	// Mode bits of the mounted files, 0644 (420) by default.
+	// Must be an octal value between 0000 and 0777 or a decimal value between 0 and 511.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=511
+	DefaultMode *int32 `json:"defaultMode,omitempty"`
+
 	// Path to mount the Object. If not specified default-path/Name will be used
 	// +optional
 	MountPath string `json:"mountPath"`
@@ -226,8 +427,46 @@ type FileObjectRef struct {
 	// +kubebuilder:validation:MinItems=1
 	// +kubebuilder:validation:XValidation:rule="!(size(self) != 1 && self[0]==\"*\")",message="If '*' is specified, no other container names are allowed"
 	Containers []string `json:"containers,omitempty"`
+
+	// How the changes of the object get to Backstage: restart restarts the Pod,
+	// live mounts the object as a directory (the MountPath if specified, otherwise default-path/Name),
+	// without subPath, so Kubernetes updates the files and Backstage watches and reloads them without restart.
+	// If the Key is specified, only this key is mounted in the directory.
+	// If not specified, spec.application.reloadStrategy is used, if neither is specified the Pod is restarted.
+	// +optional
+	// +kubebuilder:validation:Enum=restart;live
+	ReloadStrategy ReloadStrategy `json:"reloadStrategy,omitempty"`
 }
 
+// FileObjectItem maps a key of a ConfigMap or Secret to the file it is mounted as
+type FileObjectItem struct {
+	// Key in the object
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+
+	// Path of the file, relative to the directory the object is mounted to. The Key by default.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Mode bits of the file, the DefaultMode of the reference if not specified.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=511
+	Mode *int32 `json:"mode,omitempty"`
+}
+
+// ReloadStrategy defines how the changes of a ConfigMap or Secret mounted as files get to Backstage
+type ReloadStrategy string
+
+const (
+	// ReloadStrategyRestart mounts the files with subPath (unless only the mountPath is specified)
+	// and restarts the Pod when the object changes
+	ReloadStrategyRestart ReloadStrategy = "restart"
+	// ReloadStrategyLive mounts the object as a directory, without subPath, so Kubernetes updates the files
+	// and Backstage reloads them without the Pod restart
+	ReloadStrategyLive ReloadStrategy = "live"
+)
+
 type PvcRef struct {
 	// Name of the object
 	// +kubebuilder:validation:Required
@@ -267,6 +506,93 @@ type BackstageStatus struct {
 	// Conditions is the list of conditions describing the state of the runtime
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// DynamicPlugins reports the dynamic plugins processed by the Operator
+	// +optional
+	DynamicPlugins *DynamicPluginsStatus `json:"dynamicPlugins,omitempty"`
+
+	// PluginDependencies reports the state of the dynamic plugins dependencies applied by the Operator
+	// +optional
+	PluginDependencies []PluginDependencyStatus `json:"pluginDependencies,omitempty"`
+
+	// Flavours reports the flavours enabled for the instance, in the order their configuration is merged
+	// +optional
+	Flavours []FlavourStatus `json:"flavours,omitempty"`
+}
+
+type FlavourStatus struct {
+	// Name of the flavour
+	Name string `json:"name"`
+
+	// ConfigMap in the Operator namespace the user flavour is loaded from, empty for the flavours shipped with the Operator
+	// +optional
+	ConfigMap string `json:"configMap,omitempty"`
+}
+
+type DynamicPluginsStatus struct {
+	// Packages is the list of enabled plugin packages passed to the plugin installer
+	// +optional
+	Packages []PluginPackageStatus `json:"packages,omitempty"`
+
+	// CatalogIndex reports the catalog index images configured in the spec and their resolved digests
+	// +optional
+	CatalogIndex []CatalogIndexStatus `json:"catalogIndex,omitempty"`
+}
+
+type CatalogIndexStatus struct {
+	// Name of the extra catalog index, empty for the primary one
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Image as specified
+	Image string `json:"image"`
+
+	// Digest the image was resolved to
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// Message describing why the image could not be resolved
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type PluginPackageStatus struct {
+	// Package URL as passed to the plugin installer
+	Package string `json:"package"`
+
+	// Original package reference, set if the package URL was rewritten (e.g. by a registry mirror)
+	// +optional
+	Original string `json:"original,omitempty"`
+}
+
+type PluginDependencyStatus struct {
+	// Ref is the name the dependency is referenced with by the plugins
+	Ref string `json:"ref"`
+
+	// State of the dependency
+	// +kubebuilder:validation:Enum=Applied;Ready;Pending;Error
+	State PluginDependencyState `json:"state"`
+
+	// Message describing the state, e.g. the objects which are not ready or the error
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Shared is true if the dependency objects are shared with other Backstage instances
+	// +optional
+	Shared bool `json:"shared,omitempty"`
+
+	// Objects applied for the dependency
+	// +optional
+	Objects []PluginDependencyObject `json:"objects,omitempty"`
+}
+
+type PluginDependencyObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Namespace of the object, empty for cluster-scoped objects
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -364,6 +690,11 @@ type Flavour struct {
 	// +optional
 	// +kubebuilder:default=true
 	Enabled bool `json:"enabled,omitempty"`
+
+	// Parameters of the flavour, by name. The parameters, their types and default values are declared
+	// in the flavour metadata, and the values are substituted into the flavour configuration.
+	// +optional
+	Parameters map[string]apiextensionsv1.JSON `json:"parameters,omitempty"`
 }
 
 func init() {
@@ -386,6 +717,16 @@ func (s *BackstageSpec) IsRouteEnabled() bool {
 	return true
 }
 
+// IsLiveReload returns true if the changes of the ConfigMap or Secret mounted as files are reloaded live,
+// as specified in the reference or by default in spec.application.reloadStrategy.
+// If not specified, the changes restart the Pod.
+func (s *BackstageSpec) IsLiveReload(ref FileObjectRef) bool {
+	if ref.ReloadStrategy != "" {
+		return ref.ReloadStrategy == ReloadStrategyLive
+	}
+	return s.Application != nil && s.Application.ReloadStrategy == ReloadStrategyLive
+}
+
 func (s *BackstageSpec) IsAuthSecretSpecified() bool {
 	return s.Database != nil && s.Database.AuthSecretName != ""
 }
@@ -5,6 +5,7 @@
 package v1alpha5
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
@@ -20,6 +21,11 @@ func (in *AppConfig) DeepCopyInto(out *AppConfig) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Inline != nil {
+		in, out := &in.Inline, &out.Inline
+		*out = new(v1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppConfig.
@@ -55,6 +61,11 @@ func (in *Application) DeepCopyInto(out *Application) {
 		*out = new(Route)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DynamicPlugins != nil {
+		in, out := &in.DynamicPlugins, &out.DynamicPlugins
+		*out = new(DynamicPlugins)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Application.
@@ -176,9 +187,16 @@ func (in *BackstageSpec) DeepCopyInto(out *BackstageSpec) {
 		if **in != nil {
 			in, out := *in, *out
 			*out = make([]Flavour, len(*in))
-			copy(*out, *in)
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
 		}
 	}
+	if in.RequiredInfrastructure != nil {
+		in, out := &in.RequiredInfrastructure, &out.RequiredInfrastructure
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackstageSpec.
@@ -201,6 +219,23 @@ func (in *BackstageStatus) DeepCopyInto(out *BackstageStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DynamicPlugins != nil {
+		in, out := &in.DynamicPlugins, &out.DynamicPlugins
+		*out = new(DynamicPluginsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PluginDependencies != nil {
+		in, out := &in.PluginDependencies, &out.PluginDependencies
+		*out = make([]PluginDependencyStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Flavours != nil {
+		in, out := &in.Flavours, &out.Flavours
+		*out = make([]FlavourStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackstageStatus.
@@ -213,6 +248,41 @@ func (in *BackstageStatus) DeepCopy() *BackstageStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CatalogIndex) DeepCopyInto(out *CatalogIndex) {
+	*out = *in
+	if in.Extra != nil {
+		in, out := &in.Extra, &out.Extra
+		*out = make([]NamedCatalogIndex, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CatalogIndex.
+func (in *CatalogIndex) DeepCopy() *CatalogIndex {
+	if in == nil {
+		return nil
+	}
+	out := new(CatalogIndex)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CatalogIndexStatus) DeepCopyInto(out *CatalogIndexStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CatalogIndexStatus.
+func (in *CatalogIndexStatus) DeepCopy() *CatalogIndexStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CatalogIndexStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Database) DeepCopyInto(out *Database) {
 	*out = *in
@@ -233,6 +303,66 @@ func (in *Database) DeepCopy() *Database {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicPlugins) DeepCopyInto(out *DynamicPlugins) {
+	*out = *in
+	if in.Mirrors != nil {
+		in, out := &in.Mirrors, &out.Mirrors
+		*out = make([]PluginMirror, len(*in))
+		copy(*out, *in)
+	}
+	if in.RegistryAuth != nil {
+		in, out := &in.RegistryAuth, &out.RegistryAuth
+		*out = new(PluginRegistryAuth)
+		**out = **in
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(PluginCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CatalogIndex != nil {
+		in, out := &in.CatalogIndex, &out.CatalogIndex
+		*out = new(CatalogIndex)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicPlugins.
+func (in *DynamicPlugins) DeepCopy() *DynamicPlugins {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicPlugins)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicPluginsStatus) DeepCopyInto(out *DynamicPluginsStatus) {
+	*out = *in
+	if in.Packages != nil {
+		in, out := &in.Packages, &out.Packages
+		*out = make([]PluginPackageStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.CatalogIndex != nil {
+		in, out := &in.CatalogIndex, &out.CatalogIndex
+		*out = make([]CatalogIndexStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicPluginsStatus.
+func (in *DynamicPluginsStatus) DeepCopy() *DynamicPluginsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicPluginsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Env) DeepCopyInto(out *Env) {
 	*out = *in
@@ -253,9 +383,29 @@ func (in *Env) DeepCopy() *Env {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnvObjectItem) DeepCopyInto(out *EnvObjectItem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvObjectItem.
+func (in *EnvObjectItem) DeepCopy() *EnvObjectItem {
+	if in == nil {
+		return nil
+	}
+	out := new(EnvObjectItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EnvObjectRef) DeepCopyInto(out *EnvObjectRef) {
 	*out = *in
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EnvObjectItem, len(*in))
+		copy(*out, *in)
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make([]string, len(*in))
@@ -345,9 +495,41 @@ func (in *ExtraFiles) DeepCopy() *ExtraFiles {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileObjectItem) DeepCopyInto(out *FileObjectItem) {
+	*out = *in
+	if in.Mode != nil {
+		in, out := &in.Mode, &out.Mode
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileObjectItem.
+func (in *FileObjectItem) DeepCopy() *FileObjectItem {
+	if in == nil {
+		return nil
+	}
+	out := new(FileObjectItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FileObjectRef) DeepCopyInto(out *FileObjectRef) {
 	*out = *in
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FileObjectItem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DefaultMode != nil {
+		in, out := &in.DefaultMode, &out.DefaultMode
+		*out = new(int32)
+		**out = **in
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make([]string, len(*in))
@@ -368,6 +550,13 @@ func (in *FileObjectRef) DeepCopy() *FileObjectRef {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Flavour) DeepCopyInto(out *Flavour) {
 	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]v1.JSON, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Flavour.
@@ -380,6 +569,21 @@ func (in *Flavour) DeepCopy() *Flavour {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlavourStatus) DeepCopyInto(out *FlavourStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlavourStatus.
+func (in *FlavourStatus) DeepCopy() *FlavourStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FlavourStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Monitoring) DeepCopyInto(out *Monitoring) {
 	*out = *in
@@ -395,6 +599,131 @@ func (in *Monitoring) DeepCopy() *Monitoring {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamedCatalogIndex) DeepCopyInto(out *NamedCatalogIndex) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamedCatalogIndex.
+func (in *NamedCatalogIndex) DeepCopy() *NamedCatalogIndex {
+	if in == nil {
+		return nil
+	}
+	out := new(NamedCatalogIndex)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginCache) DeepCopyInto(out *PluginCache) {
+	*out = *in
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.StorageClass != nil {
+		in, out := &in.StorageClass, &out.StorageClass
+		*out = new(string)
+		**out = **in
+	}
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]corev1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginCache.
+func (in *PluginCache) DeepCopy() *PluginCache {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginDependencyObject) DeepCopyInto(out *PluginDependencyObject) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginDependencyObject.
+func (in *PluginDependencyObject) DeepCopy() *PluginDependencyObject {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginDependencyObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginDependencyStatus) DeepCopyInto(out *PluginDependencyStatus) {
+	*out = *in
+	if in.Objects != nil {
+		in, out := &in.Objects, &out.Objects
+		*out = make([]PluginDependencyObject, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginDependencyStatus.
+func (in *PluginDependencyStatus) DeepCopy() *PluginDependencyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginDependencyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginMirror) DeepCopyInto(out *PluginMirror) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginMirror.
+func (in *PluginMirror) DeepCopy() *PluginMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginPackageStatus) DeepCopyInto(out *PluginPackageStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginPackageStatus.
+func (in *PluginPackageStatus) DeepCopy() *PluginPackageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginPackageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginRegistryAuth) DeepCopyInto(out *PluginRegistryAuth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginRegistryAuth.
+func (in *PluginRegistryAuth) DeepCopy() *PluginRegistryAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginRegistryAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PvcRef) DeepCopyInto(out *PvcRef) {
 	*out = *in
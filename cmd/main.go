@@ -6,6 +6,7 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -38,6 +39,7 @@ import (
 	configv1 "github.com/openshift/api/config/v1"
 	openshift "github.com/openshift/api/route/v1"
 	tlspkg "github.com/openshift/controller-runtime-common/pkg/tls"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -55,11 +57,17 @@ func init() {
 
 	utilruntime.Must(monitoringv1.AddToScheme(scheme))
 
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
 	utilruntime.Must(configv1.Install(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(render(os.Args[2:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
@@ -68,6 +76,7 @@ func main() {
 	var metricsCertPath, metricsCertName, metricsCertKey string
 	var webhookCertPath, webhookCertName, webhookCertKey string
 	var enableCacheLabelFilter bool
+	var enablePluginInfra bool
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -90,6 +99,8 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableCacheLabelFilter, "enable-cache-label-filter", os.Getenv("ENABLE_CACHE_LABEL_FILTER") == "true",
 		"If set, the cache will only store Secrets and ConfigMaps with the label 'rhdh.redhat.com/external-config=true'. This reduces memory consumption. Can also be set via ENABLE_CACHE_LABEL_FILTER env var.")
+	flag.BoolVar(&enablePluginInfra, "enable-plugin-infra", os.Getenv("PLUGIN_INFRA_ENABLED") == "true",
+		"If set, the Operator applies the plugin infrastructure (e.g. Operators required by the orchestrator flavour) Backstage instances require and waits for it to be ready before deploying them. Can also be set via PLUGIN_INFRA_ENABLED env var.")
 
 	opts := zap.Options{
 		Development: true,
@@ -284,10 +295,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	var userFlavours *controller.UserFlavourLoader
+	if ns := operatorNamespace(); ns != "" {
+		if userFlavours, err = controller.NewUserFlavourLoader(mgr, ns); err != nil {
+			setupLog.Error(err, "unable to create user flavour loader")
+			os.Exit(1)
+		}
+	}
+
+	if enablePluginInfra {
+		setupLog.Info("Enabling plugin infrastructure management")
+		if err = (&controller.PluginInfraReconciler{
+			Client:       mgr.GetClient(),
+			Platform:     plf,
+			UserFlavours: userFlavours,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PluginInfra")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controller.BackstageReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Platform: plf,
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		Platform:     plf,
+		PluginInfra:  enablePluginInfra,
+		UserFlavours: userFlavours,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Backstage")
 		os.Exit(1)
@@ -336,6 +369,20 @@ func main() {
 	}
 }
 
+// operatorNamespace returns the namespace the Operator runs in, set with the OPERATOR_NAMESPACE env var
+// or read from the service account, empty if unknown
+func operatorNamespace() string {
+	if ns, ok := os.LookupEnv("OPERATOR_NAMESPACE"); ok {
+		return ns
+	}
+	ns, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		setupLog.Info("unable to determine the Operator namespace, user flavours are not supported", "error", err.Error())
+		return ""
+	}
+	return strings.TrimSpace(string(ns))
+}
+
 func getTLSProfileConfig(
 	ctx context.Context,
 	restConfig *rest.Config,
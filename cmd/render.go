@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/redhat-developer/rhdh-operator/internal/controller"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+// render implements the render command, which prints what the Operator generates for a Backstage custom resource
+// without a cluster. The referenced ConfigMaps and Secrets are read from the same file as the custom resource.
+func render(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	effectiveConfig := fs.Bool("effective-config", false, "Print the effective app-config, annotated with the source of each value.")
+	file := fs.String("f", "-", "Multi-document YAML file with the Backstage custom resource and the ConfigMaps and Secrets it references, - for stdin.")
+	openshift := fs.Bool("openshift", false, "Render for OpenShift.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !*effectiveConfig {
+		fmt.Fprintln(os.Stderr, "nothing to render, use --effective-config")
+		return 2
+	}
+
+	var data []byte
+	var err error
+	if *file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", *file, err)
+		return 1
+	}
+
+	objects, err := utils.ReadYamls(data, nil, *scheme)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read objects: %v\n", err)
+		return 1
+	}
+
+	plf := platform.Default
+	if *openshift {
+		plf = platform.OpenShift
+	}
+	out, err := controller.RenderEffectiveAppConfig(context.Background(), objects, scheme, plf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to render effective app-config: %v\n", err)
+		return 1
+	}
+	fmt.Print(out)
+	return 0
+}
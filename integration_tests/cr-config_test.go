@@ -191,7 +191,7 @@ spec:
 		}, time.Minute, time.Second).Should(Succeed(), controllerMessage())
 	})
 
-	It("generates label and annotation", func() {
+	It("does not modify external configuration objects", func() {
 
 		appConfig := generateConfigMap(ctx, k8sClient, "app-config1", ns, map[string]string{"key11": "app:"}, nil, nil)
 
@@ -207,16 +207,16 @@ spec:
 
 		backstageName := createAndReconcileBackstage(ctx, ns, bs, "")
 		Eventually(func(g Gomega) {
+			deploy, err := backstageDeployment(ctx, k8sClient, ns, backstageName)
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(deploy.PodObjectMeta().Annotations).To(HaveKey(model.ExtConfigHashAnnotation))
 
 			cm := &corev1.ConfigMap{}
-			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: ns, Name: appConfig}, cm)
+			err = k8sClient.Get(ctx, types.NamespacedName{Namespace: ns, Name: appConfig}, cm)
 			g.Expect(err).ShouldNot(HaveOccurred())
 
-			g.Expect(cm.Labels).To(HaveLen(1))
-			g.Expect(cm.Labels[model.ExtConfigSyncLabel]).To(Equal("true"))
-
-			g.Expect(cm.Annotations).To(HaveLen(1))
-			g.Expect(cm.Annotations[model.BackstageNameAnnotation]).To(Equal(backstageName))
+			g.Expect(cm.Labels).To(BeEmpty())
+			g.Expect(cm.Annotations).To(BeEmpty())
 
 		}, 10*time.Second, time.Second).Should(Succeed())
 
@@ -27,7 +27,7 @@ import (
 const (
 	BackstageFieldManager = "backstage-controller"
 
-	// AutoSyncEnvVar: EXT_CONF_SYNC_backstage env variable which defines the value for rhdh.redhat.com/ext-config-sync annotation of external config object (ConfigMap|Secret)
+	// AutoSyncEnvVar: EXT_CONF_SYNC_backstage env variable which defines whether the changes of external config objects (ConfigMap|Secret) refresh the Backstage Pod
 	// True by default
 	AutoSyncEnvVar = "EXT_CONF_SYNC_backstage"
 
@@ -41,6 +41,11 @@ type BackstageReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Platform platform.Platform
+	// PluginInfra is whether the Operator manages the plugin infrastructure with the PluginInfraReconciler
+	PluginInfra bool
+	// UserFlavours loads the user flavours from the Operator namespace,
+	// nil if the user flavours are not supported
+	UserFlavours *UserFlavourLoader
 }
 
 // +kubebuilder:rbac:groups=rhdh.redhat.com,resources=backstages,verbs=get;list;watch;create;update;patch;delete
@@ -54,6 +59,7 @@ type BackstageReconciler struct {
 // +kubebuilder:rbac:groups="config.openshift.io",resources=apiservers,verbs=get;list;watch
 // +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;update;patch;delete
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch;create;patch;update
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -66,11 +72,22 @@ func (r *BackstageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if err := r.Get(ctx, req.NamespacedName, &backstage); err != nil {
 		if errors.IsNotFound(err) {
 			lg.Info("backstage gone from the namespace")
+			missingRefsBackoff.Forget(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, fmt.Errorf("failed to load backstage deployment from the cluster: %w", err)
 	}
 
+	if !backstage.GetDeletionTimestamp().IsZero() {
+		if err := r.finalizePluginDeps(ctx, &backstage); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to release plugin dependencies: %w", err)
+		}
+		if err := r.finalizeExtConfigMarkers(ctx, &backstage); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to remove external config markers: %w", err)
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// This update will make sure the status is always updated in case of any errors or successful result
 	defer func(bs *api.Backstage) {
 		if err := r.Client.Status().Update(ctx, bs); err != nil {
@@ -86,12 +103,37 @@ func (r *BackstageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		setStatusCondition(&backstage, api.BackstageConditionTypeDeployed, metav1.ConditionFalse, api.BackstageConditionReasonInProgress, "Deployment process started")
 	}
 
+	// The user flavours the instance may enable
+	userFlavours, err := r.UserFlavours.Load(ctx)
+	if err != nil {
+		return ctrl.Result{}, errorAndStatus(&backstage, "failed to load user flavours", err)
+	}
+
 	// 1. Preliminary read and prepare external config objects from the specs (configMaps, Secrets)
 	// 2. Make some validation to fail fast
 	externalConfig, err := r.preprocessSpec(ctx, backstage)
 	if err != nil {
+		if errors.IsNotFound(err) {
+			// wait for the referenced object, its creation triggers the reconciliation as well
+			setStatusCondition(&backstage, api.BackstageConditionTypeDeployed, metav1.ConditionFalse, api.BackstageConditionReasonFailed, fmt.Sprintf("failed to preprocess backstage spec %s", err))
+			requeueAfter := missingRefsBackoff.When(req.NamespacedName)
+			lg.Info("referenced object not found, requeue", "after", requeueAfter, "error", err.Error())
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
 		return ctrl.Result{}, errorAndStatus(&backstage, "failed to preprocess backstage spec", err)
 	}
+	missingRefsBackoff.Forget(req.NamespacedName)
+	externalConfig.UserFlavours = userFlavours
+
+	// Resolve the catalog index and plugin images, calling the registries only here and not in the watchers
+	if err := r.resolveImages(ctx, backstage, &externalConfig); err != nil {
+		return ctrl.Result{}, errorAndStatus(&backstage, "failed to resolve dynamic plugins images", err)
+	}
+
+	// Remove the markers of the previous Operator versions from the external config objects no longer referenced
+	if err := r.cleanupExtConfigMarkers(ctx, &backstage); err != nil {
+		return ctrl.Result{}, errorAndStatus(&backstage, "failed to remove external config markers", err)
+	}
 
 	// Apply the ServiceMonitor if monitoring is enabled
 	if err := r.applyServiceMonitor(ctx, &backstage); err != nil {
@@ -103,9 +145,29 @@ func (r *BackstageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if err != nil {
 		return ctrl.Result{}, errorAndStatus(&backstage, "failed to initialize backstage model", err)
 	}
+	setDynamicPluginsStatus(&backstage, bsModel)
+	backstage.Status.Flavours = bsModel.FlavoursStatus()
+
+	// Do not roll out an app-config Backstage fails to start with,
+	// the change of the referenced objects or the spec triggers the reconciliation again
+	if !setConfigValidStatus(&backstage, bsModel) {
+		setStatusCondition(&backstage, api.BackstageConditionTypeDeployed, metav1.ConditionFalse, api.BackstageConditionReasonFailed, "Invalid configuration, see the ConfigValid condition")
+		return ctrl.Result{}, nil
+	}
+
+	// Wait for the plugin infrastructure the instance requires
+	infraPending, err := r.checkPluginInfra(ctx, &backstage, userFlavours)
+	if err != nil {
+		return ctrl.Result{}, errorAndStatus(&backstage, "failed to check plugin infrastructure", err)
+	}
+	if infraPending {
+		setStatusCondition(&backstage, api.BackstageConditionTypeDeployed, metav1.ConditionFalse, api.BackstageConditionReasonInProgress, "Waiting for the plugin infrastructure to be ready")
+		return ctrl.Result{RequeueAfter: pluginDepsRequeueInterval}, nil
+	}
 
 	// Apply the plugin dependencies
-	if err := r.applyPluginDeps(ctx, backstage, bsModel); err != nil {
+	pluginDepsPending, err := r.applyPluginDeps(ctx, &backstage, bsModel)
+	if err != nil {
 		return ctrl.Result{}, errorAndStatus(&backstage, "failed to apply plugin dependencies", err)
 	}
 
@@ -116,6 +178,9 @@ func (r *BackstageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	r.setDeploymentStatus(ctx, &backstage, *bsModel)
+	if pluginDepsPending {
+		return ctrl.Result{RequeueAfter: pluginDepsRequeueInterval}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
@@ -184,6 +249,10 @@ func (r *BackstageReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		For(&api.Backstage{}).
 		Named("backstage")
 
+	if err := indexExtConfigRefs(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+
 	if err := r.addWatchers(b); err != nil {
 		return err
 	}
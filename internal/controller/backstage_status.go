@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/redhat-developer/rhdh-operator/api"
 	"github.com/redhat-developer/rhdh-operator/pkg/model"
@@ -51,6 +52,25 @@ func (r *BackstageReconciler) setDeploymentStatus(ctx context.Context, backstage
 	setStatusCondition(backstage, api.BackstageConditionTypeDeployed, status, state, msg)
 }
 
+// setDynamicPluginsStatus reports the dynamic plugin packages processed by the Operator
+// and the catalog index images configured in the spec
+func setDynamicPluginsStatus(backstage *api.Backstage, backstageModel *model.BackstageModel) {
+	obj := backstageModel.GetRuntimeObject(model.DynamicPluginsKey)
+	if obj == nil {
+		backstage.Status.DynamicPlugins = nil
+		return
+	}
+	plugins := obj.(*model.DynamicPlugins)
+	if len(plugins.Packages()) == 0 && len(plugins.CatalogIndexStatus()) == 0 {
+		backstage.Status.DynamicPlugins = nil
+		return
+	}
+	backstage.Status.DynamicPlugins = &api.DynamicPluginsStatus{
+		Packages:     plugins.Packages(),
+		CatalogIndex: plugins.CatalogIndexStatus(),
+	}
+}
+
 func setStatusCondition(backstage *api.Backstage, condType api.BackstageConditionType, status metav1.ConditionStatus, reason api.BackstageConditionReason, msg string) {
 	meta.SetStatusCondition(&backstage.Status.Conditions, metav1.Condition{
 		Type:               string(condType),
@@ -109,3 +129,33 @@ func statefulSetState(deploy *appsv1.StatefulSet) (state api.BackstageConditionR
 	}
 	return api.BackstageConditionReasonInProgress, msg
 }
+
+// setConfigValidStatus reports the environment variables and files referenced by the app-config
+// which are not available to the Backstage container. Returns false if there are any.
+// The environment variables set from different ConfigMaps or Secrets (the last one wins), and the dynamic plugins
+// pluginConfig conflicts and schema validation errors are reported too, but do not block the deployment.
+func setConfigValidStatus(backstage *api.Backstage, backstageModel *model.BackstageModel) bool {
+	var msgs []string
+	var pluginConfigErrs []string
+	if obj := backstageModel.GetRuntimeObject(model.AppConfigKey); obj != nil {
+		appConfig := obj.(*model.AppConfig)
+		if errs := appConfig.ValidateReferences(); len(errs) > 0 {
+			msgs = append(msgs, fmt.Sprintf("unresolved app-config references: %s", strings.Join(errs, "; ")))
+		}
+		pluginConfigErrs = appConfig.PluginConfigErrors()
+	}
+	valid := len(msgs) == 0
+	if errs := backstageModel.EnvCollisions(); len(errs) > 0 {
+		msgs = append(msgs, fmt.Sprintf("environment variable collisions: %s", strings.Join(errs, "; ")))
+	}
+	if len(pluginConfigErrs) > 0 {
+		msgs = append(msgs, fmt.Sprintf("invalid dynamic plugins pluginConfig: %s", strings.Join(pluginConfigErrs, "; ")))
+	}
+	if len(msgs) > 0 {
+		setStatusCondition(backstage, api.BackstageConditionTypeConfigValid, metav1.ConditionFalse, api.BackstageConditionReasonConfigInvalid,
+			strings.Join(msgs, "; "))
+		return valid
+	}
+	setStatusCondition(backstage, api.BackstageConditionTypeConfigValid, metav1.ConditionTrue, api.BackstageConditionReasonConfigValid, "")
+	return true
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+	"github.com/redhat-developer/rhdh-operator/pkg/oci"
+)
+
+const (
+	// catalogIndexResolveInterval is how long the digest a catalog index tag is resolved to is cached
+	catalogIndexResolveInterval = 5 * time.Minute
+	// catalogIndexFileRetention is how long the content of a pinned image no longer used stays cached
+	catalogIndexFileRetention = time.Hour
+	// catalogIndexRequestTimeout bounds the registry requests, so an unreachable registry does not block the reconciliation
+	catalogIndexRequestTimeout = 30 * time.Second
+)
+
+// catalogIndexCache caches the resolved digests and the files of the pinned catalog index and plugin images,
+// keyed by the registry credentials used, so they are not shared between differently authorized instances.
+// Expired digests and contents not used for catalogIndexFileRetention are evicted when new entries are added.
+type catalogIndexCache struct {
+	mu         sync.Mutex
+	httpClient *http.Client
+	now        func() time.Time
+	digests    map[string]resolvedDigest
+	files      map[string]cachedFile
+}
+
+type resolvedDigest struct {
+	digest   string
+	resolved time.Time
+}
+
+type cachedFile struct {
+	content string
+	missing bool
+	used    time.Time
+}
+
+func newCatalogIndexCache(httpClient *http.Client) *catalogIndexCache {
+	return &catalogIndexCache{
+		httpClient: httpClient,
+		now:        time.Now,
+		digests:    map[string]resolvedDigest{},
+		files:      map[string]cachedFile{},
+	}
+}
+
+var catalogIndexResolver = newCatalogIndexCache(&http.Client{Timeout: catalogIndexRequestTimeout})
+
+// resolveImages resolves the catalog index images of spec.application.dynamicPlugins.catalogIndex into the external config
+// and, if enabled, sets the reader of the plugin packages configSchema.
+// It calls the image registries, so it is done by Reconcile only, not by preprocessSpec which the watchers run as well.
+func (r *BackstageReconciler) resolveImages(ctx context.Context, backstage api.Backstage, externalConfig *model.ExternalConfig) error {
+	if backstage.Spec.Application == nil || backstage.Spec.Application.DynamicPlugins == nil {
+		if model.IsPluginSchemasReading() {
+			externalConfig.PluginSchemas = catalogIndexResolver.pluginSchemaReader(ctx, nil)
+		}
+		return nil
+	}
+	dp := backstage.Spec.Application.DynamicPlugins
+
+	// use the registry credentials of the plugin installer
+	var dockerConfigJSON []byte
+	if dp.RegistryAuth != nil && dp.RegistryAuth.DockerConfigSecret != "" {
+		secret := &corev1.Secret{}
+		if err := r.checkExternalObject(ctx, secret, dp.RegistryAuth.DockerConfigSecret, backstage.Namespace); err != nil {
+			return err
+		}
+		dockerConfigJSON = secret.Data[model.DockerConfigJsonKey]
+	}
+	if dp.CatalogIndex != nil {
+		var previous []api.CatalogIndexStatus
+		if backstage.Status.DynamicPlugins != nil {
+			previous = backstage.Status.DynamicPlugins.CatalogIndex
+		}
+		externalConfig.CatalogIndex = catalogIndexResolver.resolveCatalogIndex(ctx, *dp.CatalogIndex, dockerConfigJSON, previous)
+	}
+	if model.IsPluginSchemasReading() {
+		externalConfig.PluginSchemas = catalogIndexResolver.pluginSchemaReader(ctx, dockerConfigJSON)
+	}
+	return nil
+}
+
+// resolveCatalogIndex resolves the catalog index images of spec.application.dynamicPlugins.catalogIndex
+// to digests and, if the Operator processes dynamic plugins, reads their default dynamic plugins configuration.
+// Failures are not fatal: the error is reported in the status and the image is passed to the plugin installer pinned
+// by the digest previously reported in the status, or as specified if it was never resolved, so that a registry outage
+// does not change the plugin installer configuration.
+func (c *catalogIndexCache) resolveCatalogIndex(ctx context.Context, catalogIndex api.CatalogIndex, dockerConfigJSON []byte, previous []api.CatalogIndexStatus) []model.ResolvedCatalogIndex {
+	images := []api.NamedCatalogIndex{{Image: catalogIndex.Image}}
+	images = append(images, catalogIndex.Extra...)
+
+	client, err := oci.NewClient(c.httpClient, dockerConfigJSON)
+	credsKey := fmt.Sprintf("%x", sha256.Sum256(dockerConfigJSON))
+
+	result := make([]model.ResolvedCatalogIndex, 0, len(images))
+	for _, image := range images {
+		resolved := model.ResolvedCatalogIndex{
+			Status: api.CatalogIndexStatus{Name: image.Name, Image: image.Image},
+			Image:  image.Image,
+		}
+		if err != nil {
+			resolved.Status.Message = err.Error()
+		} else if err := c.resolve(ctx, client, credsKey, &resolved, lastDigest(previous, image)); err != nil {
+			log.FromContext(ctx).V(1).Info("failed to resolve catalog index image", "image", image.Image, "error", err.Error())
+			resolved.Status.Message = err.Error()
+		}
+		result = append(result, resolved)
+	}
+	return result
+}
+
+// lastDigest returns the digest the image was last resolved to, as reported in the status, empty if none
+func lastDigest(previous []api.CatalogIndexStatus, image api.NamedCatalogIndex) string {
+	for _, status := range previous {
+		if status.Name == image.Name && status.Image == image.Image {
+			return status.Digest
+		}
+	}
+	return ""
+}
+
+func (c *catalogIndexCache) resolve(ctx context.Context, client *oci.Client, credsKey string, resolved *model.ResolvedCatalogIndex, lastDigest string) error {
+	ref, err := oci.ParseReference(resolved.Status.Image)
+	if err != nil {
+		return err
+	}
+
+	digest := ref.Digest
+	var resolveErr error
+	if digest == "" {
+		if digest, err = c.digest(ctx, client, credsKey, ref); err != nil {
+			resolveErr = fmt.Errorf("failed to resolve catalog index image digest: %w", err)
+			if lastDigest == "" {
+				return resolveErr
+			}
+			digest = lastDigest
+		}
+	}
+	pinned := ref.Pinned(digest)
+	resolved.Status.Digest = digest
+	resolved.Image = pinned.String()
+
+	if !model.IsOperatorDPProcessing() {
+		return resolveErr
+	}
+	content, err := c.readFile(ctx, client, credsKey, pinned, model.CatalogIndexDefaultConfigFile)
+	if errors.Is(err, oci.ErrNotFound) {
+		return fmt.Errorf("catalog index image does not contain %s", model.CatalogIndexDefaultConfigFile)
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s of catalog index image: %w", model.CatalogIndexDefaultConfigFile, err)
+	}
+	resolved.DefaultConfig = content
+	return resolveErr
+}
+
+func (c *catalogIndexCache) digest(ctx context.Context, client *oci.Client, credsKey string, ref oci.Reference) (string, error) {
+	key := credsKey + "/" + ref.String()
+	c.mu.Lock()
+	cached, ok := c.digests[key]
+	c.mu.Unlock()
+	if ok && c.now().Sub(cached.resolved) < catalogIndexResolveInterval {
+		return cached.digest, nil
+	}
+
+	digest, err := client.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.evict()
+	c.digests[key] = resolvedDigest{digest: digest, resolved: c.now()}
+	c.mu.Unlock()
+	return digest, nil
+}
+
+// readFile reads the file of the pinned image, which never changes once read. A missing file is cached as well.
+func (c *catalogIndexCache) readFile(ctx context.Context, client *oci.Client, credsKey string, ref oci.Reference, name string) (string, error) {
+	key := credsKey + "/" + ref.String() + "!" + name
+	c.mu.Lock()
+	cached, ok := c.files[key]
+	if ok {
+		cached.used = c.now()
+		c.files[key] = cached
+	}
+	c.mu.Unlock()
+	if ok && cached.missing {
+		return "", fmt.Errorf("file %s %w in image %s", name, oci.ErrNotFound, ref)
+	} else if ok {
+		return cached.content, nil
+	}
+
+	data, err := client.ReadFile(ctx, ref, name)
+	if err != nil && !errors.Is(err, oci.ErrNotFound) {
+		return "", err
+	}
+	c.mu.Lock()
+	c.evict()
+	c.files[key] = cachedFile{content: string(data), missing: err != nil, used: c.now()}
+	c.mu.Unlock()
+	return string(data), err
+}
+
+// evict removes the expired digests and the contents not used for catalogIndexFileRetention, c.mu must be held
+func (c *catalogIndexCache) evict() {
+	now := c.now()
+	for key, d := range c.digests {
+		if now.Sub(d.resolved) >= catalogIndexResolveInterval {
+			delete(c.digests, key)
+		}
+	}
+	for key, f := range c.files {
+		if now.Sub(f.used) >= catalogIndexFileRetention {
+			delete(c.files, key)
+		}
+	}
+}
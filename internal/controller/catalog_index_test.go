@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+func testDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// catalogIndexRegistry serves the rhdh/index:1.10 image containing the dynamic plugins default configuration
+func catalogIndexRegistry(t *testing.T, manifestRequests *int) (*httptest.Server, string) {
+	return imageRegistry(t, "rhdh/index", "1.10", map[string]string{model.CatalogIndexDefaultConfigFile: "plugins: []\n"}, manifestRequests)
+}
+
+// imageRegistry serves the repo:tag image with a single layer containing the files
+func imageRegistry(t *testing.T, repo, tag string, files map[string]string, manifestRequests *int) (*httptest.Server, string) {
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	for _, name := range utils.SortedKeys(files) {
+		content := files[name]
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"layers":    []map[string]interface{}{{"digest": testDigest(layer.Bytes()), "size": layer.Len()}},
+	})
+	blobs := map[string][]byte{
+		"manifests/" + tag:                   manifest,
+		"manifests/" + testDigest(manifest):  manifest,
+		"blobs/" + testDigest(layer.Bytes()): layer.Bytes(),
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path := strings.TrimPrefix(req.URL.Path, "/v2/"+repo+"/")
+		if strings.HasPrefix(path, "manifests/") {
+			*manifestRequests++
+		}
+		if b, ok := blobs[path]; ok {
+			_, _ = w.Write(b)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	return srv, testDigest(manifest)
+}
+
+func TestResolveCatalogIndex(t *testing.T) {
+	manifestRequests := 0
+	srv, digest := catalogIndexRegistry(t, &manifestRequests)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	cache := newCatalogIndexCache(srv.Client())
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	catalogIndex := api.CatalogIndex{
+		Image: host + "/rhdh/index:1.10",
+		Extra: []api.NamedCatalogIndex{
+			{Name: "pinned", Image: host + "/rhdh/index@" + digest},
+			{Name: "missing", Image: host + "/rhdh/index:2.0"},
+			{Name: "invalid", Image: "quay.io/rhdh/index@sha256:abc"},
+		},
+	}
+
+	resolved := cache.resolveCatalogIndex(context.TODO(), catalogIndex, nil, nil)
+	assert.Len(t, resolved, 4)
+	assert.Equal(t, api.CatalogIndexStatus{Image: host + "/rhdh/index:1.10", Digest: digest}, resolved[0].Status)
+	assert.Equal(t, host+"/rhdh/index@"+digest, resolved[0].Image)
+	// the content is read only if the Operator processes dynamic plugins
+	assert.Empty(t, resolved[0].DefaultConfig)
+	assert.Equal(t, digest, resolved[1].Status.Digest)
+	assert.Contains(t, resolved[2].Status.Message, "failed to resolve catalog index image digest")
+	assert.Equal(t, host+"/rhdh/index:2.0", resolved[2].Image)
+	assert.Contains(t, resolved[3].Status.Message, "unsupported digest")
+	// pinned images are not resolved
+	assert.Equal(t, 2, manifestRequests)
+
+	// if the tag cannot be resolved, the image stays pinned by the digest last reported in the status
+	previous := []api.CatalogIndexStatus{{Image: host + "/rhdh/index:2.0", Digest: digest}}
+	resolved = cache.resolveCatalogIndex(context.TODO(), api.CatalogIndex{Image: host + "/rhdh/index:2.0"}, nil, previous)
+	assert.Equal(t, digest, resolved[0].Status.Digest)
+	assert.Equal(t, host+"/rhdh/index@"+digest, resolved[0].Image)
+	assert.Contains(t, resolved[0].Status.Message, "failed to resolve catalog index image digest")
+
+	t.Setenv(model.OperatorDPProcessingEnvVar, "true")
+	manifestRequests = 0
+	resolved = cache.resolveCatalogIndex(context.TODO(), api.CatalogIndex{Image: host + "/rhdh/index:1.10"}, nil, nil)
+	assert.Equal(t, "plugins: []\n", resolved[0].DefaultConfig)
+	// the digest is cached, the content is read from the pinned image
+	assert.Equal(t, 1, manifestRequests)
+
+	resolved = cache.resolveCatalogIndex(context.TODO(), api.CatalogIndex{Image: host + "/rhdh/index:1.10"}, nil, nil)
+	assert.Equal(t, "plugins: []\n", resolved[0].DefaultConfig)
+	assert.Equal(t, 1, manifestRequests)
+
+	// the tag is resolved again after the cache interval
+	now = now.Add(catalogIndexResolveInterval)
+	cache.resolveCatalogIndex(context.TODO(), api.CatalogIndex{Image: host + "/rhdh/index:1.10"}, nil, nil)
+	assert.Equal(t, 2, manifestRequests)
+	assert.Len(t, cache.digests, 1)
+	assert.Len(t, cache.files, 1)
+
+	// the expired digests and the contents no longer used are evicted when new entries are added
+	now = now.Add(catalogIndexFileRetention)
+	otherCreds := []byte(`{"auths":{}}`)
+	otherKey := fmt.Sprintf("%x", sha256.Sum256(otherCreds))
+	cache.resolveCatalogIndex(context.TODO(), api.CatalogIndex{Image: host + "/rhdh/index:1.10"}, otherCreds, nil)
+	assert.Equal(t, []string{otherKey + "/" + host + "/rhdh/index:1.10"}, utils.SortedKeys(cache.digests))
+	assert.Equal(t, []string{otherKey + "/" + host + "/rhdh/index@" + digest + "!" + model.CatalogIndexDefaultConfigFile}, utils.SortedKeys(cache.files))
+}
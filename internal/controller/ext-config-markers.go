@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+)
+
+// ExtConfigMarkersFinalizer makes sure the markers set by the previous versions of the Operator
+// on the external config objects are removed when the Backstage is deleted
+const ExtConfigMarkersFinalizer = "rhdh.redhat.com/ext-config-markers"
+
+// cleanupExtConfigMarkers removes the rhdh.redhat.com/ext-config-sync label and rhdh.redhat.com/backstage-name annotation,
+// set by the previous versions of the Operator, from the ConfigMaps and Secrets marked for the Backstage
+// and no longer referenced by it. The finalizer is kept while some marked objects are still referenced.
+func (r *BackstageReconciler) cleanupExtConfigMarkers(ctx context.Context, backstage *api.Backstage) error {
+	remaining, err := r.removeExtConfigMarkers(ctx, backstage, extConfigRefs(backstage))
+	if err != nil {
+		return err
+	}
+
+	var changed bool
+	if remaining {
+		changed = controllerutil.AddFinalizer(backstage, ExtConfigMarkersFinalizer)
+	} else {
+		changed = controllerutil.RemoveFinalizer(backstage, ExtConfigMarkersFinalizer)
+	}
+	if !changed {
+		return nil
+	}
+
+	// Update returns the stored object, keep the status updated during this reconciliation
+	status := backstage.Status.DeepCopy()
+	if err := r.Update(ctx, backstage); err != nil {
+		return fmt.Errorf("failed to update external config markers finalizer: %w", err)
+	}
+	backstage.Status = *status
+	return nil
+}
+
+// finalizeExtConfigMarkers removes the markers of all the objects marked for the Backstage being deleted
+// and removes the finalizer
+func (r *BackstageReconciler) finalizeExtConfigMarkers(ctx context.Context, backstage *api.Backstage) error {
+	if !controllerutil.ContainsFinalizer(backstage, ExtConfigMarkersFinalizer) {
+		return nil
+	}
+	if _, err := r.removeExtConfigMarkers(ctx, backstage, nil); err != nil {
+		return err
+	}
+	controllerutil.RemoveFinalizer(backstage, ExtConfigMarkersFinalizer)
+	return r.Update(ctx, backstage)
+}
+
+// removeExtConfigMarkers removes the markers from the objects marked for the Backstage, except the referenced ones.
+// It returns true if some referenced objects are still marked.
+func (r *BackstageReconciler) removeExtConfigMarkers(ctx context.Context, backstage *api.Backstage, refs []string) (bool, error) {
+	lg := log.FromContext(ctx)
+
+	cms := &corev1.ConfigMapList{}
+	if err := r.List(ctx, cms, client.InNamespace(backstage.Namespace), client.HasLabels{model.ExtConfigSyncLabel}); err != nil {
+		return false, fmt.Errorf("failed to list marked ConfigMaps: %w", err)
+	}
+	secrets := &corev1.SecretList{}
+	if err := r.List(ctx, secrets, client.InNamespace(backstage.Namespace), client.HasLabels{model.ExtConfigSyncLabel}); err != nil {
+		return false, fmt.Errorf("failed to list marked Secrets: %w", err)
+	}
+
+	marked := map[string]client.Object{}
+	for i := range cms.Items {
+		marked["ConfigMap/"+cms.Items[i].Name] = &cms.Items[i]
+	}
+	for i := range secrets.Items {
+		marked["Secret/"+secrets.Items[i].Name] = &secrets.Items[i]
+	}
+
+	remaining := false
+	for ref, obj := range marked {
+		if obj.GetAnnotations()[model.BackstageNameAnnotation] != backstage.Name {
+			continue
+		}
+		if slices.Contains(refs, ref) {
+			remaining = true
+			continue
+		}
+
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		labels, annotations := obj.GetLabels(), obj.GetAnnotations()
+		delete(labels, model.ExtConfigSyncLabel)
+		delete(annotations, model.BackstageNameAnnotation)
+		obj.SetLabels(labels)
+		obj.SetAnnotations(annotations)
+		if err := r.Patch(ctx, obj, patch); err != nil && !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to remove markers from %s: %w", ref, err)
+		}
+		lg.V(1).Info("removed external config markers", "object", ref)
+	}
+	return remaining, nil
+}
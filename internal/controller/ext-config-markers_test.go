@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+)
+
+func TestCleanupExtConfigMarkers(t *testing.T) {
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = api.AddToScheme(scheme)
+
+	marked := func(name, backstageName string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "ns1",
+			Labels:      map[string]string{model.ExtConfigSyncLabel: "true", "app": "my"},
+			Annotations: map[string]string{model.BackstageNameAnnotation: backstageName},
+		}
+	}
+
+	bs := &api.Backstage{
+		ObjectMeta: metav1.ObjectMeta{Name: "bs1", Namespace: "ns1"},
+		Spec: api.BackstageSpec{Application: &api.Application{
+			AppConfig: &api.AppConfig{ConfigMaps: []api.FileObjectRef{{Name: "app-config"}}},
+		}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(bs,
+			&corev1.ConfigMap{ObjectMeta: marked("app-config", "bs1")},
+			&corev1.ConfigMap{ObjectMeta: marked("unreferenced", "bs1")},
+			&corev1.ConfigMap{ObjectMeta: marked("other", "bs2")},
+			&corev1.Secret{ObjectMeta: marked("secret", "bs1")}).
+		Build()
+	r := &BackstageReconciler{Client: c, Scheme: scheme}
+
+	assertMarked := func(obj *corev1.ConfigMap, name string, expected bool) {
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: name, Namespace: "ns1"}, obj))
+		assert.Equal(t, expected, obj.Labels[model.ExtConfigSyncLabel] != "", name)
+		assert.Equal(t, expected, obj.Annotations[model.BackstageNameAnnotation] != "", name)
+		// the other labels are left as they were
+		assert.Equal(t, "my", obj.Labels["app"])
+	}
+
+	// the objects no longer referenced are cleaned up, the finalizer is kept for the referenced one
+	assert.NoError(t, r.cleanupExtConfigMarkers(ctx, bs))
+	assert.True(t, controllerutil.ContainsFinalizer(bs, ExtConfigMarkersFinalizer))
+	assertMarked(&corev1.ConfigMap{}, "app-config", true)
+	assertMarked(&corev1.ConfigMap{}, "unreferenced", false)
+	assertMarked(&corev1.ConfigMap{}, "other", true)
+	secret := &corev1.Secret{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: "secret", Namespace: "ns1"}, secret))
+	assert.NotContains(t, secret.Labels, model.ExtConfigSyncLabel)
+	assert.NotContains(t, secret.Annotations, model.BackstageNameAnnotation)
+
+	// the Backstage is deleted
+	assert.NoError(t, r.finalizeExtConfigMarkers(ctx, bs))
+	assert.False(t, controllerutil.ContainsFinalizer(bs, ExtConfigMarkersFinalizer))
+	assertMarked(&corev1.ConfigMap{}, "app-config", false)
+	assertMarked(&corev1.ConfigMap{}, "other", true)
+
+	// no finalizer without marked objects
+	assert.NoError(t, r.cleanupExtConfigMarkers(ctx, bs))
+	assert.False(t, controllerutil.ContainsFinalizer(bs, ExtConfigMarkersFinalizer))
+}
@@ -9,6 +9,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,6 +35,9 @@ type NameKind struct {
 }
 
 func kind(obj runtime.Object) string {
+	if u, ok := obj.(*unstructured.Unstructured); ok && u.GetKind() != "" {
+		return u.GetKind()
+	}
 	str := reflect.TypeOf(obj).String()
 	return str[strings.LastIndex(str, ".")+1:]
 	// return reflect.TypeOf(obj).String()
@@ -117,6 +121,19 @@ func (m MockClient) Patch(_ context.Context, obj client.Object, patch client.Pat
 		}
 	}
 
+	// status is a subresource, applying the object does not change it
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		if stored := m.objects[NameKind{Name: obj.GetName(), Kind: objKind}]; stored != nil {
+			existing := &unstructured.Unstructured{}
+			if err := json.Unmarshal(stored, existing); err != nil {
+				return err
+			}
+			if status, found := existing.Object["status"]; found {
+				u.Object["status"] = status
+			}
+		}
+	}
+
 	dat, err := json.Marshal(obj)
 	if err != nil {
 		return err
@@ -154,6 +171,14 @@ func (m MockClient) GroupVersionKindFor(_ runtime.Object) (schema.GroupVersionKi
 	panic(implementMe)
 }
 
-func (m MockClient) IsObjectNamespaced(_ runtime.Object) (bool, error) {
-	panic(implementMe)
+// clusterScopedKinds are the kinds the mock client treats as cluster-scoped
+var clusterScopedKinds = map[string]bool{
+	"CustomResourceDefinition": true,
+	"ClusterRole":              true,
+	"ClusterRoleBinding":       true,
+	"Namespace":                true,
+}
+
+func (m MockClient) IsObjectNamespaced(obj runtime.Object) (bool, error) {
+	return !clusterScopedKinds[kind(obj)], nil
 }
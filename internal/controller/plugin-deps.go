@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/redhat-developer/rhdh-operator/pkg/model"
 
@@ -12,43 +13,392 @@ import (
 
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func (r *BackstageReconciler) applyPluginDeps(ctx context.Context, backstage api.Backstage, bsModel *model.BackstageModel) error {
+// pluginDepsRequeueInterval is how often the plugin dependencies are rechecked
+// while some of them are pending or not ready
+const pluginDepsRequeueInterval = 15 * time.Second
 
+// PluginDepsFinalizer makes sure the shared plugin dependencies are released when the Backstage is deleted
+const PluginDepsFinalizer = "rhdh.redhat.com/plugin-deps"
+
+// readyConditionTypes are the status condition types checked, in order, to decide whether an applied object is ready
+var readyConditionTypes = []string{"Ready", "Available", "Established", "Succeeded", "Complete"}
+
+// applyPluginDeps applies the dependencies of the enabled plugins in order, deletes the objects
+// of the dependencies which are no longer referenced and reports the state of every dependency
+// in the Backstage status. It returns true if some dependencies are pending or not ready yet.
+func (r *BackstageReconciler) applyPluginDeps(ctx context.Context, backstage *api.Backstage, bsModel *model.BackstageModel) (bool, error) {
+
+	var deps []model.PluginDep
+	if obj := bsModel.GetRuntimeObject(model.DynamicPluginsKey); obj != nil {
+		var err error
+		deps, err = model.GetPluginDeps(*backstage, bsModel.ExternalConfig.UserFlavours, *obj.(*model.DynamicPlugins), r.Platform, r.Scheme)
+		if err != nil {
+			return false, fmt.Errorf("failed to get plugin dependencies: %w", err)
+		}
+	}
+
+	statuses := make([]api.PluginDependencyStatus, 0, len(deps))
+	for _, dep := range deps {
+		statuses = append(statuses, r.applyPluginDep(ctx, *backstage, dep))
+	}
+	statuses = append(statuses, r.cleanupPluginDeps(ctx, *backstage, backstage.Status.PluginDependencies, deps)...)
+
+	var errs []error
+	requeue := false
+	for _, s := range statuses {
+		switch s.State {
+		case api.PluginDependencyStateError:
+			errs = append(errs, fmt.Errorf("plugin dependency %s: %s", s.Ref, s.Message))
+		case api.PluginDependencyStatePending, api.PluginDependencyStateApplied:
+			requeue = true
+		}
+	}
+
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	if err := r.updatePluginDepsFinalizer(ctx, backstage, statuses); err != nil {
+		errs = append(errs, err)
+	}
+	backstage.Status.PluginDependencies = statuses
+
+	if len(errs) > 0 {
+		return requeue, combineErrors(errs)
+	}
+	return requeue, nil
+}
+
+// applyPluginDep applies the plugin dependency for the Backstage instance and reports its state
+func (r *BackstageReconciler) applyPluginDep(ctx context.Context, backstage api.Backstage, dep model.PluginDep) api.PluginDependencyStatus {
+	// every instance applies shared objects with its own field manager, so the owner labels
+	// of the other instances are not removed
+	fieldManager := BackstageFieldManager
+	if dep.Metadata.Shared {
+		fieldManager = BackstageFieldManager + "-" + strings.TrimPrefix(model.PluginDepOwnerLabel(backstage), model.PluginDepOwnerLabelPrefix)
+	}
+	return applyDependency(ctx, r.Client, dep, fieldManager)
+}
+
+// applyDependency applies the dependency objects, CRDs first, and reports the state of the dependency.
+// The other objects are applied only after the required and the applied CRDs are established.
+// Cluster-scoped objects are allowed for shared dependencies only.
+func applyDependency(ctx context.Context, c client.Client, dep model.PluginDep, fieldManager string) api.PluginDependencyStatus {
 	lg := log.FromContext(ctx)
 
-	obj := bsModel.GetRuntimeObject(model.DynamicPluginsKey)
-	if obj == nil {
+	status := api.PluginDependencyStatus{Ref: dep.Ref, Shared: dep.Metadata.Shared}
+	for _, obj := range dep.Objects {
+		// the scope of custom resources is not known until their CRD is installed
+		if namespaced, err := c.IsObjectNamespaced(obj); err == nil && !namespaced {
+			if !dep.Metadata.Shared {
+				status.State = api.PluginDependencyStateError
+				status.Message = fmt.Sprintf("cluster-scoped %s %s requires the dependency to be shared", obj.GetKind(), obj.GetName())
+				return status
+			}
+			obj.SetNamespace("")
+		}
+		status.Objects = append(status.Objects, dependencyObject(obj))
+	}
+
+	var crds, others []*unstructured.Unstructured
+	for _, obj := range dep.Objects {
+		if obj.GroupVersionKind().GroupKind() == crdGVK.GroupKind() {
+			crds = append(crds, obj)
+		} else {
+			others = append(others, obj)
+		}
+	}
+
+	apply := func(objs []*unstructured.Unstructured) error {
+		for _, obj := range objs {
+			// Apply the unstructured object
+			lg.V(1).Info("apply plugin dependency: ", "ref", dep.Ref, "name", obj.GetName(), "kind", obj.GetKind(), "namespace", obj.GetNamespace())
+
+			if err := c.Patch(ctx, obj, client.Apply, &client.PatchOptions{FieldManager: fieldManager, Force: ptr.To(true)}); err != nil { //nolint:staticcheck // SA1019: client.Apply is deprecated: Further investigation needed
+				return fmt.Errorf("failed to apply %s %s: %w", obj.GetKind(), obj.GetName(), err)
+			}
+		}
 		return nil
 	}
-	plugins := *obj.(*model.DynamicPlugins)
 
-	objects, err := model.GetPluginDeps(backstage, plugins, r.Scheme)
-	if err != nil {
-		return fmt.Errorf("failed to get plugin dependencies: %w", err)
+	requiredCRDs := append([]string{}, dep.Metadata.RequiredCRDs...)
+	for _, crd := range crds {
+		requiredCRDs = append(requiredCRDs, crd.GetName())
+	}
+	if err := apply(crds); err != nil {
+		status.State, status.Message = api.PluginDependencyStateError, err.Error()
+		return status
+	}
+	if pending, err := checkRequiredCRDs(ctx, c, requiredCRDs); err != nil {
+		status.State, status.Message = api.PluginDependencyStateError, err.Error()
+		return status
+	} else if len(pending) > 0 {
+		status.State = api.PluginDependencyStatePending
+		status.Message = fmt.Sprintf("waiting for CRDs to be established: %s", strings.Join(pending, ", "))
+		return status
+	}
+	if err := apply(others); err != nil {
+		status.State, status.Message = api.PluginDependencyStateError, err.Error()
+		return status
 	}
 
-	// Process the objects as needed
-	var errs []error
-	for _, obj := range objects {
-		// Apply the unstructured object
-		lg.V(1).Info("apply plugin dependency: ", "name", obj.GetName(), "kind", obj.GetKind(), "namespace", obj.GetNamespace())
+	// objects are updated with the applied state, including status
+	if notReady := notReadyObjects(ctx, c, dep.Objects); len(notReady) > 0 {
+		status.State, status.Message = api.PluginDependencyStateApplied, "not ready: "+strings.Join(notReady, "; ")
+		return status
+	}
+	status.State = api.PluginDependencyStateReady
+	return status
+}
 
-		if err = r.Patch(ctx, obj, client.Apply, &client.PatchOptions{FieldManager: BackstageFieldManager, Force: ptr.To(true)}); err != nil { //nolint:staticcheck // SA1019: client.Apply is deprecated: Further investigation needed
-			errs = append(errs, err)
+// notReadyObjects returns the objects, holding their state in the cluster, which are not ready, with the reason
+func notReadyObjects(ctx context.Context, c client.Reader, objs []*unstructured.Unstructured) []string {
+	var notReady []string
+	for _, obj := range objs {
+		ready, msg := dependencyObjectReady(obj)
+		if ready && obj.GroupVersionKind().GroupKind() == subscriptionGVK.GroupKind() {
+			ready, msg = subscriptionReady(ctx, c, obj)
+		}
+		if !ready {
+			notReady = append(notReady, fmt.Sprintf("%s %s: %s", obj.GetKind(), obj.GetName(), msg))
 		}
 	}
+	return notReady
+}
 
-	if len(errs) > 0 {
-		return combineErrors(errs)
+// checkRequiredCRDs checks that the CRDs are installed and returns the ones which are not established yet
+func checkRequiredCRDs(ctx context.Context, c client.Reader, names []string) ([]string, error) {
+	var pending []string
+	for _, name := range names {
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := c.Get(ctx, client.ObjectKey{Name: name}, crd); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("required CRD %s is not installed", name)
+			}
+			return nil, fmt.Errorf("failed to get required CRD %s: %w", name, err)
+		}
+		established := false
+		for _, cond := range crd.Status.Conditions {
+			if cond.Type == apiextensionsv1.Established && cond.Status == apiextensionsv1.ConditionTrue {
+				established = true
+			}
+		}
+		if !established {
+			pending = append(pending, name)
+		}
+	}
+	return pending, nil
+}
+
+// subscriptionReady checks that the Operator installed with the OLM Subscription succeeded,
+// i.e. the ClusterServiceVersion the Subscription installed is in the Succeeded phase
+func subscriptionReady(ctx context.Context, c client.Reader, sub *unstructured.Unstructured) (bool, string) {
+	csvName, _, _ := unstructured.NestedString(sub.Object, "status", "installedCSV")
+	if csvName == "" {
+		return false, "waiting for the operator to be installed"
+	}
+	csv := &unstructured.Unstructured{}
+	csv.SetGroupVersionKind(csvGVK)
+	if err := c.Get(ctx, client.ObjectKey{Name: csvName, Namespace: sub.GetNamespace()}, csv); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, fmt.Sprintf("waiting for ClusterServiceVersion %s", csvName)
+		}
+		return false, fmt.Sprintf("failed to get ClusterServiceVersion %s: %s", csvName, err)
+	}
+	if phase, _, _ := unstructured.NestedString(csv.Object, "status", "phase"); phase != "Succeeded" {
+		return false, fmt.Sprintf("ClusterServiceVersion %s is in phase %q", csvName, phase)
 	}
+	return true, ""
+}
 
+// cleanupPluginDeps deletes the objects applied for the plugin dependencies before (as reported in the status)
+// which are not part of the current dependencies. Objects which fail to be deleted are reported
+// with the Error state, so the deletion is retried.
+func (r *BackstageReconciler) cleanupPluginDeps(ctx context.Context, backstage api.Backstage, previous []api.PluginDependencyStatus, deps []model.PluginDep) []api.PluginDependencyStatus {
+	lg := log.FromContext(ctx)
+
+	current := map[api.PluginDependencyObject]bool{}
+	for _, dep := range deps {
+		for _, obj := range dep.Objects {
+			current[dependencyObject(obj)] = true
+		}
+	}
+
+	var failed []api.PluginDependencyStatus
+	for _, prev := range previous {
+		var remaining []api.PluginDependencyObject
+		var errs []string
+		for _, o := range prev.Objects {
+			if current[o] {
+				continue
+			}
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion(o.APIVersion)
+			obj.SetKind(o.Kind)
+			if err := r.Get(ctx, client.ObjectKey{Name: o.Name, Namespace: o.Namespace}, obj); err != nil {
+				if !apierrors.IsNotFound(err) {
+					remaining = append(remaining, o)
+					errs = append(errs, err.Error())
+				}
+				continue
+			}
+			if _, shared := obj.GetLabels()[model.SharedPluginDepLabel]; shared {
+				if err := r.releaseSharedPluginDep(ctx, backstage, obj); err != nil {
+					remaining = append(remaining, o)
+					errs = append(errs, err.Error())
+				}
+				continue
+			}
+			// do not touch objects which are not owned by this Backstage anymore
+			if !metav1.IsControlledBy(obj, &backstage) {
+				continue
+			}
+			lg.V(1).Info("delete plugin dependency: ", "ref", prev.Ref, "name", o.Name, "kind", o.Kind, "namespace", o.Namespace)
+			if err := r.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+				remaining = append(remaining, o)
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(remaining) > 0 {
+			failed = append(failed, api.PluginDependencyStatus{
+				Ref:     prev.Ref,
+				Shared:  prev.Shared,
+				State:   api.PluginDependencyStateError,
+				Message: fmt.Sprintf("failed to delete objects of the dependency which is no longer referenced: %s", strings.Join(errs, "; ")),
+				Objects: remaining,
+			})
+		}
+	}
+	return failed
+}
+
+// releaseSharedPluginDep removes the owner label of the Backstage from the shared dependency object
+// and deletes the object if no other Backstage instance uses it
+func (r *BackstageReconciler) releaseSharedPluginDep(ctx context.Context, backstage api.Backstage, obj *unstructured.Unstructured) error {
+	lg := log.FromContext(ctx)
+
+	labels := obj.GetLabels()
+	ownerLabel := model.PluginDepOwnerLabel(backstage)
+	if _, ok := labels[ownerLabel]; !ok {
+		return nil
+	}
+	delete(labels, ownerLabel)
+
+	for l := range labels {
+		if strings.HasPrefix(l, model.PluginDepOwnerLabelPrefix) {
+			// still used by other instances
+			obj.SetLabels(labels)
+			lg.V(1).Info("release shared plugin dependency: ", "name", obj.GetName(), "kind", obj.GetKind(), "namespace", obj.GetNamespace())
+			return r.Update(ctx, obj)
+		}
+	}
+
+	// the last user, delete unless it was modified in the meantime (e.g. another instance started using it)
+	lg.V(1).Info("delete shared plugin dependency: ", "name", obj.GetName(), "kind", obj.GetKind(), "namespace", obj.GetNamespace())
+	rv := obj.GetResourceVersion()
+	if err := r.Delete(ctx, obj, client.Preconditions{ResourceVersion: &rv}); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
 	return nil
 }
 
+// updatePluginDepsFinalizer adds the finalizer while the Backstage uses shared plugin dependencies
+// and removes it otherwise
+func (r *BackstageReconciler) updatePluginDepsFinalizer(ctx context.Context, backstage *api.Backstage, statuses []api.PluginDependencyStatus) error {
+	shared := false
+	for _, s := range statuses {
+		shared = shared || s.Shared
+	}
+
+	var changed bool
+	if shared {
+		changed = controllerutil.AddFinalizer(backstage, PluginDepsFinalizer)
+	} else {
+		changed = controllerutil.RemoveFinalizer(backstage, PluginDepsFinalizer)
+	}
+	if !changed {
+		return nil
+	}
+
+	// Update returns the stored object, keep the status updated during this reconciliation
+	status := backstage.Status.DeepCopy()
+	if err := r.Update(ctx, backstage); err != nil {
+		return fmt.Errorf("failed to update plugin dependencies finalizer: %w", err)
+	}
+	backstage.Status = *status
+	return nil
+}
+
+// finalizePluginDeps releases the plugin dependencies of the Backstage being deleted
+// and removes the finalizer once all of them are released
+func (r *BackstageReconciler) finalizePluginDeps(ctx context.Context, backstage *api.Backstage) error {
+	if !controllerutil.ContainsFinalizer(backstage, PluginDepsFinalizer) {
+		return nil
+	}
+	if failed := r.cleanupPluginDeps(ctx, *backstage, backstage.Status.PluginDependencies, nil); len(failed) > 0 {
+		var errs []error
+		for _, s := range failed {
+			errs = append(errs, fmt.Errorf("plugin dependency %s: %s", s.Ref, s.Message))
+		}
+		return combineErrors(errs)
+	}
+	controllerutil.RemoveFinalizer(backstage, PluginDepsFinalizer)
+	return r.Update(ctx, backstage)
+}
+
+// dependencyObjectReady checks the well-known status conditions of the applied object.
+// Objects without status conditions (e.g. ConfigMaps, Tekton Tasks) are considered ready once applied.
+func dependencyObjectReady(obj *unstructured.Unstructured) (bool, string) {
+	conditions, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil || !found {
+		return true, ""
+	}
+	byType := map[string]map[string]interface{}{}
+	for _, c := range conditions {
+		if cond, ok := c.(map[string]interface{}); ok {
+			if t, ok := cond["type"].(string); ok {
+				byType[t] = cond
+			}
+		}
+	}
+	if cond, ok := byType["Failed"]; ok && cond["status"] == "True" {
+		return false, fmt.Sprintf("Failed: %v", cond["message"])
+	}
+	for _, t := range readyConditionTypes {
+		if cond, ok := byType[t]; ok {
+			if cond["status"] == "True" {
+				return true, ""
+			}
+			return false, fmt.Sprintf("%s=%v: %v", t, cond["status"], cond["message"])
+		}
+	}
+	return true, ""
+}
+
+func dependencyObject(obj *unstructured.Unstructured) api.PluginDependencyObject {
+	return api.PluginDependencyObject{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+	}
+}
+
+var (
+	crdGVK          = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+	subscriptionGVK = schema.GroupVersionKind{Group: "operators.coreos.com", Version: "v1alpha1", Kind: "Subscription"}
+	csvGVK          = schema.GroupVersionKind{Group: "operators.coreos.com", Version: "v1alpha1", Kind: "ClusterServiceVersion"}
+)
+
 func combineErrors(errs []error) error {
 	var sb strings.Builder
 	for _, err := range errs {
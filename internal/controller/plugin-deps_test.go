@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+)
+
+func depObject(apiVersion, kind, name, namespace string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	return obj
+}
+
+func establishCRD(t *testing.T, r BackstageReconciler, name string) {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	assert.NoError(t, r.Get(context.TODO(), client.ObjectKey{Name: name}, crd))
+	crd.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{
+		{Type: apiextensionsv1.Established, Status: apiextensionsv1.ConditionTrue},
+	}
+	assert.NoError(t, r.Update(context.TODO(), crd))
+}
+
+func TestApplyPluginDepReady(t *testing.T) {
+	r := setupMonitorTestReconciler()
+
+	dep := model.PluginDep{
+		Ref: "dep",
+		Objects: []*unstructured.Unstructured{
+			depObject("v1", "ConfigMap", "cm", "ns"),
+			depObject("v1", "Secret", "secret", "ns"),
+		},
+	}
+
+	status := r.applyPluginDep(context.TODO(), api.Backstage{}, dep)
+	assert.Equal(t, api.PluginDependencyStateReady, status.State)
+	assert.Equal(t, []api.PluginDependencyObject{
+		{APIVersion: "v1", Kind: "ConfigMap", Name: "cm", Namespace: "ns"},
+		{APIVersion: "v1", Kind: "Secret", Name: "secret", Namespace: "ns"},
+	}, status.Objects)
+
+	cm := depObject("v1", "ConfigMap", "", "")
+	assert.NoError(t, r.Get(context.TODO(), types.NamespacedName{Name: "cm", Namespace: "ns"}, cm))
+}
+
+func TestApplyPluginDepWaitsForCRD(t *testing.T) {
+	r := setupMonitorTestReconciler()
+
+	dep := model.PluginDep{
+		Ref:      "dep",
+		Metadata: model.PluginDepMetadata{Shared: true},
+		Objects: []*unstructured.Unstructured{
+			depObject("example.com/v1", "Example", "example", "ns"),
+			depObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "examples.example.com", "ns"),
+		},
+	}
+
+	// the CRD is applied, the CR waits for the CRD to be established
+	status := r.applyPluginDep(context.TODO(), api.Backstage{}, dep)
+	assert.Equal(t, api.PluginDependencyStatePending, status.State)
+	assert.Contains(t, status.Message, "examples.example.com")
+	assert.Error(t, r.Get(context.TODO(), types.NamespacedName{Name: "example", Namespace: "ns"}, depObject("example.com/v1", "Example", "", "")))
+
+	establishCRD(t, r, "examples.example.com")
+
+	status = r.applyPluginDep(context.TODO(), api.Backstage{}, dep)
+	assert.Equal(t, api.PluginDependencyStateReady, status.State)
+	assert.True(t, status.Shared)
+	// cluster-scoped
+	assert.Equal(t, "", status.Objects[1].Namespace)
+	assert.NoError(t, r.Get(context.TODO(), types.NamespacedName{Name: "example", Namespace: "ns"}, depObject("example.com/v1", "Example", "", "")))
+}
+
+func TestApplyPluginDepRequiredCRDs(t *testing.T) {
+	r := setupMonitorTestReconciler()
+
+	dep := model.PluginDep{
+		Ref:      "dep",
+		Metadata: model.PluginDepMetadata{RequiredCRDs: []string{"sonataflowplatforms.sonataflow.org"}},
+		Objects:  []*unstructured.Unstructured{depObject("sonataflow.org/v1alpha08", "SonataFlowPlatform", "platform", "ns")},
+	}
+
+	status := r.applyPluginDep(context.TODO(), api.Backstage{}, dep)
+	assert.Equal(t, api.PluginDependencyStateError, status.State)
+	assert.Equal(t, "required CRD sonataflowplatforms.sonataflow.org is not installed", status.Message)
+
+	assert.NoError(t, r.Create(context.TODO(), &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "sonataflowplatforms.sonataflow.org"},
+	}))
+	status = r.applyPluginDep(context.TODO(), api.Backstage{}, dep)
+	assert.Equal(t, api.PluginDependencyStatePending, status.State)
+
+	establishCRD(t, r, "sonataflowplatforms.sonataflow.org")
+	status = r.applyPluginDep(context.TODO(), api.Backstage{}, dep)
+	assert.Equal(t, api.PluginDependencyStateReady, status.State)
+}
+
+func TestApplyPluginDepNotReady(t *testing.T) {
+	r := setupMonitorTestReconciler()
+
+	obj := depObject("sonataflow.org/v1alpha08", "SonataFlowPlatform", "platform", "ns")
+	assert.NoError(t, unstructured.SetNestedSlice(obj.Object, []interface{}{
+		map[string]interface{}{"type": "Succeed", "status": "True"},
+		map[string]interface{}{"type": "Ready", "status": "False", "message": "waiting for the build"},
+	}, "status", "conditions"))
+
+	status := r.applyPluginDep(context.TODO(), api.Backstage{}, model.PluginDep{Ref: "dep", Objects: []*unstructured.Unstructured{obj}})
+	assert.Equal(t, api.PluginDependencyStateApplied, status.State)
+	assert.Equal(t, "not ready: SonataFlowPlatform platform: Ready=False: waiting for the build", status.Message)
+}
+
+func TestDependencyObjectReady(t *testing.T) {
+	withConditions := func(conditions ...map[string]interface{}) *unstructured.Unstructured {
+		obj := depObject("v1", "Kind", "name", "ns")
+		var list []interface{}
+		for _, c := range conditions {
+			list = append(list, c)
+		}
+		_ = unstructured.SetNestedSlice(obj.Object, list, "status", "conditions")
+		return obj
+	}
+
+	ready, _ := dependencyObjectReady(depObject("v1", "ConfigMap", "cm", "ns"))
+	assert.True(t, ready)
+
+	ready, _ = dependencyObjectReady(withConditions(map[string]interface{}{"type": "Available", "status": "True"}))
+	assert.True(t, ready)
+
+	ready, msg := dependencyObjectReady(withConditions(map[string]interface{}{"type": "Complete", "status": "False", "message": "running"}))
+	assert.False(t, ready)
+	assert.Equal(t, "Complete=False: running", msg)
+
+	ready, msg = dependencyObjectReady(withConditions(
+		map[string]interface{}{"type": "Failed", "status": "True", "message": "backoff limit exceeded"},
+		map[string]interface{}{"type": "Complete", "status": "False"},
+	))
+	assert.False(t, ready)
+	assert.Equal(t, "Failed: backoff limit exceeded", msg)
+
+	// unknown condition types only
+	ready, _ = dependencyObjectReady(withConditions(map[string]interface{}{"type": "Progressing", "status": "False"}))
+	assert.True(t, ready)
+}
+
+func TestCleanupPluginDeps(t *testing.T) {
+	r := setupMonitorTestReconciler()
+	ctx := context.TODO()
+
+	bs := createTestBackstage("bs", "ns", false)
+	bs.UID = "bs-uid"
+
+	owned := depObject("v1", "ConfigMap", "owned", "ns")
+	assert.NoError(t, controllerutil.SetControllerReference(bs, owned, r.Scheme))
+	assert.NoError(t, r.Patch(ctx, owned, nil))
+	kept := depObject("v1", "Secret", "kept", "ns")
+	assert.NoError(t, controllerutil.SetControllerReference(bs, kept, r.Scheme))
+	assert.NoError(t, r.Patch(ctx, kept, nil))
+	notOwned := depObject("v1", "ServiceAccount", "not-owned", "ns")
+	assert.NoError(t, r.Patch(ctx, notOwned, nil))
+
+	previous := []api.PluginDependencyStatus{
+		{Ref: "removed", State: api.PluginDependencyStateReady, Objects: []api.PluginDependencyObject{
+			dependencyObject(owned), dependencyObject(notOwned),
+			{APIVersion: "v1", Kind: "ConfigMap", Name: "already-gone", Namespace: "ns"},
+		}},
+		{Ref: "still-referenced", State: api.PluginDependencyStateReady, Objects: []api.PluginDependencyObject{
+			dependencyObject(kept),
+		}},
+	}
+	deps := []model.PluginDep{{Ref: "still-referenced", Objects: []*unstructured.Unstructured{kept}}}
+
+	failed := r.cleanupPluginDeps(ctx, *bs, previous, deps)
+	assert.Empty(t, failed)
+
+	// the object of the dependency no longer referenced is deleted
+	assert.Error(t, r.Get(ctx, types.NamespacedName{Name: "owned", Namespace: "ns"}, depObject("v1", "ConfigMap", "", "")))
+	// the still referenced one is kept
+	assert.NoError(t, r.Get(ctx, types.NamespacedName{Name: "kept", Namespace: "ns"}, depObject("v1", "Secret", "", "")))
+	// the one not owned by the Backstage is not touched
+	assert.NoError(t, r.Get(ctx, types.NamespacedName{Name: "not-owned", Namespace: "ns"}, depObject("v1", "ServiceAccount", "", "")))
+}
+
+func TestApplyPluginDepClusterScopedNotShared(t *testing.T) {
+	r := setupMonitorTestReconciler()
+
+	dep := model.PluginDep{
+		Ref:     "dep",
+		Objects: []*unstructured.Unstructured{depObject("rbac.authorization.k8s.io/v1", "ClusterRole", "role", "ns")},
+	}
+	status := r.applyPluginDep(context.TODO(), api.Backstage{}, dep)
+	assert.Equal(t, api.PluginDependencyStateError, status.State)
+	assert.Equal(t, "cluster-scoped ClusterRole role requires the dependency to be shared", status.Message)
+}
+
+func TestReleaseSharedPluginDeps(t *testing.T) {
+	r := setupMonitorTestReconciler()
+	ctx := context.TODO()
+
+	bs1 := *createTestBackstage("bs1", "ns", false)
+	bs2 := *createTestBackstage("bs2", "ns", false)
+
+	task := depObject("tekton.dev/v1", "Task", "task", "ns")
+	task.SetLabels(map[string]string{
+		model.SharedPluginDepLabel:     "tekton",
+		model.PluginDepOwnerLabel(bs1): "true",
+		model.PluginDepOwnerLabel(bs2): "true",
+	})
+	assert.NoError(t, r.Patch(ctx, task, nil))
+
+	previous := []api.PluginDependencyStatus{{Ref: "tekton", Shared: true, State: api.PluginDependencyStateReady,
+		Objects: []api.PluginDependencyObject{dependencyObject(task)}}}
+
+	// bs1 stops using the task, it is still used by bs2
+	assert.Empty(t, r.cleanupPluginDeps(ctx, bs1, previous, nil))
+	stored := depObject("tekton.dev/v1", "Task", "", "")
+	assert.NoError(t, r.Get(ctx, types.NamespacedName{Name: "task", Namespace: "ns"}, stored))
+	assert.NotContains(t, stored.GetLabels(), model.PluginDepOwnerLabel(bs1))
+	assert.Contains(t, stored.GetLabels(), model.PluginDepOwnerLabel(bs2))
+
+	// the last user deletes it
+	assert.Empty(t, r.cleanupPluginDeps(ctx, bs2, previous, nil))
+	assert.Error(t, r.Get(ctx, types.NamespacedName{Name: "task", Namespace: "ns"}, depObject("tekton.dev/v1", "Task", "", "")))
+}
+
+func TestPluginDepsFinalizer(t *testing.T) {
+	r := setupMonitorTestReconciler()
+	ctx := context.TODO()
+
+	bs := createTestBackstage("bs", "ns", false)
+	assert.NoError(t, r.Create(ctx, bs))
+
+	role := depObject("rbac.authorization.k8s.io/v1", "ClusterRole", "role", "")
+	role.SetLabels(map[string]string{model.SharedPluginDepLabel: "dep", model.PluginDepOwnerLabel(*bs): "true"})
+	assert.NoError(t, r.Patch(ctx, role, nil))
+
+	statuses := []api.PluginDependencyStatus{{Ref: "dep", Shared: true, State: api.PluginDependencyStateReady,
+		Objects: []api.PluginDependencyObject{dependencyObject(role)}}}
+	bs.Status.PluginDependencies = statuses
+
+	// added while shared dependencies are used, the status is kept
+	assert.NoError(t, r.updatePluginDepsFinalizer(ctx, bs, statuses))
+	assert.Contains(t, bs.Finalizers, PluginDepsFinalizer)
+	assert.Equal(t, statuses, bs.Status.PluginDependencies)
+
+	// on deletion the shared dependencies are released and the finalizer removed
+	assert.NoError(t, r.finalizePluginDeps(ctx, bs))
+	assert.NotContains(t, bs.Finalizers, PluginDepsFinalizer)
+	assert.Error(t, r.Get(ctx, types.NamespacedName{Name: "role"}, depObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", "")))
+
+	// removed when no shared dependencies are used anymore
+	controllerutil.AddFinalizer(bs, PluginDepsFinalizer)
+	assert.NoError(t, r.updatePluginDepsFinalizer(ctx, bs, nil))
+	assert.NotContains(t, bs.Finalizers, PluginDepsFinalizer)
+}
+
+// TestCRDApplyPermissions checks the Operator role allows the server-side apply of the CRDs of the dependencies,
+// which the mock client does not check
+func TestCRDApplyPermissions(t *testing.T) {
+	content, err := os.ReadFile("../../config/rbac/role.yaml")
+	assert.NoError(t, err)
+	role := rbacv1.ClusterRole{}
+	assert.NoError(t, yaml.Unmarshal(content, &role))
+
+	var verbs []string
+	for _, rule := range role.Rules {
+		for _, res := range rule.Resources {
+			if res == "customresourcedefinitions" {
+				verbs = append(verbs, rule.Verbs...)
+			}
+		}
+	}
+	assert.Subset(t, verbs, []string{"create", "get", "patch"})
+}
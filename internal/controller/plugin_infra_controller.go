@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// PluginInfraFieldManager is the field manager the plugin infrastructure objects are applied with
+const PluginInfraFieldManager = BackstageFieldManager + "-plugin-infra"
+
+// pluginInfraRequest is the only request of the plugin infrastructure reconciler,
+// the infrastructure is reconciled for all Backstage instances at once
+var pluginInfraRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: "plugin-infra"}}
+
+// PluginInfraReconciler applies the plugin infrastructure components (e.g. Operators installed with OLM Subscriptions
+// and their CRs) required by the Backstage instances and tracks their readiness.
+// The components are shared by all the instances and are never deleted. Their state is not kept,
+// the BackstageReconciler reads it from the cluster.
+type PluginInfraReconciler struct {
+	client.Client
+	Platform platform.Platform
+	// UserFlavours loads the user flavours, which may require infrastructure as well
+	UserFlavours *UserFlavourLoader
+}
+
+// +kubebuilder:rbac:groups=rhdh.redhat.com,resources=backstages,verbs=get;list;watch
+
+func (r *PluginInfraReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	lg := log.FromContext(ctx)
+
+	var list api.BackstageList
+	if err := r.List(ctx, &list); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list backstages: %w", err)
+	}
+
+	userFlavours, err := r.UserFlavours.Load(ctx)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to load user flavours: %w", err)
+	}
+
+	dir := model.PluginInfraDir()
+	p := model.PluginDepsPlatform{Name: r.Platform.Name, IsOpenShift: r.Platform.IsOpenshift()}
+
+	// components required by instances with invalid requirements are skipped,
+	// the error is reported in the status of the instance by the BackstageReconciler
+	required := map[string]bool{}
+	for _, bs := range list.Items {
+		if !bs.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		names, err := model.RequiredInfrastructure(bs.Spec, userFlavours)
+		if err == nil {
+			_, err = model.ReadPluginInfra(dir, names, p)
+		}
+		if err != nil {
+			lg.Info("skipping plugin infrastructure of invalid backstage", "backstage", bs.Namespace+"/"+bs.Name, "error", err.Error())
+			continue
+		}
+		for _, name := range names {
+			required[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(required))
+	for name := range required {
+		names = append(names, name)
+	}
+	components, err := model.ReadPluginInfra(dir, names, p)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to read plugin infrastructure: %w", err)
+	}
+
+	// the components are ordered, the ones they require are applied first in this pass
+	statuses := map[string]api.PluginDependencyStatus{}
+	for _, c := range components {
+		statuses[c.Name] = api.PluginDependencyStatus{Ref: c.Name, Shared: true, State: api.PluginDependencyStatePending}
+	}
+
+	requeue := false
+	for _, c := range components {
+		status := r.applyComponent(ctx, c, statuses)
+		statuses[c.Name] = status
+		if status.State != api.PluginDependencyStateReady {
+			lg.V(1).Info("plugin infrastructure component is not ready", "component", c.Name, "state", status.State, "message", status.Message)
+			requeue = true
+		}
+	}
+
+	if requeue {
+		return ctrl.Result{RequeueAfter: pluginDepsRequeueInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// applyComponent applies the component once the components it requires are ready, as reported in statuses.
+// Required components which do not apply to the platform (not in statuses) are not waited for.
+func (r *PluginInfraReconciler) applyComponent(ctx context.Context, c model.PluginInfraComponent, statuses map[string]api.PluginDependencyStatus) api.PluginDependencyStatus {
+	var waiting []string
+	for _, req := range c.Requires {
+		if s, ok := statuses[req]; ok && s.State != api.PluginDependencyStateReady {
+			waiting = append(waiting, req)
+		}
+	}
+	if len(waiting) > 0 {
+		return api.PluginDependencyStatus{
+			Ref:     c.Name,
+			Shared:  true,
+			State:   api.PluginDependencyStatePending,
+			Message: fmt.Sprintf("waiting for required components: %s", strings.Join(waiting, ", ")),
+		}
+	}
+
+	dep := model.PluginDep{
+		Ref:      c.Name,
+		Metadata: model.PluginDepMetadata{RequiredCRDs: c.RequiredCRDs, Shared: true},
+		Objects:  c.Objects,
+	}
+	return applyDependency(ctx, r.Client, dep, PluginInfraFieldManager)
+}
+
+// componentStatus reads the state of the component from the cluster, without applying it
+func componentStatus(ctx context.Context, c client.Client, comp model.PluginInfraComponent) api.PluginDependencyStatus {
+	status := api.PluginDependencyStatus{Ref: comp.Name, Shared: true}
+	var notApplied []string
+	objs := make([]*unstructured.Unstructured, 0, len(comp.Objects))
+	for _, obj := range comp.Objects {
+		key := client.ObjectKeyFromObject(obj)
+		if namespaced, err := c.IsObjectNamespaced(obj); err == nil && !namespaced {
+			key.Namespace = ""
+		}
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := c.Get(ctx, key, current); err != nil {
+			// the CRDs of the component objects may not be installed yet
+			if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				notApplied = append(notApplied, fmt.Sprintf("%s %s", obj.GetKind(), obj.GetName()))
+				continue
+			}
+			status.State, status.Message = api.PluginDependencyStateError, fmt.Sprintf("failed to get %s %s: %s", obj.GetKind(), obj.GetName(), err)
+			return status
+		}
+		objs = append(objs, current)
+	}
+	if len(notApplied) > 0 {
+		status.State, status.Message = api.PluginDependencyStatePending, "not applied yet: "+strings.Join(notApplied, ", ")
+		return status
+	}
+	if notReady := notReadyObjects(ctx, c, objs); len(notReady) > 0 {
+		status.State, status.Message = api.PluginDependencyStateApplied, "not ready: "+strings.Join(notReady, "; ")
+		return status
+	}
+	status.State = api.PluginDependencyStateReady
+	return status
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// Any change of the spec of a Backstage instance, its creation or deletion,
+// or a change of the user flavours triggers the reconciliation of the whole infrastructure.
+func (r *PluginInfraReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	infraRequest := func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{pluginInfraRequest}
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named("plugin-infra").
+		Watches(&api.Backstage{}, handler.EnqueueRequestsFromMapFunc(infraRequest),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	if r.UserFlavours != nil {
+		b.WatchesRawSource(r.UserFlavours.flavourSource(infraRequest))
+	}
+	return b.Complete(r)
+}
+
+// checkPluginInfra checks that the plugin infrastructure components the Backstage requires are ready in the cluster
+// and reports it with the InfrastructureReady condition. It returns true if some of them are not ready yet.
+func (r *BackstageReconciler) checkPluginInfra(ctx context.Context, backstage *api.Backstage, userFlavours model.UserFlavours) (bool, error) {
+	if !r.PluginInfra {
+		meta.RemoveStatusCondition(&backstage.Status.Conditions, string(api.BackstageConditionTypeInfrastructureReady))
+		return false, nil
+	}
+
+	names, err := model.RequiredInfrastructure(backstage.Spec, userFlavours)
+	if err != nil {
+		return false, err
+	}
+	p := model.PluginDepsPlatform{Name: r.Platform.Name, IsOpenShift: r.Platform.IsOpenshift()}
+	components, err := model.ReadPluginInfra(model.PluginInfraDir(), names, p)
+	if err != nil {
+		return false, err
+	}
+	if len(components) == 0 {
+		meta.RemoveStatusCondition(&backstage.Status.Conditions, string(api.BackstageConditionTypeInfrastructureReady))
+		return false, nil
+	}
+
+	var notReady []string
+	for _, c := range components {
+		if s := componentStatus(ctx, r.Client, c); s.State != api.PluginDependencyStateReady {
+			notReady = append(notReady, fmt.Sprintf("%s: %s %s", c.Name, s.State, s.Message))
+		}
+	}
+	if len(notReady) > 0 {
+		setStatusCondition(backstage, api.BackstageConditionTypeInfrastructureReady, metav1.ConditionFalse, api.BackstageConditionReasonInfrastructureNotReady,
+			"plugin infrastructure is not ready: "+strings.Join(notReady, "; "))
+		return true, nil
+	}
+	setStatusCondition(backstage, api.BackstageConditionTypeInfrastructureReady, metav1.ConditionTrue, api.BackstageConditionReasonInfrastructureReady,
+		fmt.Sprintf("plugin infrastructure is ready: %s", strings.Join(names, ", ")))
+	return false, nil
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+)
+
+func TestSubscriptionReady(t *testing.T) {
+	r := setupMonitorTestReconciler()
+
+	sub := depObject("operators.coreos.com/v1alpha1", "Subscription", "logic-operator", "openshift-serverless-logic")
+	ready, msg := subscriptionReady(context.TODO(), r.Client, sub)
+	assert.False(t, ready)
+	assert.Equal(t, "waiting for the operator to be installed", msg)
+
+	assert.NoError(t, unstructured.SetNestedField(sub.Object, "logic-operator.v1.36.0", "status", "installedCSV"))
+	ready, msg = subscriptionReady(context.TODO(), r.Client, sub)
+	assert.False(t, ready)
+	assert.Equal(t, "waiting for ClusterServiceVersion logic-operator.v1.36.0", msg)
+
+	csv := depObject("operators.coreos.com/v1alpha1", "ClusterServiceVersion", "logic-operator.v1.36.0", "openshift-serverless-logic")
+	assert.NoError(t, unstructured.SetNestedField(csv.Object, "Installing", "status", "phase"))
+	assert.NoError(t, r.Create(context.TODO(), csv))
+	ready, msg = subscriptionReady(context.TODO(), r.Client, sub)
+	assert.False(t, ready)
+	assert.Equal(t, "ClusterServiceVersion logic-operator.v1.36.0 is in phase \"Installing\"", msg)
+
+	assert.NoError(t, unstructured.SetNestedField(csv.Object, "Succeeded", "status", "phase"))
+	assert.NoError(t, r.Update(context.TODO(), csv))
+	ready, _ = subscriptionReady(context.TODO(), r.Client, sub)
+	assert.True(t, ready)
+}
+
+func TestPluginInfraApplyComponent(t *testing.T) {
+	br := setupMonitorTestReconciler()
+	r := PluginInfraReconciler{Client: br.Client, Platform: platform.OpenShift}
+
+	first := model.PluginInfraComponent{
+		Name:    "first",
+		Objects: []*unstructured.Unstructured{depObject("v1", "Namespace", "first", "")},
+	}
+	second := model.PluginInfraComponent{
+		Name:     "second",
+		Requires: []string{"first", "other-platform"},
+		Objects:  []*unstructured.Unstructured{depObject("v1", "ConfigMap", "second", "first")},
+	}
+	statuses := map[string]api.PluginDependencyStatus{
+		"first":  {Ref: "first", State: api.PluginDependencyStatePending},
+		"second": {Ref: "second", State: api.PluginDependencyStatePending},
+	}
+
+	// the required component is not applied yet
+	status := r.applyComponent(context.TODO(), second, statuses)
+	assert.Equal(t, api.PluginDependencyStatePending, status.State)
+	assert.Equal(t, "waiting for required components: first", status.Message)
+
+	status = r.applyComponent(context.TODO(), first, statuses)
+	assert.Equal(t, api.PluginDependencyStateReady, status.State)
+	// cluster-scoped objects are allowed
+	assert.Equal(t, "", status.Objects[0].Namespace)
+	statuses["first"] = status
+
+	status = r.applyComponent(context.TODO(), second, statuses)
+	assert.Equal(t, api.PluginDependencyStateReady, status.State)
+}
+
+func TestCheckPluginInfra(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOCALBIN", dir)
+	t.Setenv("PLUGIN_INFRA_DIR_backstage", dir)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, model.PluginInfraIndexFile), []byte(`
+components:
+  - name: serverless
+    files: [serverless.yaml]
+    platforms: [OpenShift]
+`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "serverless.yaml"), []byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: openshift-serverless
+---
+apiVersion: operators.coreos.com/v1alpha1
+kind: Subscription
+metadata:
+  name: serverless-operator
+  namespace: openshift-serverless
+`), 0644))
+
+	r := setupMonitorTestReconciler()
+	r.Platform = platform.OpenShift
+	bs := createTestBackstage("bs", "ns", false)
+	bs.Spec.RequiredInfrastructure = []string{"serverless"}
+
+	// not managed by the Operator
+	pending, err := r.checkPluginInfra(context.TODO(), bs, nil)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	assert.Nil(t, meta.FindStatusCondition(bs.Status.Conditions, string(api.BackstageConditionTypeInfrastructureReady)))
+
+	// the state is read from the cluster
+	r.PluginInfra = true
+	pending, err = r.checkPluginInfra(context.TODO(), bs, nil)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	cond := meta.FindStatusCondition(bs.Status.Conditions, string(api.BackstageConditionTypeInfrastructureReady))
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, string(api.BackstageConditionReasonInfrastructureNotReady), cond.Reason)
+	assert.Equal(t, "plugin infrastructure is not ready: serverless: Pending not applied yet: Namespace openshift-serverless, Subscription serverless-operator", cond.Message)
+
+	assert.NoError(t, r.Create(context.TODO(), depObject("v1", "Namespace", "openshift-serverless", "")))
+	sub := depObject("operators.coreos.com/v1alpha1", "Subscription", "serverless-operator", "openshift-serverless")
+	assert.NoError(t, r.Create(context.TODO(), sub))
+	pending, err = r.checkPluginInfra(context.TODO(), bs, nil)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	cond = meta.FindStatusCondition(bs.Status.Conditions, string(api.BackstageConditionTypeInfrastructureReady))
+	assert.Equal(t, "plugin infrastructure is not ready: serverless: Applied not ready: Subscription serverless-operator: waiting for the operator to be installed", cond.Message)
+
+	assert.NoError(t, unstructured.SetNestedField(sub.Object, "serverless-operator.v1.36.0", "status", "installedCSV"))
+	assert.NoError(t, r.Update(context.TODO(), sub))
+	csv := depObject("operators.coreos.com/v1alpha1", "ClusterServiceVersion", "serverless-operator.v1.36.0", "openshift-serverless")
+	assert.NoError(t, unstructured.SetNestedField(csv.Object, "Succeeded", "status", "phase"))
+	assert.NoError(t, r.Create(context.TODO(), csv))
+	pending, err = r.checkPluginInfra(context.TODO(), bs, nil)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	cond = meta.FindStatusCondition(bs.Status.Conditions, string(api.BackstageConditionTypeInfrastructureReady))
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+
+	// the component does not apply to the platform
+	r.Platform = platform.Kubernetes
+	pending, err = r.checkPluginInfra(context.TODO(), bs, nil)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	assert.Nil(t, meta.FindStatusCondition(bs.Status.Conditions, string(api.BackstageConditionTypeInfrastructureReady)))
+
+	bs.Spec.RequiredInfrastructure = []string{"unknown"}
+	_, err = r.checkPluginInfra(context.TODO(), bs, nil)
+	assert.EqualError(t, err, "plugin infrastructure component unknown is not defined in components.yaml")
+}
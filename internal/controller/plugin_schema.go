@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+	"github.com/redhat-developer/rhdh-operator/pkg/oci"
+)
+
+// pluginConfigSchemaFiles are the self-contained configuration schemas the dynamic plugins export
+// saves in the backend and frontend plugin packages
+var pluginConfigSchemaFiles = []string{"dist/.config-schema.json", "dist-scalprum/.config-schema.json"}
+
+// pluginSchemaReader reads the configuration schema of the oci:// plugin packages, using the registry cache
+type pluginSchemaReader struct {
+	ctx       context.Context
+	cache     *catalogIndexCache
+	client    *oci.Client
+	clientErr error
+	credsKey  string
+}
+
+func (c *catalogIndexCache) pluginSchemaReader(ctx context.Context, dockerConfigJSON []byte) model.PluginSchemaReader {
+	client, err := oci.NewClient(c.httpClient, dockerConfigJSON)
+	return &pluginSchemaReader{
+		ctx:       ctx,
+		cache:     c,
+		client:    client,
+		clientErr: err,
+		credsKey:  fmt.Sprintf("%x", sha256.Sum256(dockerConfigJSON)),
+	}
+}
+
+// ReadConfigSchema reads the configuration schema of the oci://<image>!<plugin-path> packages.
+// The other packages, and the images with no plugin path which only the plugin installer resolves, are not read.
+func (r *pluginSchemaReader) ReadConfigSchema(pkg string) (map[string]interface{}, error) {
+	idx := strings.LastIndex(pkg, "!")
+	if !strings.HasPrefix(pkg, "oci://") || idx == -1 {
+		return nil, nil
+	}
+	if r.clientErr != nil {
+		return nil, r.clientErr
+	}
+	ref, err := oci.ParseReference(strings.TrimPrefix(pkg[:idx], "oci://"))
+	if err != nil {
+		return nil, err
+	}
+	digest := ref.Digest
+	if digest == "" {
+		if digest, err = r.cache.digest(r.ctx, r.client, r.credsKey, ref); err != nil {
+			return nil, fmt.Errorf("failed to resolve plugin image digest: %w", err)
+		}
+	}
+
+	for _, file := range pluginConfigSchemaFiles {
+		content, err := r.cache.readFile(r.ctx, r.client, r.credsKey, ref.Pinned(digest), path.Join(pkg[idx+1:], file))
+		if errors.Is(err, oci.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return parsePluginConfigSchema(content)
+	}
+	return nil, nil
+}
+
+// parsePluginConfigSchema parses either a JSON schema or a serialized Backstage config schema
+// ({"schemas": [{"path": ..., "value": <JSON schema>}]}), all the schemas of which apply
+func parsePluginConfigSchema(content string) (map[string]interface{}, error) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &schema); err != nil {
+		return nil, fmt.Errorf("invalid configuration schema: %w", err)
+	}
+	schemas, ok := schema["schemas"].([]interface{})
+	if !ok {
+		return schema, nil
+	}
+	allOf := []interface{}{}
+	for _, s := range schemas {
+		if entry, ok := s.(map[string]interface{}); ok && entry["value"] != nil {
+			allOf = append(allOf, entry["value"])
+		}
+	}
+	return map[string]interface{}{"allOf": allOf}, nil
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPluginConfigSchema(t *testing.T) {
+	manifestRequests := 0
+	srv, digest := imageRegistry(t, "rhdh/plugins", "1.0", map[string]string{
+		"backend-plugin/dist/.config-schema.json":           `{"schemas":[{"path":"config.d.ts","value":{"type":"object","properties":{"catalog":{"type":"object"}}}}],"backstageConfigSchemaVersion":1}`,
+		"frontend-plugin/dist-scalprum/.config-schema.json": `{"type":"object"}`,
+		"invalid-plugin/dist/.config-schema.json":           `{`,
+	}, &manifestRequests)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	reader := newCatalogIndexCache(srv.Client()).pluginSchemaReader(context.TODO(), nil)
+
+	schema, err := reader.ReadConfigSchema("oci://" + host + "/rhdh/plugins:1.0!backend-plugin")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"allOf": []interface{}{
+		map[string]interface{}{"type": "object", "properties": map[string]interface{}{"catalog": map[string]interface{}{"type": "object"}}},
+	}}, schema)
+
+	schema, err = reader.ReadConfigSchema("oci://" + host + "/rhdh/plugins@" + digest + "!frontend-plugin")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"type": "object"}, schema)
+
+	// the package provides no schema
+	schema, err = reader.ReadConfigSchema("oci://" + host + "/rhdh/plugins:1.0!other-plugin")
+	assert.NoError(t, err)
+	assert.Nil(t, schema)
+
+	_, err = reader.ReadConfigSchema("oci://" + host + "/rhdh/plugins:1.0!invalid-plugin")
+	assert.ErrorContains(t, err, "invalid configuration schema")
+
+	_, err = reader.ReadConfigSchema("oci://" + host + "/rhdh/plugins:2.0!backend-plugin")
+	assert.ErrorContains(t, err, "failed to resolve plugin image digest")
+
+	// the other packages and the images with no plugin path are not read
+	manifestRequests = 0
+	for _, pkg := range []string{"oci://" + host + "/rhdh/plugins:1.0", "@backstage/plugin-catalog@1.0.0", "./dynamic-plugins/dist/plugin"} {
+		schema, err = reader.ReadConfigSchema(pkg)
+		assert.NoError(t, err)
+		assert.Nil(t, schema)
+	}
+	assert.Equal(t, 0, manifestRequests)
+}
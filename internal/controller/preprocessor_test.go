@@ -2,17 +2,21 @@ package controller
 
 import (
 	"context"
-	"os"
-
 	"testing"
 
 	"github.com/redhat-developer/rhdh-operator/api"
 	"github.com/redhat-developer/rhdh-operator/pkg/model"
 
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func updateConfigMap(t *testing.T) BackstageReconciler {
@@ -71,18 +75,41 @@ func TestExtConfigChanged(t *testing.T) {
 	rc := updateConfigMap(t)
 	err := rc.Get(ctx, types.NamespacedName{Namespace: "ns1", Name: "cm1"}, &cm)
 	assert.NoError(t, err)
+	// the user's object is not modified
+	assert.Empty(t, cm.Labels)
+	assert.Empty(t, cm.Annotations)
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = api.AddToScheme(scheme)
+	bs := &api.Backstage{
+		ObjectMeta: metav1.ObjectMeta{Name: "bs1", Namespace: "ns1"},
+		Spec: api.BackstageSpec{Application: &api.Application{
+			AppConfig: &api.AppConfig{ConfigMaps: []api.FileObjectRef{{Name: "cm1"}}},
+		}},
+	}
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:        model.DeploymentName("bs1"),
+		Namespace:   "ns1",
+		Annotations: map[string]string{model.ExtConfigHashAnnotation: "old"},
+	}}
+	cm = corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm1", Namespace: "ns1"}, Data: map[string]string{"key": "value"}}
+	rc = BackstageReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).
+		WithIndex(&api.Backstage{}, extConfigRefIndex, func(o client.Object) []string {
+			return extConfigRefs(o.(*api.Backstage))
+		}).
+		WithObjects(bs, deploy, &cm).
+		Build()}
+
 	// true : Backstage will be reconciled
-	assert.Equal(t, "true", cm.Labels[model.ExtConfigSyncLabel])
+	assert.True(t, rc.extConfigChanged(ctx, *bs))
+	assert.Len(t, rc.requestsByExtConfig(ctx, "ConfigMap", &cm), 1)
 
-	err = os.Setenv(AutoSyncEnvVar, "false")
-	assert.NoError(t, err)
+	t.Setenv(AutoSyncEnvVar, "false")
 
-	rc = updateConfigMap(t)
-	err = rc.Get(ctx, types.NamespacedName{Namespace: "ns1", Name: "cm1"}, &cm)
-	assert.NoError(t, err)
 	// false : Backstage will not be reconciled
-	assert.Equal(t, "false", cm.Labels[model.ExtConfigSyncLabel])
-
+	assert.True(t, rc.extConfigChanged(ctx, *bs))
+	assert.Empty(t, rc.requestsByExtConfig(ctx, "ConfigMap", &cm))
 }
 
 // TestExtConfigChanged tests if concatData returns the same data when the order of the keys is different
@@ -97,3 +124,217 @@ func TestExtConcatData(t *testing.T) {
 	assert.Equal(t, data1, concatData(original, &cm))
 
 }
+
+func TestRegistryAuthSecretWatched(t *testing.T) {
+	ctx := context.TODO()
+
+	bs := api.Backstage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bs1",
+			Namespace: "ns1",
+		},
+		Spec: api.BackstageSpec{
+			Application: &api.Application{
+				DynamicPlugins: &api.DynamicPlugins{
+					RegistryAuth: &api.PluginRegistryAuth{DockerConfigSecret: "pull-secret"},
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")}}
+	secret.Name = "pull-secret"
+
+	rc := BackstageReconciler{
+		Client: NewMockClient(),
+	}
+	assert.NoError(t, rc.Create(ctx, &secret))
+
+	extConf, err := rc.preprocessSpec(ctx, bs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{corev1.DockerConfigJsonKey}, extConf.RegistryAuthSecretKeys["pull-secret"].All())
+	oldHash := extConf.WatchingHash
+
+	assert.NoError(t, rc.Get(ctx, types.NamespacedName{Namespace: "ns1", Name: "pull-secret"}, &secret))
+	secret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+	assert.NoError(t, rc.Update(ctx, &secret))
+
+	extConf, err = rc.preprocessSpec(ctx, bs)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldHash, extConf.WatchingHash)
+}
+
+func TestInlineAppConfigChanged(t *testing.T) {
+	ctx := context.TODO()
+
+	bs := api.Backstage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bs1",
+			Namespace: "ns1",
+		},
+		Spec: api.BackstageSpec{
+			Application: &api.Application{
+				AppConfig: &api.AppConfig{
+					Inline: &apiextensionsv1.JSON{Raw: []byte(`{"app":{"title":"a"}}`)},
+				},
+			},
+		},
+	}
+
+	rc := BackstageReconciler{
+		Client: NewMockClient(),
+	}
+
+	extConf, err := rc.preprocessSpec(ctx, bs)
+	assert.NoError(t, err)
+	oldHash := extConf.WatchingHash
+
+	bs.Spec.Application.AppConfig.Inline.Raw = []byte(`{"app":{"title":"b"}}`)
+	extConf, err = rc.preprocessSpec(ctx, bs)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldHash, extConf.WatchingHash)
+}
+
+func TestLiveReloadNotWatched(t *testing.T) {
+	ctx := context.TODO()
+
+	bs := api.Backstage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bs1",
+			Namespace: "ns1",
+		},
+		Spec: api.BackstageSpec{
+			Application: &api.Application{
+				AppConfig: &api.AppConfig{
+					ConfigMaps: []api.FileObjectRef{{Name: "app-config", ReloadStrategy: api.ReloadStrategyLive}},
+				},
+				ExtraFiles: &api.ExtraFiles{
+					Secrets: []api.FileObjectRef{{Name: "certs", Key: "tls.crt"}},
+				},
+			},
+		},
+	}
+
+	cm := corev1.ConfigMap{Data: map[string]string{"app-config.yaml": "app:"}}
+	cm.Name = "app-config"
+	secret := corev1.Secret{Data: map[string][]byte{"tls.crt": []byte("cert")}}
+	secret.Name = "certs"
+
+	rc := BackstageReconciler{
+		Client: NewMockClient(),
+	}
+	assert.NoError(t, rc.Create(ctx, &cm))
+	assert.NoError(t, rc.Create(ctx, &secret))
+
+	extConf, err := rc.preprocessSpec(ctx, bs)
+	assert.NoError(t, err)
+	oldHash := extConf.WatchingHash
+
+	// the live app-config change does not restart the Pod
+	cm.Data["app-config.yaml"] = "app: {title: live}"
+	assert.NoError(t, rc.Update(ctx, &cm))
+	extConf, err = rc.preprocessSpec(ctx, bs)
+	assert.NoError(t, err)
+	assert.Equal(t, oldHash, extConf.WatchingHash)
+
+	// the Secret mounted with subPath does
+	secret.Data["tls.crt"] = []byte("new cert")
+	assert.NoError(t, rc.Update(ctx, &secret))
+	extConf, err = rc.preprocessSpec(ctx, bs)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldHash, extConf.WatchingHash)
+}
+
+func TestMissingReferencedKeys(t *testing.T) {
+	ctx := context.TODO()
+
+	bs := api.Backstage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bs1",
+			Namespace: "ns1",
+		},
+		Spec: api.BackstageSpec{
+			Application: &api.Application{
+				AppConfig: &api.AppConfig{
+					ConfigMaps: []api.FileObjectRef{{Name: "cm1", Key: "app-config.yaml"}},
+				},
+				ExtraFiles: &api.ExtraFiles{
+					Secrets: []api.FileObjectRef{{Name: "secret1", Key: "tls.crt"}},
+				},
+				ExtraEnvs: &api.ExtraEnvs{
+					ConfigMaps: []api.EnvObjectRef{{Name: "cm1", Key: "typo"}},
+					Secrets:    []api.EnvObjectRef{{Name: "secret1", Key: "TOKEN"}, {Name: "secret1", Key: "PASSWORD"}},
+				},
+			},
+		},
+	}
+
+	rc := BackstageReconciler{
+		Client: NewMockClient(),
+	}
+	assert.NoError(t, rc.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm1", Namespace: "ns1"},
+		Data:       map[string]string{"app-config.yaml": "app:"},
+	}))
+	assert.NoError(t, rc.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret1", Namespace: "ns1"},
+		Data:       map[string][]byte{"tls.crt": []byte("crt"), "TOKEN": []byte("token")},
+	}))
+
+	_, err := rc.preprocessSpec(ctx, bs)
+	assert.EqualError(t, err, "referenced keys not found: ConfigMap cm1 has no key typo; Secret secret1 has no key PASSWORD")
+
+	bs.Spec.Application.ExtraEnvs.ConfigMaps[0].Key = "app-config.yaml"
+	bs.Spec.Application.ExtraEnvs.Secrets = bs.Spec.Application.ExtraEnvs.Secrets[:1]
+	_, err = rc.preprocessSpec(ctx, bs)
+	assert.NoError(t, err)
+
+	// the keys of the items are checked too
+	bs.Spec.Application.ExtraFiles.Secrets = []api.FileObjectRef{{Name: "secret1", Items: []api.FileObjectItem{{Key: "tls.crt"}, {Key: "tls.key"}}}}
+	_, err = rc.preprocessSpec(ctx, bs)
+	assert.EqualError(t, err, "referenced keys not found: Secret secret1 has no key tls.key")
+
+	bs.Spec.Application.ExtraFiles.Secrets = nil
+	bs.Spec.Application.ExtraEnvs.Secrets = []api.EnvObjectRef{{Name: "secret1", Items: []api.EnvObjectItem{{Key: "TOKEN", As: "GITHUB_TOKEN"}, {Key: "CLIENT_ID"}}}}
+	_, err = rc.preprocessSpec(ctx, bs)
+	assert.EqualError(t, err, "referenced keys not found: Secret secret1 has no key CLIENT_ID")
+}
+
+func TestDirectoryMountWatched(t *testing.T) {
+	ctx := context.TODO()
+
+	rc := BackstageReconciler{
+		Client: NewMockClient(),
+	}
+	secret := corev1.Secret{Data: map[string][]byte{"tls.crt": []byte("cert")}}
+	secret.Name = "certs"
+	assert.NoError(t, rc.Create(ctx, &secret))
+
+	hashChanged := func(ref api.FileObjectRef) bool {
+		bs := api.Backstage{
+			ObjectMeta: metav1.ObjectMeta{Name: "bs1", Namespace: "ns1"},
+			Spec: api.BackstageSpec{Application: &api.Application{
+				ExtraFiles: &api.ExtraFiles{Secrets: []api.FileObjectRef{ref}},
+			}},
+		}
+		extConf, err := rc.preprocessSpec(ctx, bs)
+		assert.NoError(t, err)
+		oldHash := extConf.WatchingHash
+
+		assert.NoError(t, rc.Get(ctx, types.NamespacedName{Namespace: "ns1", Name: "certs"}, &secret))
+		secret.Data["tls.crt"] = append(secret.Data["tls.crt"], '!')
+		assert.NoError(t, rc.Update(ctx, &secret))
+
+		extConf, err = rc.preprocessSpec(ctx, bs)
+		assert.NoError(t, err)
+		return oldHash != extConf.WatchingHash
+	}
+
+	// the whole Secret mounted to the default directory
+	assert.True(t, hashChanged(api.FileObjectRef{Name: "certs"}))
+	// the items
+	assert.True(t, hashChanged(api.FileObjectRef{Name: "certs", MountPath: "/certs", Items: []api.FileObjectItem{{Key: "tls.crt"}}}))
+	// the whole Secret mounted to the mountPath restarts the Pod, unless it is reloaded live explicitly
+	assert.True(t, hashChanged(api.FileObjectRef{Name: "certs", MountPath: "/certs"}))
+	assert.False(t, hashChanged(api.FileObjectRef{Name: "certs", MountPath: "/certs", ReloadStrategy: api.ReloadStrategyLive}))
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+)
+
+// RenderEffectiveAppConfig computes the effective app-config of the Backstage custom resource among objects
+// the way the controller does, reading the ConfigMaps and Secrets it references from objects instead of the cluster
+func RenderEffectiveAppConfig(ctx context.Context, objects []client.Object, scheme *runtime.Scheme, plf platform.Platform) (string, error) {
+	mockClient := NewMockClient()
+	var backstage *api.Backstage
+	for _, obj := range objects {
+		if bs, ok := obj.(*api.Backstage); ok {
+			if backstage != nil {
+				return "", fmt.Errorf("multiple Backstage objects found: %s and %s", backstage.Name, bs.Name)
+			}
+			backstage = bs
+			continue
+		}
+		if err := mockClient.Create(ctx, obj); err != nil {
+			return "", fmt.Errorf("failed to add %s %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), err)
+		}
+	}
+	if backstage == nil {
+		return "", fmt.Errorf("no Backstage object found")
+	}
+
+	r := &BackstageReconciler{Client: mockClient, Scheme: scheme, Platform: plf}
+	externalConfig, err := r.preprocessSpec(ctx, *backstage)
+	if err != nil {
+		return "", fmt.Errorf("failed to preprocess backstage spec: %w", err)
+	}
+	if err := r.resolveImages(ctx, *backstage, &externalConfig); err != nil {
+		return "", fmt.Errorf("failed to resolve dynamic plugins images: %w", err)
+	}
+	bsModel, err := model.InitObjects(ctx, *backstage, externalConfig, plf, scheme)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize backstage model: %w", err)
+	}
+	appConfig, ok := bsModel.GetRuntimeObject(model.AppConfigKey).(*model.AppConfig)
+	if !ok {
+		return "", fmt.Errorf("no app-config found")
+	}
+	return appConfig.EffectiveConfig()
+}
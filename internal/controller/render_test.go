@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	openshift "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+func TestRenderEffectiveAppConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOCALBIN", dir)
+	defaultConfig, err := filepath.Abs("../../config/profile/backstage.io/default-config")
+	assert.NoError(t, err)
+	assert.NoError(t, os.Symlink(defaultConfig, filepath.Join(dir, "default-config")))
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = api.AddToScheme(scheme)
+	_ = openshift.Install(scheme)
+
+	objects, err := utils.ReadYamls([]byte(`
+apiVersion: rhdh.redhat.com/v1alpha5
+kind: Backstage
+metadata:
+  name: bs1
+  namespace: ns1
+spec:
+  database:
+    enableLocalDb: false
+  application:
+    appConfig:
+      configMaps:
+        - name: cm1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm1
+  namespace: ns1
+data:
+  app-config.yaml: |
+    backend:
+      auth:
+        keys:
+          - secret: my-secret
+`), nil, *scheme)
+	assert.NoError(t, err)
+
+	effective, err := RenderEffectiveAppConfig(context.TODO(), objects, scheme, platform.Kubernetes)
+	assert.NoError(t, err)
+	assert.Contains(t, effective, "# - /opt/app-root/src/app-config.yaml (cm1/app-config.yaml)")
+	assert.Contains(t, effective, "- secret: '********' # cm1/app-config.yaml")
+	assert.NotContains(t, effective, "my-secret")
+
+	// the referenced ConfigMap is required
+	_, err = RenderEffectiveAppConfig(context.TODO(), objects[:1], scheme, platform.Kubernetes)
+	assert.ErrorContains(t, err, "cm1")
+
+	_, err = RenderEffectiveAppConfig(context.TODO(), objects[1:], scheme, platform.Kubernetes)
+	assert.ErrorContains(t, err, "no Backstage object found")
+}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
-	"os"
+	"slices"
 	"sort"
-	"strconv"
+	"strings"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -16,10 +16,6 @@ import (
 
 	"github.com/redhat-developer/rhdh-operator/pkg/utils"
 
-	"k8s.io/client-go/util/retry"
-
-	"sigs.k8s.io/controller-runtime/pkg/log"
-
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/redhat-developer/rhdh-operator/api"
@@ -76,14 +72,23 @@ func (r *BackstageReconciler) preprocessSpec(ctx context.Context, backstage api.
 		bsSpec.Application = &api.Application{}
 	}
 
+	// keys referenced in the spec and missing in the objects
+	var missingKeys []string
+
 	// Process AppConfigs
 	if bsSpec.Application.AppConfig != nil {
 		for _, ac := range bsSpec.Application.AppConfig.ConfigMaps {
 			cm := &corev1.ConfigMap{Data: map[string]string{}}
-			if hashingData, err = r.addExtConfig(ctx, cm, backstage.Name, ac.Name, ns, addToWatch(ac), hashingData); err != nil {
+			if hashingData, err = r.addExtConfig(ctx, cm, ac.Name, ns, addToWatch(bsSpec, ac), hashingData); err != nil {
 				return result, err
 			}
 			result.AppConfigKeys[ac.Name] = utils.SortedKeys(cm.Data)
+			missingKeys = appendMissingFileKeys(missingKeys, "ConfigMap", ac, result.AppConfigKeys[ac.Name])
+			result.AppConfigData[ac.Name] = cm.Data
+		}
+		// the inline app-config is mounted with subPath, so it is hashed to restart the Pod on change
+		if bsSpec.Application.AppConfig.Inline != nil {
+			hashingData = append(hashingData, bsSpec.Application.AppConfig.Inline.Raw...)
 		}
 	}
 
@@ -91,10 +96,11 @@ func (r *BackstageReconciler) preprocessSpec(ctx context.Context, backstage api.
 	if bsSpec.Application.ExtraFiles != nil && bsSpec.Application.ExtraFiles.ConfigMaps != nil {
 		for _, ef := range bsSpec.Application.ExtraFiles.ConfigMaps {
 			cm := &corev1.ConfigMap{Data: map[string]string{}, BinaryData: map[string][]byte{}}
-			if hashingData, err = r.addExtConfig(ctx, cm, backstage.Name, ef.Name, ns, addToWatch(ef), hashingData); err != nil {
+			if hashingData, err = r.addExtConfig(ctx, cm, ef.Name, ns, addToWatch(bsSpec, ef), hashingData); err != nil {
 				return result, err
 			}
 			result.ExtraFileConfigMapKeys[ef.Name] = model.NewDataObjectKeys(cm.Data, cm.BinaryData)
+			missingKeys = appendMissingFileKeys(missingKeys, "ConfigMap", ef, result.ExtraFileConfigMapKeys[ef.Name].All())
 		}
 	}
 
@@ -102,10 +108,11 @@ func (r *BackstageReconciler) preprocessSpec(ctx context.Context, backstage api.
 	if bsSpec.Application.ExtraFiles != nil && bsSpec.Application.ExtraFiles.Secrets != nil {
 		for _, ef := range bsSpec.Application.ExtraFiles.Secrets {
 			secret := &corev1.Secret{Data: map[string][]byte{}, StringData: map[string]string{}}
-			if hashingData, err = r.addExtConfig(ctx, secret, backstage.Name, ef.Name, ns, addToWatch(ef), hashingData); err != nil {
+			if hashingData, err = r.addExtConfig(ctx, secret, ef.Name, ns, addToWatch(bsSpec, ef), hashingData); err != nil {
 				return result, err
 			}
 			result.ExtraFileSecretKeys[ef.Name] = model.NewDataObjectKeys(secret.StringData, secret.Data)
+			missingKeys = appendMissingFileKeys(missingKeys, "Secret", ef, result.ExtraFileSecretKeys[ef.Name].All())
 		}
 	}
 
@@ -113,10 +120,11 @@ func (r *BackstageReconciler) preprocessSpec(ctx context.Context, backstage api.
 	if bsSpec.Application.ExtraEnvs != nil && bsSpec.Application.ExtraEnvs.ConfigMaps != nil {
 		for _, ee := range bsSpec.Application.ExtraEnvs.ConfigMaps {
 			cm := &corev1.ConfigMap{Data: map[string]string{}, BinaryData: map[string][]byte{}}
-			if hashingData, err = r.addExtConfig(ctx, cm, backstage.Name, ee.Name, ns, true, hashingData); err != nil {
+			if hashingData, err = r.addExtConfig(ctx, cm, ee.Name, ns, true, hashingData); err != nil {
 				return result, err
 			}
 			result.ExtraEnvConfigMapKeys[ee.Name] = model.NewDataObjectKeys(cm.Data, cm.BinaryData)
+			missingKeys = appendMissingEnvKeys(missingKeys, "ConfigMap", ee, result.ExtraEnvConfigMapKeys[ee.Name].All())
 		}
 	}
 
@@ -124,11 +132,12 @@ func (r *BackstageReconciler) preprocessSpec(ctx context.Context, backstage api.
 	if bsSpec.Application.ExtraEnvs != nil && bsSpec.Application.ExtraEnvs.Secrets != nil {
 		for _, ee := range bsSpec.Application.ExtraEnvs.Secrets {
 			secret := &corev1.Secret{Data: map[string][]byte{}, StringData: map[string]string{}}
-			if hashingData, err = r.addExtConfig(ctx, secret, backstage.Name, ee.Name, ns, true, hashingData); err != nil {
+			if hashingData, err = r.addExtConfig(ctx, secret, ee.Name, ns, true, hashingData); err != nil {
 				return result, err
 			}
 			//result.ExtraEnvSecrets[secret.Name] = *secret
 			result.ExtraEnvSecretKeys[ee.Name] = model.NewDataObjectKeys(secret.StringData, secret.Data)
+			missingKeys = appendMissingEnvKeys(missingKeys, "Secret", ee, result.ExtraEnvSecretKeys[ee.Name].All())
 		}
 	}
 
@@ -144,15 +153,29 @@ func (r *BackstageReconciler) preprocessSpec(ctx context.Context, backstage api.
 		}
 	}
 
+	// Fail fast with all the keys referenced but missing, as the Pod would not start
+	if len(missingKeys) > 0 {
+		return result, fmt.Errorf("referenced keys not found: %s", strings.Join(missingKeys, "; "))
+	}
+
 	// Process DynamicPlugins
 	if bsSpec.Application.DynamicPluginsConfigMapName != "" {
 		cm := &corev1.ConfigMap{}
-		if hashingData, err = r.addExtConfig(ctx, cm, backstage.Name, bsSpec.Application.DynamicPluginsConfigMapName, ns, true, hashingData); err != nil {
+		if hashingData, err = r.addExtConfig(ctx, cm, bsSpec.Application.DynamicPluginsConfigMapName, ns, true, hashingData); err != nil {
 			return result, err
 		}
 		result.DynamicPlugins = *cm
 	}
 
+	// Process DynamicPlugins registry auth Secrets
+	for _, name := range model.RegistryAuthSecrets(backstage) {
+		secret := &corev1.Secret{Data: map[string][]byte{}, StringData: map[string]string{}}
+		if hashingData, err = r.addExtConfig(ctx, secret, name, ns, true, hashingData); err != nil {
+			return result, err
+		}
+		result.RegistryAuthSecretKeys[name] = model.NewDataObjectKeys(secret.StringData, secret.Data)
+	}
+
 	hash := sha256.New()
 	hash.Write(hashingData)
 	result.WatchingHash = fmt.Sprintf("%x", hash.Sum(nil))
@@ -160,54 +183,17 @@ func (r *BackstageReconciler) preprocessSpec(ctx context.Context, backstage api.
 	return result, nil
 }
 
-// addExtConfig makes object watchable by Operator adding ExtConfigSyncLabel label and BackstageNameAnnotation
-// and adding its content (marshalled object) to make it watchable by Operator and able to refresh the Pod if needed
-// (Pod refresh will be called if external configuration hash changed)
-func (r *BackstageReconciler) addExtConfig(ctx context.Context, obj client.Object, backstageName, objectName, ns string, addToWatch bool, hashingData []byte) ([]byte, error) {
-
-	lg := log.FromContext(ctx)
-
+// addExtConfig reads the external config object and, if it is watched, adds its content to hashingData
+// to refresh the Pod if needed (Pod refresh will be called if external configuration hash changed).
+// The object is not modified: the Backstage instances referencing it are found with the extConfigRefIndex.
+func (r *BackstageReconciler) addExtConfig(ctx context.Context, obj client.Object, objectName, ns string, addToWatch bool, hashingData []byte) ([]byte, error) {
+	if err := r.checkExternalObject(ctx, obj, objectName, ns); err != nil {
+		return hashingData, err
+	}
 	if !addToWatch {
-		return hashingData, r.checkExternalObject(ctx, obj, objectName, ns)
+		return hashingData, nil
 	}
-
-	// use RetryOnConflict to avoid possible Conflict error which may be caused mostly by other call of this function
-	// https://pkg.go.dev/k8s.io/client-go/util/retry#RetryOnConflict.
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-
-		if err := r.checkExternalObject(ctx, obj, objectName, ns); err != nil {
-			return err
-		}
-
-		if obj.GetLabels() == nil {
-			obj.SetLabels(map[string]string{})
-		}
-		if obj.GetAnnotations() == nil {
-			obj.SetAnnotations(map[string]string{})
-		}
-
-		autoSync := true
-		autoSyncStr, ok := os.LookupEnv(AutoSyncEnvVar)
-		if ok {
-			autoSync, _ = strconv.ParseBool(autoSyncStr)
-		}
-
-		if obj.GetLabels()[model.ExtConfigSyncLabel] == "" || obj.GetAnnotations()[model.BackstageNameAnnotation] == "" ||
-			obj.GetLabels()[model.ExtConfigSyncLabel] != strconv.FormatBool(autoSync) {
-
-			obj.GetLabels()[model.ExtConfigSyncLabel] = strconv.FormatBool(autoSync)
-			obj.GetAnnotations()[model.BackstageNameAnnotation] = backstageName
-			if err := r.Update(ctx, obj); err != nil {
-				return err
-			}
-			lg.V(1).Info(fmt.Sprintf("update external config %s with label %s=%s and annotation %s=%s", obj.GetName(), model.ExtConfigSyncLabel, strconv.FormatBool(autoSync), model.BackstageNameAnnotation, backstageName))
-		}
-
-		return nil
-
-	})
-
-	return concatData(hashingData, obj), err
+	return concatData(hashingData, obj), nil
 }
 
 func (r *BackstageReconciler) checkExternalObject(ctx context.Context, obj client.Object, objectName, ns string) error {
@@ -220,12 +206,10 @@ func (r *BackstageReconciler) checkExternalObject(ctx context.Context, obj clien
 	return nil
 }
 
-func addToWatch(fileObjectRef api.FileObjectRef) bool {
-	// it will contain subPath either as specified key or as a list of all keys if only mountPath specified
-	if (fileObjectRef.MountPath == "" || fileObjectRef.Key != "") && utils.BoolEnvVar(WatchExtConfig, true) {
-		return true
-	}
-	return false
+func addToWatch(spec api.BackstageSpec, fileObjectRef api.FileObjectRef) bool {
+	// the objects reloaded live are mounted without subPath and updated by Kubernetes,
+	// the others (mounted with subPath or as directories restarting the Pod) are watched
+	return !spec.IsLiveReload(fileObjectRef) && utils.BoolEnvVar(WatchExtConfig, true)
 }
 
 func concatData(original []byte, obj client.Object) []byte {
@@ -299,3 +283,29 @@ func (r *BackstageReconciler) getOCPIngressDomain() (string, error) {
 	}
 	return d, nil
 }
+
+// appendMissingKey appends the reference to the key of the object to missingKeys if the key is specified and not in keys
+func appendMissingKey(missingKeys []string, kind, name, key string, keys []string) []string {
+	if key == "" || slices.Contains(keys, key) {
+		return missingKeys
+	}
+	return append(missingKeys, fmt.Sprintf("%s %s has no key %s", kind, name, key))
+}
+
+// appendMissingEnvKeys appends the key and the item keys of the environment reference not found in the object keys
+func appendMissingEnvKeys(missingKeys []string, kind string, ref api.EnvObjectRef, keys []string) []string {
+	missingKeys = appendMissingKey(missingKeys, kind, ref.Name, ref.Key, keys)
+	for _, item := range ref.Items {
+		missingKeys = appendMissingKey(missingKeys, kind, ref.Name, item.Key, keys)
+	}
+	return missingKeys
+}
+
+// appendMissingFileKeys appends the key and the item keys of the file reference not found in the object keys
+func appendMissingFileKeys(missingKeys []string, kind string, ref api.FileObjectRef, keys []string) []string {
+	missingKeys = appendMissingKey(missingKeys, kind, ref.Name, ref.Key, keys)
+	for _, item := range ref.Items {
+		missingKeys = appendMissingKey(missingKeys, kind, ref.Name, item.Key, keys)
+	}
+	return missingKeys
+}
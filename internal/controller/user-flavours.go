@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+)
+
+// UserFlavourLoader loads the user flavours from the ConfigMaps labeled with model.FlavourLabel in the Operator namespace.
+// The ConfigMaps are read from a cache of their own, so they are found regardless of the cache options of the manager
+// (e.g. --enable-cache-label-filter), and a ConfigMap is parsed again only when its resourceVersion changes.
+type UserFlavourLoader struct {
+	cache     cache.Cache
+	reader    client.Reader
+	namespace string
+	scheme    *runtime.Scheme
+
+	mu     sync.Mutex
+	loaded map[string]loadedUserFlavour
+}
+
+// loadedUserFlavour is the user flavour parsed from the resourceVersion of its ConfigMap
+type loadedUserFlavour struct {
+	resourceVersion string
+	flavour         model.UserFlavour
+}
+
+// NewUserFlavourLoader creates the loader of the user flavours of the namespace and adds its cache to the manager
+func NewUserFlavourLoader(mgr ctrl.Manager, namespace string) (*UserFlavourLoader, error) {
+	selector, err := labels.Parse(model.FlavourLabel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create flavour label selector: %w", err)
+	}
+	c, err := cache.New(mgr.GetConfig(), cache.Options{
+		HTTPClient:           mgr.GetHTTPClient(),
+		Scheme:               mgr.GetScheme(),
+		Mapper:               mgr.GetRESTMapper(),
+		DefaultNamespaces:    map[string]cache.Config{namespace: {}},
+		DefaultLabelSelector: selector,
+		DefaultTransform:     cache.TransformStripManagedFields(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create flavour ConfigMaps cache: %w", err)
+	}
+	if err := mgr.Add(c); err != nil {
+		return nil, fmt.Errorf("failed to add flavour ConfigMaps cache: %w", err)
+	}
+	return &UserFlavourLoader{cache: c, reader: c, namespace: namespace, scheme: mgr.GetScheme()}, nil
+}
+
+// Load returns the user flavours, none if the loader is nil (the Operator namespace is not known).
+// Invalid flavours are kept with the reason, so enabling them fails the instance with it.
+func (l *UserFlavourLoader) Load(ctx context.Context) (model.UserFlavours, error) {
+	if l == nil {
+		return nil, nil
+	}
+	lg := log.FromContext(ctx)
+
+	cms := &corev1.ConfigMapList{}
+	if err := l.reader.List(ctx, cms, client.InNamespace(l.namespace), client.HasLabels{model.FlavourLabel}); err != nil {
+		return nil, fmt.Errorf("failed to list flavour ConfigMaps: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	loaded := make(map[string]loadedUserFlavour, len(cms.Items))
+	flavours := make(model.UserFlavours, len(cms.Items))
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		lf, ok := l.loaded[cm.Name]
+		if !ok || lf.resourceVersion != cm.ResourceVersion {
+			lf = loadedUserFlavour{resourceVersion: cm.ResourceVersion, flavour: model.NewUserFlavour(cm, *l.scheme)}
+			if lf.flavour.Err != nil {
+				lg.V(1).Info("invalid user flavour", "flavour", lf.flavour.Name, "error", lf.flavour.Err.Error())
+			}
+		}
+		loaded[cm.Name] = lf
+		flavours[cm.Name] = lf.flavour
+	}
+	l.loaded = loaded
+	return flavours, nil
+}
+
+// flavourSource returns the source of the events of the flavour ConfigMaps, mapped with the function
+func (l *UserFlavourLoader) flavourSource(mapFunc handler.MapFunc) source.Source {
+	return source.Kind(l.cache, &corev1.ConfigMap{}, handler.TypedEnqueueRequestsFromMapFunc(
+		func(ctx context.Context, cm *corev1.ConfigMap) []reconcile.Request {
+			return mapFunc(ctx, cm)
+		}))
+}
+
+// requestsByFlavour returns the requests for the Backstage instances which may use the user flavour:
+// the ones enabling flavours by default, referencing it in spec.flavours or reporting it in the status
+func (r *BackstageReconciler) requestsByFlavour(ctx context.Context, object client.Object) []reconcile.Request {
+	lg := log.FromContext(ctx)
+
+	backstages := &api.BackstageList{}
+	if err := r.List(ctx, backstages); err != nil {
+		lg.Error(err, "request by flavour failed, list Backstages ")
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, backstage := range backstages.Items {
+		if usesFlavour(backstage, object.GetName()) {
+			lg.V(1).Info("enqueuing reconcile for", "backstage", backstage.Name, "flavour", object.GetName())
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: backstage.Name, Namespace: backstage.Namespace}})
+		}
+	}
+	return requests
+}
+
+// usesFlavour returns whether the Backstage instance may use the flavour
+func usesFlavour(backstage api.Backstage, name string) bool {
+	if backstage.Spec.Flavours == nil {
+		return true
+	}
+	if slices.ContainsFunc(*backstage.Spec.Flavours, func(f api.Flavour) bool { return f.Name == name }) {
+		return true
+	}
+	return slices.ContainsFunc(backstage.Status.Flavours, func(f api.FlavourStatus) bool { return f.Name == name })
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+func TestUserFlavours(t *testing.T) {
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = api.AddToScheme(scheme)
+
+	flavourCm := func(name, ns string, data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: map[string]string{model.FlavourLabel: ""}},
+			Data:       data,
+		}
+	}
+	backstage := func(name string, flavours *[]api.Flavour, status ...api.FlavourStatus) *api.Backstage {
+		return &api.Backstage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"},
+			Spec:       api.BackstageSpec{Flavours: flavours},
+			Status:     api.BackstageStatus{Flavours: status},
+		}
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			flavourCm("golden", "operator-ns", map[string]string{model.FlavourMetadataFile: "enabledByDefault: true"}),
+			flavourCm("invalid", "operator-ns", map[string]string{"service.yaml": ""}),
+			// not in the Operator namespace
+			flavourCm("other", "ns1", map[string]string{}),
+			backstage("defaults", nil),
+			backstage("explicit", &[]api.Flavour{{Name: "golden", Enabled: true}}),
+			backstage("previous", &[]api.Flavour{}, api.FlavourStatus{Name: "golden", ConfigMap: "golden"}),
+			backstage("none", &[]api.Flavour{{Name: "flavor1", Enabled: true}})).
+		Build()
+	loader := &UserFlavourLoader{reader: c, namespace: "operator-ns", scheme: scheme}
+	r := &BackstageReconciler{Client: c, Scheme: scheme, UserFlavours: loader}
+
+	userFlavours, err := loader.Load(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"golden", "invalid"}, utils.SortedKeys(userFlavours))
+
+	// the flavours are resolved with the loaded user flavours
+	required, err := model.RequiredInfrastructure(backstage("defaults", nil).Spec, userFlavours)
+	assert.NoError(t, err)
+	assert.Empty(t, required)
+	_, err = model.RequiredInfrastructure(backstage("bs", &[]api.Flavour{{Name: "invalid", Enabled: true}}).Spec, userFlavours)
+	assert.ErrorContains(t, err, "flavour 'invalid' is invalid: service.yaml is not a config file supported by flavours")
+	_, err = model.RequiredInfrastructure(backstage("bs", &[]api.Flavour{{Name: "other", Enabled: true}}).Spec, userFlavours)
+	assert.ErrorContains(t, err, "flavour 'other' not found")
+
+	// the unchanged ConfigMaps are not parsed again
+	cached := loader.loaded["invalid"]
+	cached.flavour.Err = fmt.Errorf("cached")
+	loader.loaded["invalid"] = cached
+	userFlavours, err = loader.Load(ctx)
+	assert.NoError(t, err)
+	assert.EqualError(t, userFlavours["invalid"].Err, "cached")
+
+	// the changed ones are
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: "invalid", Namespace: "operator-ns"}, cm))
+	cm.Data = map[string]string{model.FlavourMetadataFile: "enabledByDefault: false"}
+	assert.NoError(t, c.Update(ctx, cm))
+	userFlavours, err = loader.Load(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, userFlavours["invalid"].Err)
+
+	// and the removed ones are dropped
+	assert.NoError(t, c.Delete(ctx, cm))
+	userFlavours, err = loader.Load(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"golden"}, utils.SortedKeys(userFlavours))
+
+	// not supported without the Operator namespace
+	userFlavours, err = (*UserFlavourLoader)(nil).Load(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, userFlavours)
+
+	requests := r.requestsByFlavour(ctx, flavourCm("golden", "operator-ns", nil))
+	var names []string
+	for _, req := range requests {
+		names = append(names, req.Name)
+	}
+	assert.ElementsMatch(t, []string{"defaults", "explicit", "previous"}, names)
+}
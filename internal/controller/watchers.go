@@ -2,16 +2,19 @@ package controller
 
 import (
 	"context"
-	"fmt"
+	"slices"
+	"time"
 
 	"github.com/redhat-developer/rhdh-operator/api"
 	"github.com/redhat-developer/rhdh-operator/pkg/model"
 	"github.com/redhat-developer/rhdh-operator/pkg/utils"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -21,23 +24,66 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// extConfigRefIndex indexes the Backstage instances by the external ConfigMaps, Secrets and PVCs they reference,
+// as <Kind>/<name>, so all the instances sharing an object are refreshed when it is created or changes
+const extConfigRefIndex = ".spec.application.extConfigRefs"
+
+// missingRefsBackoff is the exponential requeue delay of the Backstage instances referencing objects which do not exist (yet),
+// in case the creation of the object is missed
+var missingRefsBackoff = workqueue.NewTypedItemExponentialFailureRateLimiter[types.NamespacedName](5*time.Second, 5*time.Minute)
+
+// indexExtConfigRefs registers the extConfigRefIndex
+func indexExtConfigRefs(ctx context.Context, indexer client.FieldIndexer) error {
+	return indexer.IndexField(ctx, &api.Backstage{}, extConfigRefIndex, func(o client.Object) []string {
+		return extConfigRefs(o.(*api.Backstage))
+	})
+}
+
+// extConfigRefs returns the external ConfigMaps, Secrets and PVCs referenced in the Backstage spec, as <Kind>/<name>
+func extConfigRefs(backstage *api.Backstage) []string {
+	app := backstage.Spec.Application
+	if app == nil {
+		return nil
+	}
+	var refs []string
+	if app.AppConfig != nil {
+		for _, ac := range app.AppConfig.ConfigMaps {
+			refs = append(refs, "ConfigMap/"+ac.Name)
+		}
+	}
+	if app.ExtraFiles != nil {
+		for _, ef := range app.ExtraFiles.ConfigMaps {
+			refs = append(refs, "ConfigMap/"+ef.Name)
+		}
+		for _, ef := range app.ExtraFiles.Secrets {
+			refs = append(refs, "Secret/"+ef.Name)
+		}
+		for _, ep := range app.ExtraFiles.Pvcs {
+			refs = append(refs, "PersistentVolumeClaim/"+ep.Name)
+		}
+	}
+	if app.ExtraEnvs != nil {
+		for _, ee := range app.ExtraEnvs.ConfigMaps {
+			refs = append(refs, "ConfigMap/"+ee.Name)
+		}
+		for _, ee := range app.ExtraEnvs.Secrets {
+			refs = append(refs, "Secret/"+ee.Name)
+		}
+	}
+	if app.DynamicPluginsConfigMapName != "" {
+		refs = append(refs, "ConfigMap/"+app.DynamicPluginsConfigMapName)
+	}
+	for _, name := range model.RegistryAuthSecrets(*backstage) {
+		refs = append(refs, "Secret/"+name)
+	}
+	slices.Sort(refs)
+	return slices.Compact(refs)
+}
+
 func (r *BackstageReconciler) addWatchers(b *builder.Builder) error {
 	// Watch in all the cases but WatchExtConfig == false
 	if utils.BoolEnvVar(WatchExtConfig, true) {
 
-		pred, err := predicate.LabelSelectorPredicate(metav1.LabelSelector{
-			MatchExpressions: []metav1.LabelSelectorRequirement{
-				{
-					Key:      model.ExtConfigSyncLabel,
-					Values:   []string{"true"},
-					Operator: metav1.LabelSelectorOpIn,
-				},
-			},
-		})
-		if err != nil {
-			return fmt.Errorf("failed to construct the predicate for matching secrets. This should not happen: %w", err)
-		}
-
 		secretMeta := &metav1.PartialObjectMetadata{}
 		secretMeta.SetGroupVersionKind(schema.GroupVersionKind{
 			Group:   "",
@@ -52,29 +98,56 @@ func (r *BackstageReconciler) addWatchers(b *builder.Builder) error {
 			Kind:    "ConfigMap",
 		})
 
+		pvcMeta := &metav1.PartialObjectMetadata{}
+		pvcMeta.SetGroupVersionKind(schema.GroupVersionKind{
+			Group:   "",
+			Version: "v1",
+			Kind:    "PersistentVolumeClaim",
+		})
+
+		// the Backstage instances may reference the objects before they are created,
+		// the objects no Backstage instance references are filtered out before they are mapped
+		extConfigPreds := func(kind string) builder.Predicates {
+			return builder.WithPredicates(predicate.Funcs{
+				CreateFunc: func(e event.CreateEvent) bool { return r.isExtConfig(kind, e.Object) },
+				DeleteFunc: func(e event.DeleteEvent) bool { return r.isExtConfig(kind, e.Object) },
+				// the periodic resyncs do not change the objects
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return e.ObjectOld.GetResourceVersion() != e.ObjectNew.GetResourceVersion() && r.isExtConfig(kind, e.ObjectNew)
+				},
+			})
+		}
+
 		b.WatchesMetadata(
 			secretMeta,
 			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, o client.Object) []reconcile.Request {
-				return r.requestByExtConfigLabel(ctx, o)
-			}),
-			builder.WithPredicates(pred, predicate.Funcs{
-				DeleteFunc: func(e event.DeleteEvent) bool { return true },
-				UpdateFunc: func(e event.UpdateEvent) bool { return true },
-				// CreateFunc: func(e event.CreateEvent) bool { return true },
+				return r.requestsByExtConfig(ctx, "Secret", o)
 			}),
+			extConfigPreds("Secret"),
 		).
 			WatchesMetadata(
 				configMapMeta,
 				handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, o client.Object) []reconcile.Request {
-					return r.requestByExtConfigLabel(ctx, o)
+					return r.requestsByExtConfig(ctx, "ConfigMap", o)
+				}),
+				extConfigPreds("ConfigMap"),
+			).
+			// the content of PVCs is not watched, only their creation
+			WatchesMetadata(
+				pvcMeta,
+				handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, o client.Object) []reconcile.Request {
+					return r.requestsByExtConfig(ctx, "PersistentVolumeClaim", o)
 				}),
-				builder.WithPredicates(pred, predicate.Funcs{
-					DeleteFunc: func(e event.DeleteEvent) bool { return true },
-					UpdateFunc: func(e event.UpdateEvent) bool { return true },
-					// CreateFunc: func(e event.CreateEvent) bool { return true },
+				builder.WithPredicates(predicate.Funcs{
+					CreateFunc: func(e event.CreateEvent) bool { return r.isExtConfig("PersistentVolumeClaim", e.Object) },
 				}))
 	}
 
+	// Watch the user flavour ConfigMaps in the Operator namespace
+	if r.UserFlavours != nil {
+		b.WatchesRawSource(r.UserFlavours.flavourSource(r.requestsByFlavour))
+	}
+
 	// Watch operator-owned Deployments and StatefulSets for status tracking.
 	// Owns() maps events back to the owning Backstage CR via ownerReferences.
 	logEvent := func(eventType string, obj client.Object) {
@@ -105,61 +178,80 @@ func (r *BackstageReconciler) addWatchers(b *builder.Builder) error {
 	return nil
 }
 
-// requestByExtConfigLabel returns a request with current Namespace and Backstage Object name taken from label
-// or empty request object if label not found
-func (r *BackstageReconciler) requestByExtConfigLabel(ctx context.Context, object client.Object) []reconcile.Request {
+// isExtConfig returns whether a Backstage instance in the namespace of the object references it,
+// looked up in the extConfigRefIndex of the cache. The objects the operator owns are not external configuration.
+func (r *BackstageReconciler) isExtConfig(kind string, object client.Object) bool {
+	if owner := metav1.GetControllerOf(object); owner != nil && owner.Kind == "Backstage" {
+		return false
+	}
+	backstages := &api.BackstageList{}
+	if err := r.List(context.Background(), backstages, client.InNamespace(object.GetNamespace()),
+		client.MatchingFields{extConfigRefIndex: kind + "/" + object.GetName()}, client.Limit(1)); err != nil {
+		// let requestsByExtConfig report it
+		return true
+	}
+	return len(backstages.Items) > 0
+}
+
+// requestsByExtConfig returns the requests for the Backstage instances in the namespace of the object
+// which reference it (found with the extConfigRefIndex) and either failed to deploy (e.g. as the object
+// did not exist) or whose external configuration hash changed, unless AutoSyncEnvVar is false
+func (r *BackstageReconciler) requestsByExtConfig(ctx context.Context, kind string, object client.Object) []reconcile.Request {
 
 	lg := log.FromContext(ctx)
 
-	backstageName := object.GetAnnotations()[model.BackstageNameAnnotation]
-	if backstageName == "" {
-		// lg.V(1).Info(fmt.Sprintf("warning: %s annotation is not defined for %s, Backstage instances will not be reconciled in this loop", model.BackstageNameAnnotation, object.GetName()))
+	backstages := &api.BackstageList{}
+	if err := r.List(ctx, backstages, client.InNamespace(object.GetNamespace()),
+		client.MatchingFields{extConfigRefIndex: kind + "/" + object.GetName()}); err != nil {
+		lg.Error(err, "request by external config failed, list Backstages ")
 		return []reconcile.Request{}
 	}
 
-	nn := types.NamespacedName{
-		Namespace: object.GetNamespace(),
-		Name:      backstageName,
-	}
-
-	backstage := api.Backstage{}
-	if err := r.Get(ctx, nn, &backstage); err != nil {
-		if !errors.IsNotFound(err) {
-			lg.Error(err, "request by label failed, get Backstage ")
+	autoSync := utils.BoolEnvVar(AutoSyncEnvVar, true)
+	requests := []reconcile.Request{}
+	for _, backstage := range backstages.Items {
+		if deployFailed(backstage) || (autoSync && r.extConfigChanged(ctx, backstage)) {
+			lg.V(1).Info("enqueuing reconcile for", "backstage", backstage.Name, kind, object.GetName())
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: backstage.Name, Namespace: backstage.Namespace}})
 		}
-		return []reconcile.Request{}
 	}
+	return requests
+}
 
-	// ec, err := r.preprocessSpec(ctx, backstage)
-	// if err != nil {
-	// 	lg.Error(err, "request by label failed, preprocess Backstage ")
-	// 	return []reconcile.Request{}
-	// }
+// deployFailed returns whether the last reconciliation of the Backstage instance failed
+func deployFailed(backstage api.Backstage) bool {
+	cond := meta.FindStatusCondition(backstage.Status.Conditions, string(api.BackstageConditionTypeDeployed))
+	return cond != nil && cond.Reason == string(api.BackstageConditionReasonFailed)
+}
+
+// extConfigChanged returns whether the hash of the external configuration of the Backstage instance
+// differs from the one its Deployment was created with
+func (r *BackstageReconciler) extConfigChanged(ctx context.Context, backstage api.Backstage) bool {
 
-	deploy, err := FindDeployment(ctx, r.Client, object.GetNamespace(), backstage.Name)
+	lg := log.FromContext(ctx)
+
+	deploy, err := FindDeployment(ctx, r.Client, backstage.Namespace, backstage.Name)
 	if err != nil {
 		if !errors.IsNotFound(err) {
-			lg.V(1).Info("request by label could not find a resource (most likely not created yet)", "error", err.Error())
+			lg.V(1).Info("request by external config could not find a resource (most likely not created yet)", "error", err.Error())
 		} else {
-			lg.Error(err, "request by label failed, find deployment ")
+			lg.Error(err, "request by external config failed, find deployment ")
 		}
-		return []reconcile.Request{}
+		return false
 	}
 
 	ec, err := r.preprocessSpec(ctx, backstage)
 	if err != nil {
-		lg.Error(err, "request by label failed, preprocess Backstage ")
-		return []reconcile.Request{}
+		lg.Error(err, "request by external config failed, preprocess Backstage ")
+		return false
 	}
 
 	newHash := ec.WatchingHash
 	oldHash := deploy.GetObject().GetAnnotations()[model.ExtConfigHashAnnotation]
 	if newHash == oldHash {
-		lg.V(1).Info("request by label, hash are equal", "hash", newHash)
-		return []reconcile.Request{}
+		lg.V(1).Info("request by external config, hash are equal", "backstage", backstage.Name, "hash", newHash)
+		return false
 	}
-
-	lg.V(1).Info("enqueuing reconcile for", object.GetObjectKind().GroupVersionKind().Kind, object.GetName(), "new hash: ", newHash, "old hash: ", oldHash)
-	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: backstage.Name, Namespace: object.GetNamespace()}}}
-
+	lg.V(1).Info("request by external config", "backstage", backstage.Name, "new hash", newHash, "old hash", oldHash)
+	return true
 }
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model"
+)
+
+func TestExtConfigRefs(t *testing.T) {
+	bs := &api.Backstage{Spec: api.BackstageSpec{Application: &api.Application{
+		AppConfig: &api.AppConfig{ConfigMaps: []api.FileObjectRef{{Name: "app-config"}}},
+		ExtraFiles: &api.ExtraFiles{
+			ConfigMaps: []api.FileObjectRef{{Name: "files"}},
+			Secrets:    []api.FileObjectRef{{Name: "certs", Key: "tls.crt"}},
+			Pvcs:       []api.PvcRef{{Name: "data"}},
+		},
+		ExtraEnvs: &api.ExtraEnvs{
+			ConfigMaps: []api.EnvObjectRef{{Name: "app-config"}},
+			Secrets:    []api.EnvObjectRef{{Name: "certs", Key: "TOKEN"}},
+		},
+		DynamicPluginsConfigMapName: "plugins",
+	}}}
+	assert.Equal(t, []string{"ConfigMap/app-config", "ConfigMap/files", "ConfigMap/plugins", "PersistentVolumeClaim/data", "Secret/certs"}, extConfigRefs(bs))
+	assert.Empty(t, extConfigRefs(&api.Backstage{}))
+}
+
+func TestRequestsByExtConfig(t *testing.T) {
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = api.AddToScheme(scheme)
+
+	backstage := func(name string, appConfig string) *api.Backstage {
+		return &api.Backstage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"},
+			Spec: api.BackstageSpec{Application: &api.Application{
+				AppConfig: &api.AppConfig{ConfigMaps: []api.FileObjectRef{{Name: appConfig}}},
+			}},
+		}
+	}
+	deployment := func(name string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:        model.DeploymentName(name),
+			Namespace:   "ns1",
+			Annotations: map[string]string{model.ExtConfigHashAnnotation: "old"},
+		}}
+	}
+	shared := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "ns1"}, Data: map[string]string{"app-config.yaml": "app:"}}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithIndex(&api.Backstage{}, extConfigRefIndex, func(o client.Object) []string {
+			return extConfigRefs(o.(*api.Backstage))
+		}).
+		WithObjects(backstage("bs1", "shared"), backstage("bs2", "shared"), backstage("bs3", "other"),
+			deployment("bs1"), deployment("bs2"), deployment("bs3"), shared,
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns1"}}).
+		WithStatusSubresource(&api.Backstage{}).
+		Build()
+	r := &BackstageReconciler{Client: c, Scheme: scheme}
+
+	// all the instances sharing the ConfigMap are refreshed
+	requests := r.requestsByExtConfig(ctx, "ConfigMap", shared)
+	assert.ElementsMatch(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "bs1", Namespace: "ns1"}},
+		{NamespacedName: types.NamespacedName{Name: "bs2", Namespace: "ns1"}},
+	}, requests)
+
+	// not if they are up to date
+	ec, err := r.preprocessSpec(ctx, *backstage("bs1", "shared"))
+	assert.NoError(t, err)
+	bs1Deployment := deployment("bs1")
+	bs1Deployment.Annotations[model.ExtConfigHashAnnotation] = ec.WatchingHash
+	assert.NoError(t, c.Update(ctx, bs1Deployment))
+	requests = r.requestsByExtConfig(ctx, "ConfigMap", shared)
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "bs2", Namespace: "ns1"}}}, requests)
+
+	// the Secret of the same name is not referenced
+	assert.Empty(t, r.requestsByExtConfig(ctx, "Secret", shared))
+
+	// the objects are only looked up
+	assert.True(t, r.isExtConfig("ConfigMap", shared))
+	assert.False(t, r.isExtConfig("Secret", shared))
+	assert.False(t, r.isExtConfig("ConfigMap", &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "ns2"}}))
+	owned := shared.DeepCopy()
+	owned.OwnerReferences = []metav1.OwnerReference{{Kind: "Backstage", Name: "bs1", Controller: ptr.To(true)}}
+	assert.False(t, r.isExtConfig("ConfigMap", owned))
+
+	// nor if the auto sync is disabled
+	t.Setenv(AutoSyncEnvVar, "false")
+	assert.Empty(t, r.requestsByExtConfig(ctx, "ConfigMap", shared))
+
+	// the instance failed to deploy as the object did not exist yet, it is refreshed when the object is created,
+	// regardless of the auto sync
+	missing := backstage("bs4", "missing")
+	missing.Spec.Application.ExtraFiles = &api.ExtraFiles{Pvcs: []api.PvcRef{{Name: "data"}}}
+	assert.NoError(t, c.Create(ctx, missing))
+	_, err = r.preprocessSpec(ctx, *missing)
+	assert.True(t, errors.IsNotFound(err))
+	missing.Status.Conditions = []metav1.Condition{{
+		Type:   string(api.BackstageConditionTypeDeployed),
+		Status: metav1.ConditionFalse,
+		Reason: string(api.BackstageConditionReasonFailed),
+	}}
+	assert.NoError(t, c.Status().Update(ctx, missing))
+	requests = r.requestsByExtConfig(ctx, "ConfigMap", &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "ns1"}})
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "bs4", Namespace: "ns1"}}}, requests)
+	requests = r.requestsByExtConfig(ctx, "PersistentVolumeClaim", &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "ns1"}})
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "bs4", Namespace: "ns1"}}}, requests)
+}
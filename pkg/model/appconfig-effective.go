@@ -0,0 +1,219 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/model/multiobject"
+)
+
+const EffectiveAppConfigFile = "app-config.effective.yaml"
+
+// maskedValue replaces the values of sensitive app-config keys in the effective app-config
+const maskedValue = "********"
+
+// sensitiveKeySuffixes are the (lower-cased, without '-' and '_') suffixes of the app-config keys whose values are masked
+var sensitiveKeySuffixes = []string{"password", "passwd", "secret", "token", "credential", "credentials", "privatekey", "apikey", "accesskey"}
+
+// appConfigSource is the ConfigMap key an app-config file is mounted from
+type appConfigSource struct {
+	configMap *corev1.ConfigMap
+	key       string
+}
+
+func (s appConfigSource) String() string {
+	return s.configMap.Name + "/" + s.key
+}
+
+// addSources records the files of the ConfigMap passed with --config, mounted to the mountPath
+func (b *AppConfig) addSources(cm *corev1.ConfigMap, mountPath, key string) {
+	for file := range cm.Data {
+		if key == "" || key == file {
+			b.sources[filepath.Join(mountPath, file)] = appConfigSource{configMap: cm, key: file}
+		}
+	}
+}
+
+// addEffectiveAppConfig creates the ConfigMap with the effective app-config if spec.application.appConfig.publishEffectiveConfig is set.
+// It is called once all the objects are updated, as the Route updates the default app-config.
+// The ConfigMap is not mounted.
+func (b *AppConfig) addEffectiveAppConfig(backstage api.Backstage, scheme *runtime.Scheme) error {
+	if backstage.Spec.Application == nil || backstage.Spec.Application.AppConfig == nil ||
+		!backstage.Spec.Application.AppConfig.PublishEffectiveConfig {
+		return nil
+	}
+
+	effective, err := b.EffectiveConfig()
+	if err != nil {
+		return err
+	}
+
+	effectiveCM := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "effective-appconfig",
+			Namespace: backstage.Namespace,
+		},
+		Data: map[string]string{EffectiveAppConfigFile: effective},
+	}
+	setMultiObjectConfigMetaInfo(&multiobject.MultiObject{Items: []client.Object{effectiveCM}}, "appconfig", backstage, scheme)
+	b.ConfigMaps.Items = append(b.ConfigMaps.Items, effectiveCM)
+	return nil
+}
+
+// EffectiveConfig merges the app-config files in the order of the --config arguments of the Backstage container,
+// the way Backstage does: mappings are merged, other values are replaced by the later files.
+// Each value is annotated with its source ConfigMap and key, the values of sensitive keys are masked
+// unless they reference environment variables, which are left as is.
+// Files not known to the Operator (e.g. generated by the plugin installer) are listed in the header only.
+func (b *AppConfig) EffectiveConfig() (string, error) {
+	header := []string{"Effective app-config, merged in the order of the --config arguments:"}
+	var merged *yaml.Node
+	for _, path := range configArgs(b.model.getDeployment().container().Args) {
+		source, ok := b.sources[path]
+		if !ok {
+			header = append(header, fmt.Sprintf("- %s (not available to the Operator)", path))
+			continue
+		}
+		header = append(header, fmt.Sprintf("- %s (%s)", path, source))
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(source.configMap.Data[source.key]), &doc); err != nil {
+			return "", fmt.Errorf("failed to parse app-config %s: %w", source, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		merged = mergeAppConfigNodes(merged, doc.Content[0], source.String(), false)
+	}
+	if merged == nil {
+		merged = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	doc := &yaml.Node{Kind: yaml.DocumentNode, HeadComment: strings.Join(header, "\n"), Content: []*yaml.Node{merged}}
+	if err := enc.Encode(doc); err != nil {
+		return "", fmt.Errorf("failed to marshal effective app-config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("failed to marshal effective app-config: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// configArgs returns the values of the --config arguments in order
+func configArgs(args []string) []string {
+	var paths []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--config" && i+1 < len(args) {
+			paths = append(paths, args[i+1])
+			i++
+		} else if path, ok := strings.CutPrefix(args[i], "--config="); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// mergeAppConfigNodes merges src over dst, annotating and masking the values taken from src
+func mergeAppConfigNodes(dst, src *yaml.Node, source string, sensitive bool) *yaml.Node {
+	if src.Kind == yaml.AliasNode {
+		src = src.Alias
+	}
+	if dst == nil || dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return annotateAppConfigNode(src, source, sensitive)
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		valueSensitive := isSensitiveValue(key.Value, sensitive)
+		merged := false
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				dst.Content[j+1] = mergeAppConfigNodes(dst.Content[j+1], value, source, valueSensitive)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			clearComments(key)
+			dst.Content = append(dst.Content, key, annotateAppConfigNode(value, source, valueSensitive))
+		}
+	}
+	if len(dst.Content) > 0 {
+		// an empty mapping annotated before is not a leaf anymore
+		dst.LineComment = ""
+		dst.Style &^= yaml.FlowStyle
+	}
+	return dst
+}
+
+// annotateAppConfigNode sets the source as the comment of the leaf values and masks the sensitive ones
+func annotateAppConfigNode(node *yaml.Node, source string, sensitive bool) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	clearComments(node)
+	node.Anchor = ""
+	if len(node.Content) > 0 {
+		// the leaves are annotated one per line
+		node.Style &^= yaml.FlowStyle
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode && len(node.Content) > 0:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			clearComments(node.Content[i])
+			node.Content[i+1] = annotateAppConfigNode(node.Content[i+1], source, isSensitiveValue(node.Content[i].Value, sensitive))
+		}
+	case node.Kind == yaml.SequenceNode && len(node.Content) > 0:
+		for i := range node.Content {
+			node.Content[i] = annotateAppConfigNode(node.Content[i], source, sensitive)
+		}
+	default:
+		if sensitive && node.Kind == yaml.ScalarNode && node.Tag != "!!null" && !strings.Contains(node.Value, "${") {
+			node.Value = maskedValue
+			node.Tag = "!!str"
+			node.Style = 0
+		}
+		node.LineComment = source
+	}
+	return node
+}
+
+// isSensitiveValue returns whether the value of the key is masked, given whether its parent is.
+// The values of $env, $file and $include are references, not secrets.
+func isSensitiveValue(key string, parentSensitive bool) bool {
+	if strings.HasPrefix(key, "$") {
+		return false
+	}
+	if parentSensitive {
+		return true
+	}
+	normalized := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+	for _, suffix := range sensitiveKeySuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func clearComments(node *yaml.Node) {
+	node.HeadComment = ""
+	node.LineComment = ""
+	node.FootComment = ""
+}
@@ -0,0 +1,228 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/redhat-developer/rhdh-operator/pkg/model/multiobject"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+// envSubstitutionRegexp matches the ${VAR} substitutions of app-config values, $${VAR} is an escaped one.
+// Substitutions which are not a plain variable name (e.g. with a default value) are not checked.
+var envSubstitutionRegexp = regexp.MustCompile(`\$?\$\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}`)
+
+// ValidateReferences checks the environment variables (${VAR} substitutions and $env includes) and
+// the files ($file and $include includes) referenced by the app-config files known to the Operator
+// and returns the ones not available to the Backstage container.
+// Environment variables are checked only if the keys of all the container's envFrom sources are known.
+// Files are checked only if they are under a directory mounted from a ConfigMap or Secret with known keys,
+// as the content of the image is not known.
+func (b *AppConfig) ValidateReferences() []string {
+	container := b.model.getDeployment().container()
+	env, envKnown := b.model.containerEnv(container)
+
+	var errs []string
+	for _, path := range configArgs(container.Args) {
+		source, ok := b.sources[path]
+		if !ok {
+			continue
+		}
+		var content any
+		if err := yaml.Unmarshal([]byte(source.configMap.Data[source.key]), &content); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: failed to parse: %v", source, err))
+			continue
+		}
+
+		missingEnv := map[string]bool{}
+		var missingFiles []string
+		walkAppConfigReferences(content,
+			func(name string) {
+				if envKnown && !env[name] {
+					missingEnv[name] = true
+				}
+			},
+			func(file string) {
+				if !filepath.IsAbs(file) {
+					file = filepath.Join(filepath.Dir(path), file)
+				}
+				if !b.model.fileAvailable(container, file) && !slices.Contains(missingFiles, file) {
+					missingFiles = append(missingFiles, file)
+				}
+			})
+
+		for _, name := range utils.SortedKeys(missingEnv) {
+			errs = append(errs, fmt.Sprintf("%s: environment variable %s is not set", source, name))
+		}
+		for _, file := range missingFiles {
+			errs = append(errs, fmt.Sprintf("%s: file %s not found", source, file))
+		}
+	}
+	return errs
+}
+
+// walkAppConfigReferences calls onEnv for the environment variables and onFile for the files the app-config value references
+func walkAppConfigReferences(value any, onEnv func(string), onFile func(string)) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 1 {
+			if name, ok := v["$env"].(string); ok {
+				onEnv(name)
+				return
+			}
+			for _, include := range []string{"$file", "$include"} {
+				if file, ok := v[include].(string); ok {
+					// the file can be selected with a #fragment
+					file, _, _ = strings.Cut(file, "#")
+					onFile(file)
+					return
+				}
+			}
+		}
+		for _, item := range v {
+			walkAppConfigReferences(item, onEnv, onFile)
+		}
+	case []any:
+		for _, item := range v {
+			walkAppConfigReferences(item, onEnv, onFile)
+		}
+	case string:
+		for _, m := range envSubstitutionRegexp.FindAllStringSubmatch(v, -1) {
+			if !strings.HasPrefix(m[0], "$$") {
+				onEnv(m[1])
+			}
+		}
+	}
+}
+
+// containerEnv returns the environment variables of the container and whether the keys of all its envFrom sources are known
+func (m *BackstageModel) containerEnv(container *corev1.Container) (map[string]bool, bool) {
+	env := map[string]bool{}
+	for _, e := range container.Env {
+		env[e.Name] = true
+	}
+	known := true
+	for _, from := range container.EnvFrom {
+		var keys []string
+		var ok bool
+		if from.ConfigMapRef != nil {
+			keys, ok = m.dataObjectKeys(ConfigMapObjectKind, from.ConfigMapRef.Name)
+		} else if from.SecretRef != nil {
+			keys, ok = m.dataObjectKeys(SecretObjectKind, from.SecretRef.Name)
+		}
+		if !ok {
+			known = false
+			continue
+		}
+		for _, key := range keys {
+			env[from.Prefix+key] = true
+		}
+	}
+	return env, known
+}
+
+// fileAvailable returns false if the file is under a directory mounted to the container from a ConfigMap or Secret
+// with known keys, which does not contain it
+func (m *BackstageModel) fileAvailable(container *corev1.Container, file string) bool {
+	var dirMount *corev1.VolumeMount
+	for i, vm := range container.VolumeMounts {
+		if vm.SubPath != "" {
+			if vm.MountPath == file {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(file, strings.TrimSuffix(vm.MountPath, "/")+"/") &&
+			(dirMount == nil || len(vm.MountPath) > len(dirMount.MountPath)) {
+			dirMount = &container.VolumeMounts[i]
+		}
+	}
+	if dirMount == nil {
+		return true
+	}
+
+	var volume *corev1.Volume
+	for i, v := range m.getDeployment().podSpec().Volumes {
+		if v.Name == dirMount.Name {
+			volume = &m.getDeployment().podSpec().Volumes[i]
+		}
+	}
+	if volume == nil {
+		return true
+	}
+
+	var keys []string
+	var items []corev1.KeyToPath
+	var ok bool
+	switch {
+	case volume.ConfigMap != nil:
+		keys, ok = m.dataObjectKeys(ConfigMapObjectKind, volume.ConfigMap.Name)
+		items = volume.ConfigMap.Items
+	case volume.Secret != nil:
+		keys, ok = m.dataObjectKeys(SecretObjectKind, volume.Secret.SecretName)
+		items = volume.Secret.Items
+	}
+	if !ok {
+		return true
+	}
+	if len(items) > 0 {
+		keys = nil
+		for _, item := range items {
+			keys = append(keys, item.Path)
+		}
+	}
+	rel, _ := filepath.Rel(dirMount.MountPath, file)
+	return slices.Contains(keys, rel)
+}
+
+// dataObjectKeys returns the keys of the ConfigMap or Secret referenced in the spec or generated by the Operator, if known
+func (m *BackstageModel) dataObjectKeys(kind ObjectKind, name string) ([]string, bool) {
+	ec := m.ExternalConfig
+	var external []map[string]DataObjectKeys
+	if kind == ConfigMapObjectKind {
+		external = []map[string]DataObjectKeys{ec.ExtraEnvConfigMapKeys, ec.ExtraFileConfigMapKeys}
+		if keys, ok := ec.AppConfigKeys[name]; ok {
+			return keys, true
+		}
+	} else {
+		external = []map[string]DataObjectKeys{ec.ExtraEnvSecretKeys, ec.ExtraFileSecretKeys}
+	}
+	for _, objectKeys := range external {
+		if keys, ok := objectKeys[name]; ok {
+			return keys.All(), true
+		}
+	}
+
+	for _, ro := range m.GetRuntimeObjects() {
+		var objects []client.Object
+		switch obj := ro.Object().(type) {
+		case *multiobject.MultiObject:
+			objects = obj.Items
+		case client.Object:
+			objects = []client.Object{obj}
+		}
+		for _, obj := range objects {
+			if obj.GetName() != name {
+				continue
+			}
+			switch o := obj.(type) {
+			case *corev1.ConfigMap:
+				if kind == ConfigMapObjectKind {
+					return append(utils.SortedKeys(o.Data), utils.SortedKeys(o.BinaryData)...), true
+				}
+			case *corev1.Secret:
+				if kind == SecretObjectKind {
+					return append(utils.SortedKeys(o.Data), utils.SortedKeys(o.StringData)...), true
+				}
+			}
+		}
+	}
+	return nil, false
+}
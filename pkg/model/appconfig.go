@@ -9,6 +9,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	sigsyaml "sigs.k8s.io/yaml"
 
 	"github.com/redhat-developer/rhdh-operator/api"
 	"github.com/redhat-developer/rhdh-operator/pkg/model/multiobject"
@@ -17,6 +18,7 @@ import (
 )
 
 const PluginsAppConfigFile = "app-config.dynamic-plugins.yaml"
+const InlineAppConfigFile = "app-config.inline.yaml"
 
 type AppConfigFactory struct{}
 
@@ -29,6 +31,10 @@ func (f AppConfigFactory) newBackstageObject() RuntimeObject {
 type AppConfig struct {
 	ConfigMaps *multiobject.MultiObject
 	model      *BackstageModel
+	// sources maps the --config arguments to the app-config ConfigMap keys they are mounted from
+	sources map[string]appConfigSource
+	// pluginConfigErrors are the conflicts and schema validation errors of the dynamic plugins pluginConfig
+	pluginConfigErrors []string
 }
 
 func init() {
@@ -51,6 +57,7 @@ func (b *AppConfig) GetKey() string {
 // implementation of RuntimeObject interface
 func (b *AppConfig) addToModel(model *BackstageModel, backstage api.Backstage, config runtime.Object, scheme *runtime.Scheme) error {
 	b.model = model
+	b.sources = map[string]appConfigSource{}
 	if config != nil {
 		b.ConfigMaps = config.(*multiobject.MultiObject)
 	} else {
@@ -66,21 +73,28 @@ func (b *AppConfig) addToModel(model *BackstageModel, backstage api.Backstage, c
 
 // addPluginsAppConfig creates a ConfigMap with merged pluginConfig from all enabled plugins
 // and prepends it to b.ConfigMaps.Items. Does nothing if there are no plugin configs.
+// The conflicts (the first plugin setting a key wins) and the schema validation errors do not fail the model,
+// they are kept for PluginConfigErrors.
 func (b *AppConfig) addPluginsAppConfig(namespace string) error {
+	b.pluginConfigErrors = nil
 	dpObj := b.model.GetRuntimeObject(DynamicPluginsKey)
 	if dpObj == nil {
 		return nil
 	}
 
-	plugins := dpObj.(*DynamicPlugins).enabledPlugins
-	if plugins == nil {
+	dp := dpObj.(*DynamicPlugins)
+	if dp.enabledPlugins == nil {
 		return nil
 	}
+	plugins, errs := withPackageSchemas(dp.enabledPlugins, dp.packages, b.model.ExternalConfig.PluginSchemas)
 
 	mergedConfig := make(map[string]interface{})
+	owners := make(map[string]string)
 	for _, plugin := range plugins {
-		mergePluginConfigs(mergedConfig, plugin.PluginConfig)
+		errs = append(errs, mergePluginConfigs(mergedConfig, plugin.PluginConfig, owners, plugin.Package, "")...)
 	}
+	errs = append(errs, validatePluginConfigs(mergedConfig, plugins)...)
+	b.pluginConfigErrors = errs
 	if len(mergedConfig) == 0 {
 		return nil
 	}
@@ -105,6 +119,12 @@ func (b *AppConfig) addPluginsAppConfig(namespace string) error {
 	return nil
 }
 
+// PluginConfigErrors returns the conflicts of the dynamic plugins pluginConfig
+// and its validation errors against the plugins configSchema
+func (b *AppConfig) PluginConfigErrors() []string {
+	return b.pluginConfigErrors
+}
+
 // implementation of RuntimeObject interface
 func (b *AppConfig) updateAndValidate(backstage api.Backstage, scheme *runtime.Scheme) error {
 	deployment := b.model.getDeployment()
@@ -132,21 +152,80 @@ func (b *AppConfig) updateAndValidate(backstage api.Backstage, scheme *runtime.S
 			if err != nil {
 				return err
 			}
+			b.addSources(cm, b.model.getDeployment().defaultMountPath(), "")
 		}
 
 		// Process ConfigMaps from CR spec
 		if backstage.Spec.Application != nil && backstage.Spec.Application.AppConfig != nil && backstage.Spec.Application.AppConfig.ConfigMaps != nil {
 			for _, specCm := range backstage.Spec.Application.AppConfig.ConfigMaps {
-				mp, wSubpath := deployment.mountPath(specCm.MountPath, specCm.Key, backstage.Spec.Application.AppConfig.MountPath)
+				mp, wSubpath := deployment.fileObjectMountPath(backstage.Spec, specCm, backstage.Spec.Application.AppConfig.MountPath)
+				files := b.model.ExternalConfig.AppConfigKeys[specCm.Name]
+				data := b.model.ExternalConfig.AppConfigData[specCm.Name]
+				if len(specCm.Items) > 0 {
+					// the files are renamed
+					files = nil
+					data = map[string]string{}
+					for _, item := range specCm.Items {
+						files = append(files, fileObjectItemPath(item))
+						data[fileObjectItemPath(item)] = b.model.ExternalConfig.AppConfigData[specCm.Name][item.Key]
+					}
+				}
 				err := updatePodWithAppConfig(deployment, specCm.Name,
-					mp, specCm.Key, wSubpath, b.model.ExternalConfig.AppConfigKeys[specCm.Name])
+					mp, specCm.Key, wSubpath, files)
 				if err != nil {
 					return err
 				}
+				deployment.setFileObjectVolume(specCm)
+				b.addSources(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: specCm.Name},
+					Data:       data,
+				}, mp, specCm.Key)
 			}
 		}
+
+		// Inline app-config from CR spec goes last, so it overrides all the others
+		if err := b.addInlineAppConfig(backstage, scheme); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addInlineAppConfig creates a ConfigMap with spec.application.appConfig.inline content,
+// appends it to b.ConfigMaps.Items and mounts it. Does nothing if there is no inline app-config.
+func (b *AppConfig) addInlineAppConfig(backstage api.Backstage, scheme *runtime.Scheme) error {
+	if backstage.Spec.Application == nil || backstage.Spec.Application.AppConfig == nil ||
+		backstage.Spec.Application.AppConfig.Inline == nil || len(backstage.Spec.Application.AppConfig.Inline.Raw) == 0 {
+		return nil
+	}
+	appConfig := backstage.Spec.Application.AppConfig
+
+	configYaml, err := sigsyaml.JSONToYAML(appConfig.Inline.Raw)
+	if err != nil {
+		return fmt.Errorf("failed to convert inline app-config to YAML: %w", err)
+	}
+
+	inlineCM := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "inline-appconfig",
+			Namespace: backstage.Namespace,
+		},
+		Data: map[string]string{InlineAppConfigFile: string(configYaml)},
 	}
+	setMultiObjectConfigMetaInfo(&multiobject.MultiObject{Items: []client.Object{inlineCM}}, "appconfig", backstage, scheme)
+	b.ConfigMaps.Items = append(b.ConfigMaps.Items, inlineCM)
 
+	deployment := b.model.getDeployment()
+	mp, _ := deployment.mountPath("", InlineAppConfigFile, appConfig.MountPath)
+	if err := updatePodWithAppConfig(deployment, inlineCM.Name, mp, InlineAppConfigFile, true, []string{InlineAppConfigFile}); err != nil {
+		return err
+	}
+	b.addSources(inlineCM, mp, InlineAppConfigFile)
 	return nil
 }
 
@@ -173,40 +252,3 @@ func updatePodWithAppConfig(bsd *BackstageDeployment, cmName, mountPath, key str
 	}
 	return nil
 }
-
-// mergePluginConfigs recursively merges src plugin config into dst. Maps are merged deeply, other values are overwritten.
-func mergePluginConfigs(dst, src map[string]interface{}) {
-	for k, srcVal := range src {
-		srcMap := toStringKeyedMap(srcVal)
-		if dstVal, exists := dst[k]; exists {
-			dstMap := toStringKeyedMap(dstVal)
-			if srcMap != nil && dstMap != nil {
-				mergePluginConfigs(dstMap, srcMap)
-				dst[k] = dstMap
-				continue
-			}
-		}
-		// Assign new value, converting maps to string-keyed
-		if srcMap != nil {
-			dst[k] = srcMap
-		} else {
-			dst[k] = srcVal
-		}
-	}
-}
-
-// toStringKeyedMap converts map[interface{}]interface{} (from yaml.v2) to map[string]interface{}.
-// Returns the map as-is if already string-keyed, or nil if not a map.
-func toStringKeyedMap(v interface{}) map[string]interface{} {
-	switch m := v.(type) {
-	case map[string]interface{}:
-		return m
-	case map[interface{}]interface{}:
-		result := make(map[string]interface{})
-		for key, val := range m {
-			result[key.(string)] = val
-		}
-		return result
-	}
-	return nil
-}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/redhat-developer/rhdh-operator/pkg/model/multiobject"
@@ -13,6 +15,7 @@ import (
 	"github.com/redhat-developer/rhdh-operator/api"
 
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -149,6 +152,75 @@ func TestDefaultAndSpecifiedAppConfig(t *testing.T) {
 
 }
 
+func TestInlineAppConfig(t *testing.T) {
+
+	bs := *appConfigTestBackstage.DeepCopy()
+	cms := &bs.Spec.Application.AppConfig.ConfigMaps
+	*cms = append(*cms, api.FileObjectRef{Name: appConfigTestCm.Name})
+	bs.Spec.Application.AppConfig.Inline = &apiextensionsv1.JSON{Raw: []byte(`{"backend":{"listen":{"port":7007}},"app":{"title":"My Portal"}}`)}
+
+	testObj := createBackstageTest(bs).withDefaultConfig(true).addToDefaultConfig("app-config.yaml", "raw-app-config.yaml")
+	testObj.externalConfig.AppConfigKeys = map[string][]string{appConfigTestCm.Name: maps.Keys(appConfigTestCm.Data)}
+
+	model, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	deployment := model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment)
+	// the inline app-config is the last one
+	args := deployment.container().Args
+	assert.Equal(t, 6, len(args))
+	assert.Equal(t, []string{"--config", "/my/path/conf.yaml", "--config", "/my/path/" + InlineAppConfigFile}, args[2:])
+
+	cmName := DefaultMultiObjectName("appconfig", bs.Name, "inline-appconfig")
+	mount := deployment.container().VolumeMounts[2]
+	assert.Equal(t, utils.GenerateVolumeNameFromCmOrSecret(cmName), mount.Name)
+	assert.Equal(t, InlineAppConfigFile, mount.SubPath)
+
+	items := model.GetRuntimeObject(AppConfigKey).(*AppConfig).ConfigMaps.Items
+	inlineCM := items[len(items)-1].(*corev1.ConfigMap)
+	assert.Equal(t, cmName, inlineCM.Name)
+	assert.Equal(t, "app:\n  title: My Portal\nbackend:\n  listen:\n    port: 7007\n", inlineCM.Data[InlineAppConfigFile])
+}
+
+func TestLiveReloadAppConfig(t *testing.T) {
+
+	bs := *appConfigTestBackstage.DeepCopy()
+	bs.Spec.Application.ReloadStrategy = api.ReloadStrategyLive
+	bs.Spec.Application.AppConfig.ConfigMaps = []api.FileObjectRef{
+		{Name: appConfigTestCm.Name},
+		{Name: appConfigTestCm2.Name, MountPath: "/my/appconfig", Key: "conf21.yaml"},
+		{Name: appConfigTestCm3.Name, ReloadStrategy: api.ReloadStrategyRestart},
+	}
+
+	testObj := createBackstageTest(bs).withDefaultConfig(true)
+	testObj.externalConfig.AppConfigKeys = map[string][]string{appConfigTestCm.Name: maps.Keys(appConfigTestCm.Data),
+		appConfigTestCm2.Name: maps.Keys(appConfigTestCm2.Data), appConfigTestCm3.Name: maps.Keys(appConfigTestCm3.Data)}
+
+	model, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Kubernetes, testObj.scheme)
+	assert.NoError(t, err)
+
+	deployment := model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment)
+
+	// live: mounted as directories, without subPath
+	mounts := deployment.container().VolumeMounts
+	assert.Equal(t, 3, len(mounts))
+	assert.Equal(t, "/my/path/app-config1", mounts[0].MountPath)
+	assert.Empty(t, mounts[0].SubPath)
+	assert.Equal(t, "/my/appconfig", mounts[1].MountPath)
+	assert.Empty(t, mounts[1].SubPath)
+	// restart: mounted with subPath
+	assert.Equal(t, "/my/path/conf31.yaml", mounts[2].MountPath)
+	assert.Equal(t, "conf31.yaml", mounts[2].SubPath)
+
+	assert.Equal(t, []string{"--config", "/my/path/app-config1/conf.yaml", "--config", "/my/appconfig/conf21.yaml",
+		"--config", "/my/path/conf31.yaml"}, deployment.container().Args)
+
+	// only the key is mounted to the directory if specified
+	volumes := deployment.podSpec().Volumes
+	assert.Empty(t, volumes[0].ConfigMap.Items)
+	assert.Equal(t, []corev1.KeyToPath{{Key: "conf21.yaml", Path: "conf21.yaml"}}, volumes[1].ConfigMap.Items)
+}
+
 // TestMultiEntryAppConfigNotAllowed verifies that ConfigMaps with multiple entries
 // are rejected to ensure predictable order in the app-config chain.
 func TestMultiEntryAppConfigNotAllowed(t *testing.T) {
@@ -239,4 +311,245 @@ func TestAddPluginsAppConfig(t *testing.T) {
 		assert.Contains(t, cm.Data[PluginsAppConfigFile], "plugin-a")
 		assert.Contains(t, cm.Data[PluginsAppConfigFile], "plugin-b")
 	})
+	t.Run("same value set by two plugins - no conflict", func(t *testing.T) {
+		appConfig := pluginsAppConfig(
+			DynaPlugin{Package: "plugin-a", PluginConfig: map[string]interface{}{
+				"app": map[interface{}]interface{}{"title": "RHDH", "baseUrl": "https://a"}}},
+			DynaPlugin{Package: "plugin-b", PluginConfig: map[string]interface{}{
+				"app": map[interface{}]interface{}{"title": "RHDH"}}},
+		)
+		assert.NoError(t, appConfig.addPluginsAppConfig("test-ns"))
+		assert.Equal(t, 1, len(appConfig.ConfigMaps.Items))
+	})
+
+	t.Run("conflicting keys", func(t *testing.T) {
+		appConfig := pluginsAppConfig(
+			DynaPlugin{Package: "plugin-a", PluginConfig: map[string]interface{}{
+				"app": map[interface{}]interface{}{"title": "A", "support": map[interface{}]interface{}{"url": "https://a"}}}},
+			DynaPlugin{Package: "plugin-b", PluginConfig: map[string]interface{}{
+				"app": map[interface{}]interface{}{"title": "B", "support": "https://b"}}},
+		)
+		// the conflicts are reported, not failing the model, and the later plugin wins
+		assert.NoError(t, appConfig.addPluginsAppConfig("test-ns"))
+		assert.Equal(t, []string{
+			"conflicting pluginConfig key 'app.support' set by plugins plugin-a and plugin-b",
+			"conflicting pluginConfig key 'app.title' set by plugins plugin-a and plugin-b",
+		}, appConfig.PluginConfigErrors())
+		cm := appConfig.ConfigMaps.Items[0].(*corev1.ConfigMap)
+		assert.Contains(t, cm.Data[PluginsAppConfigFile], "title: B")
+		assert.Contains(t, cm.Data[PluginsAppConfigFile], "support: https://b")
+	})
+
+	t.Run("pluginConfig validated against configSchema", func(t *testing.T) {
+		schema := map[string]interface{}{
+			"type": "object",
+			"properties": map[interface{}]interface{}{
+				"catalog": map[interface{}]interface{}{
+					"type": "object",
+					"properties": map[interface{}]interface{}{
+						"refreshIntervalSeconds": map[interface{}]interface{}{"type": "integer"},
+					},
+				},
+			},
+		}
+		appConfig := pluginsAppConfig(
+			DynaPlugin{Package: "plugin-a", ConfigSchema: schema, PluginConfig: map[string]interface{}{
+				"catalog": map[interface{}]interface{}{"refreshIntervalSeconds": 60}}},
+			DynaPlugin{Package: "plugin-b", PluginConfig: map[string]interface{}{
+				"other": map[interface{}]interface{}{"key": "value"}}},
+		)
+		assert.NoError(t, appConfig.addPluginsAppConfig("test-ns"))
+		assert.Empty(t, appConfig.PluginConfigErrors())
+
+		appConfig = pluginsAppConfig(
+			DynaPlugin{Package: "plugin-a", ConfigSchema: schema},
+			DynaPlugin{Package: "plugin-b", PluginConfig: map[string]interface{}{
+				"catalog": map[interface{}]interface{}{"refreshIntervalSeconds": "often"}}},
+		)
+		assert.NoError(t, appConfig.addPluginsAppConfig("test-ns"))
+		errs := strings.Join(appConfig.PluginConfigErrors(), "; ")
+		assert.Contains(t, errs, "pluginConfig does not match configSchema of plugin plugin-a")
+		assert.Contains(t, errs, "catalog.refreshIntervalSeconds")
+		assert.Equal(t, 1, len(appConfig.ConfigMaps.Items))
+
+		// the schema of the package is used if the entry has none
+		appConfig = pluginsAppConfig(
+			DynaPlugin{Package: "plugin-a"},
+			DynaPlugin{Package: "plugin-b", PluginConfig: map[string]interface{}{
+				"catalog": map[interface{}]interface{}{"refreshIntervalSeconds": "often"}}},
+		)
+		appConfig.model.ExternalConfig.PluginSchemas = testSchemaReader{"plugin-a": schema}
+		assert.NoError(t, appConfig.addPluginsAppConfig("test-ns"))
+		assert.Len(t, appConfig.PluginConfigErrors(), 1)
+		assert.Contains(t, appConfig.PluginConfigErrors()[0], "pluginConfig does not match configSchema of plugin plugin-a")
+
+		// a package schema failing to be read is reported
+		appConfig = pluginsAppConfig(DynaPlugin{Package: "plugin-c"})
+		appConfig.model.ExternalConfig.PluginSchemas = testSchemaReader{}
+		assert.NoError(t, appConfig.addPluginsAppConfig("test-ns"))
+		assert.Equal(t, []string{"failed to read configSchema of plugin plugin-c: registry not reachable"}, appConfig.PluginConfigErrors())
+	})
+}
+
+// testSchemaReader returns the schemas of the packages, failing for the unknown ones but plugin-b
+type testSchemaReader map[string]map[string]interface{}
+
+func (r testSchemaReader) ReadConfigSchema(pkg string) (map[string]interface{}, error) {
+	if schema, ok := r[pkg]; ok || pkg == "plugin-b" {
+		return schema, nil
+	}
+	return nil, fmt.Errorf("registry not reachable")
+}
+
+func pluginsAppConfig(plugins ...DynaPlugin) *AppConfig {
+	model := &BackstageModel{RuntimeObjects: []RuntimeObject{}}
+	model.RuntimeObjects = append(model.RuntimeObjects, &DynamicPlugins{
+		enabledPlugins:   plugins,
+		enabledPluginsCM: &corev1.ConfigMap{},
+	})
+	return &AppConfig{
+		model:      model,
+		ConfigMaps: &multiobject.MultiObject{Items: []client.Object{}},
+	}
+}
+
+func TestEffectiveAppConfig(t *testing.T) {
+
+	bs := *appConfigTestBackstage.DeepCopy()
+	bs.Spec.Application.AppConfig.ConfigMaps = []api.FileObjectRef{{Name: "app-config1"}}
+	bs.Spec.Application.AppConfig.Inline = &apiextensionsv1.JSON{Raw: []byte(`{"app":{"title":"My Portal"}}`)}
+
+	testObj := createBackstageTest(bs).withDefaultConfig(true).addToDefaultConfig("app-config.yaml", "raw-app-config.yaml")
+	testObj.externalConfig.AppConfigKeys = map[string][]string{"app-config1": {"conf.yaml"}}
+	testObj.externalConfig.AppConfigData = map[string]map[string]string{"app-config1": {"conf.yaml": `
+app:
+  title: Portal # overridden by the inline app-config
+  apiKey: abc
+backend:
+  auth:
+    externalAccess:
+      - type: static
+        options:
+          token: ${MY_TOKEN}
+  database:
+    connection:
+      password:
+        $env: MY_PASSWORD
+`}}
+
+	// not published unless requested
+	model, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+	assert.Len(t, model.GetRuntimeObject(AppConfigKey).(*AppConfig).ConfigMaps.Items, 2)
+
+	effective, err := model.GetRuntimeObject(AppConfigKey).(*AppConfig).EffectiveConfig()
+	assert.NoError(t, err)
+	defaultSource := DefaultMultiObjectName("appconfig", bs.Name, "my-backstage-config-cm1") + "/default.app-config.yaml"
+	inlineSource := DefaultMultiObjectName("appconfig", bs.Name, "inline-appconfig") + "/" + InlineAppConfigFile
+	assert.Equal(t, `# Effective app-config, merged in the order of the --config arguments:
+# - /opt/app-root/src/default.app-config.yaml (`+defaultSource+`)
+# - /my/path/conf.yaml (app-config1/conf.yaml)
+# - /my/path/`+InlineAppConfigFile+` (`+inlineSource+`)
+
+backend:
+  database:
+    connection:
+      password:
+        $env: MY_PASSWORD # app-config1/conf.yaml
+      user: ${POSTGRES_USER} # `+defaultSource+`
+  auth:
+    externalAccess:
+      - type: static # app-config1/conf.yaml
+        options:
+          token: ${MY_TOKEN} # app-config1/conf.yaml
+app:
+  title: My Portal # `+inlineSource+`
+  apiKey: '********' # app-config1/conf.yaml
+`, effective)
+
+	bs.Spec.Application.AppConfig.PublishEffectiveConfig = true
+	model, err = InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+	items := model.GetRuntimeObject(AppConfigKey).(*AppConfig).ConfigMaps.Items
+	assert.Len(t, items, 3)
+	effectiveCM := items[2].(*corev1.ConfigMap)
+	assert.Equal(t, DefaultMultiObjectName("appconfig", bs.Name, "effective-appconfig"), effectiveCM.Name)
+	assert.Equal(t, effective, effectiveCM.Data[EffectiveAppConfigFile])
+	// the effective app-config is not mounted
+	for _, v := range model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment).podSpec().Volumes {
+		assert.NotEqual(t, utils.GenerateVolumeNameFromCmOrSecret(effectiveCM.Name), v.Name)
+	}
+}
+
+func TestEffectiveAppConfigMasking(t *testing.T) {
+	bs := *appConfigTestBackstage.DeepCopy()
+	testObj := createBackstageTest(bs).withDefaultConfig(true).addToDefaultConfig("app-config.yaml", "raw-app-config.yaml")
+	model, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	effective, err := model.GetRuntimeObject(AppConfigKey).(*AppConfig).EffectiveConfig()
+	assert.NoError(t, err)
+	assert.NotContains(t, effective, "pl4s3Ch4ng3M3")
+	assert.Contains(t, effective, "secret: '********'")
+	assert.Contains(t, effective, "password: ${POSTGRES_PASSWORD}")
+	// the comments of the sources are dropped
+	assert.NotContains(t, effective, "This is a default value")
+}
+
+func TestAppConfigReferences(t *testing.T) {
+
+	bs := *appConfigTestBackstage.DeepCopy()
+	bs.Spec.Application.AppConfig.ConfigMaps = []api.FileObjectRef{{Name: "app-config1"}}
+	bs.Spec.Application.ExtraEnvs = &api.ExtraEnvs{
+		Envs:    []api.Env{{Name: "BACKEND_SECRET", Value: "abc"}},
+		Secrets: []api.EnvObjectRef{{Name: "github-secrets"}},
+	}
+	bs.Spec.Application.ExtraFiles = &api.ExtraFiles{
+		ConfigMaps: []api.FileObjectRef{{Name: "catalog", MountPath: "/catalog"}},
+	}
+
+	testObj := createBackstageTest(bs).withDefaultConfig(true).addToDefaultConfig("app-config.yaml", "raw-app-config.yaml")
+	testObj.externalConfig.AppConfigKeys = map[string][]string{"app-config1": {"conf.yaml"}}
+	testObj.externalConfig.AppConfigData = map[string]map[string]string{"app-config1": {"conf.yaml": `
+backend:
+  auth:
+    keys:
+      - secret: ${BACKEND_SECRET}
+  baseUrl: https://${HOST}:${ PORT }/$${NOT_A_VAR}
+  reading:
+    token: ${TOKEN:-default}
+auth:
+  providers:
+    github:
+      production:
+        clientId: ${GH_CLIENT_ID}
+        clientSecret:
+          $env: GH_CLIENT_SECRET
+catalog:
+  locations:
+    - target:
+        $file: /catalog/users.yaml
+    - target:
+        $include: /catalog/groups.yaml#groups
+    - target:
+        $file: extra/from-image.yaml
+`}}
+	testObj.externalConfig.ExtraEnvSecretKeys = map[string]DataObjectKeys{"github-secrets": {BinaryDataKey: []string{"GH_CLIENT_ID", "GH_CLIENT_SECRET"}}}
+	testObj.externalConfig.ExtraFileConfigMapKeys = map[string]DataObjectKeys{"catalog": {StringDataKey: []string{"users.yaml"}}}
+
+	model, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+	appConfig := model.GetRuntimeObject(AppConfigKey).(*AppConfig)
+	assert.Equal(t, []string{
+		"app-config1/conf.yaml: environment variable HOST is not set",
+		"app-config1/conf.yaml: environment variable PORT is not set",
+		"app-config1/conf.yaml: file /catalog/groups.yaml not found",
+	}, appConfig.ValidateReferences())
+
+	// the POSTGRES_* variables of the default app-config come from the generated database Secret;
+	// an envFrom source with unknown keys (e.g. an external database Secret) disables the environment variables check
+	deployment := model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment)
+	deployment.container().EnvFrom = append(deployment.container().EnvFrom, corev1.EnvFromSource{
+		SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "unknown"}}})
+	assert.Equal(t, []string{"app-config1/conf.yaml: file /catalog/groups.yaml not found"}, appConfig.ValidateReferences())
 }
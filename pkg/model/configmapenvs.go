@@ -74,7 +74,7 @@ func (p *ConfigMapEnvs) updateAndValidate(backstage api.Backstage, scheme *runti
 	// Process configmaps from CR spec (formerly addExternalConfig)
 	if backstage.Spec.Application != nil && backstage.Spec.Application.ExtraEnvs != nil && backstage.Spec.Application.ExtraEnvs.ConfigMaps != nil {
 		for _, specCm := range backstage.Spec.Application.ExtraEnvs.ConfigMaps {
-			err := deployment.addEnvVarsFrom(containersFilter{names: specCm.Containers}, ConfigMapObjectKind, specCm.Name, specCm.Key)
+			err := deployment.addEnvVarsFromRef(containersFilter{names: specCm.Containers}, ConfigMapObjectKind, specCm)
 			if err != nil {
 				return fmt.Errorf("failed to add env vars on config map %s: %w", specCm.Name, err)
 			}
@@ -82,13 +82,14 @@ func (p *ConfigMapFiles) updateAndValidate(backstage api.Backstage, scheme *runt
 	if backstage.Spec.Application != nil && backstage.Spec.Application.ExtraFiles != nil && backstage.Spec.Application.ExtraFiles.ConfigMaps != nil {
 		for _, specCm := range backstage.Spec.Application.ExtraFiles.ConfigMaps {
 
-			mp, wSubpath := deployment.mountPath(specCm.MountPath, specCm.Key, backstage.Spec.Application.ExtraFiles.MountPath)
+			mp, wSubpath := deployment.fileObjectMountPath(backstage.Spec, specCm, backstage.Spec.Application.ExtraFiles.MountPath)
 			keys := p.model.ExternalConfig.ExtraFileConfigMapKeys[specCm.Name].All()
 			err := deployment.mountFilesFrom(containersFilter{names: specCm.Containers}, ConfigMapObjectKind,
 				specCm.Name, mp, specCm.Key, wSubpath, keys)
 			if err != nil {
 				return fmt.Errorf("failed to mount files on configmap %s: %w", specCm.Name, err)
 			}
+			deployment.setFileObjectVolume(specCm)
 		}
 	}
 
@@ -33,7 +33,10 @@ func ReadDefaultConfig(conf ObjectConfig, flavours []enabledFlavour, scheme runt
 	}
 
 	// Step 2: Collect config sources from flavours and base
-	configSources := collectConfigSources(conf.Key, basePath, flavours)
+	configSources, err := collectConfigSources(conf.Key, basePath, flavours)
+	if err != nil {
+		return nil, err
+	}
 
 	// Step 3: If no configs found, return empty array (config file is optional)
 	if len(configSources) == 0 {
@@ -51,9 +54,10 @@ type configSource struct {
 	content     []byte // pre-read YAML content
 }
 
-// collectConfigSources collects all config file sources with flavour names and reads their content
-// Returns sources in merge order: base first, then flavours (in spec order)
-func collectConfigSources(key string, basePath string, flavours []enabledFlavour) []configSource {
+// collectConfigSources collects all config file sources with flavour names and reads their content,
+// substituting the flavour parameters.
+// Returns sources in merge order: base first, then flavours (in the enabled flavours order)
+func collectConfigSources(key string, basePath string, flavours []enabledFlavour) ([]configSource, error) {
 	var sources []configSource
 
 	// Read base config if it exists
@@ -69,19 +73,35 @@ func collectConfigSources(key string, basePath string, flavours []enabledFlavour
 
 	// Read each flavour config if it exists
 	for _, flavour := range flavours {
-		flavourConfigPath := filepath.Join(flavour.basePath, key)
-		if _, err := os.Stat(flavourConfigPath); err == nil {
-			if content, err := os.ReadFile(flavourConfigPath); err == nil {
-				sources = append(sources, configSource{
-					path:        flavourConfigPath,
-					flavourName: flavour.name,
-					content:     content,
-				})
+		var src configSource
+		if flavour.files != nil {
+			content, ok := flavour.files[key]
+			if !ok {
+				continue
+			}
+			src = configSource{path: "ConfigMap " + flavour.configMap + " key " + key, content: content}
+		} else {
+			flavourConfigPath := filepath.Join(flavour.basePath, key)
+			if _, err := os.Stat(flavourConfigPath); err != nil {
+				continue
+			}
+			content, err := os.ReadFile(flavourConfigPath)
+			if err != nil {
+				continue
 			}
+			src = configSource{path: flavourConfigPath, content: content}
+		}
+
+		content, err := substituteParameters(src.content, flavour.parameters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to substitute parameters of flavour '%s' in %s: %w", flavour.name, src.path, err)
 		}
+		src.flavourName = flavour.name
+		src.content = content
+		sources = append(sources, src)
 	}
 
-	return sources
+	return sources, nil
 }
 
 // mergeDynamicPlugins merges dynamic-plugins.yaml files by package name
@@ -252,7 +252,7 @@ func TestGetEnabledFlavours(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			flavours, err := GetEnabledFlavours(tt.spec)
+			flavours, err := GetEnabledFlavours(tt.spec, nil)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -244,6 +244,61 @@ func (b *BackstageDeployment) mountPath(objectMountPath, objectKey, sharedMountP
 	return mp, wSubpath
 }
 
+// fileObjectMountPath returns the mount path of the referenced ConfigMap or Secret and whether it is mounted with subPath.
+// The objects reloaded live or with items are mounted as directories, to default-path/Name if the mountPath is not specified.
+func (b *BackstageDeployment) fileObjectMountPath(spec api.BackstageSpec, ref api.FileObjectRef, sharedMountPath string) (string, bool) {
+	mp, wSubpath := b.mountPath(ref.MountPath, ref.Key, sharedMountPath)
+	if !spec.IsLiveReload(ref) && len(ref.Items) == 0 {
+		return mp, wSubpath
+	}
+	if ref.MountPath == "" {
+		mp = filepath.Join(mp, ref.Name)
+	}
+	return mp, false
+}
+
+// setFileObjectVolume sets the items and the file modes of the volume the referenced ConfigMap or Secret is mounted from
+func (b *BackstageDeployment) setFileObjectVolume(ref api.FileObjectRef) {
+	if len(ref.Items) == 0 && ref.DefaultMode == nil {
+		return
+	}
+	var items []corev1.KeyToPath
+	for _, item := range ref.Items {
+		items = append(items, corev1.KeyToPath{Key: item.Key, Path: fileObjectItemPath(item), Mode: item.Mode})
+	}
+
+	volName := utils.GenerateVolumeNameFromCmOrSecret(ref.Name)
+	for i, v := range b.podSpec().Volumes {
+		if v.Name != volName {
+			continue
+		}
+		switch {
+		case v.ConfigMap != nil:
+			if items != nil {
+				b.podSpec().Volumes[i].ConfigMap.Items = items
+			}
+			if ref.DefaultMode != nil {
+				b.podSpec().Volumes[i].ConfigMap.DefaultMode = ref.DefaultMode
+			}
+		case v.Secret != nil:
+			if items != nil {
+				b.podSpec().Volumes[i].Secret.Items = items
+			}
+			if ref.DefaultMode != nil {
+				b.podSpec().Volumes[i].Secret.DefaultMode = ref.DefaultMode
+			}
+		}
+	}
+}
+
+// fileObjectItemPath returns the path of the file the item is mounted as, the key by default
+func fileObjectItemPath(item api.FileObjectItem) string {
+	if item.Path != "" {
+		return item.Path
+	}
+	return item.Key
+}
+
 // setDeployment sets the deployment object from the backstage configuration
 // it merges the deployment object with the patch from the backstage configuration
 func (b *BackstageDeployment) setDeployment(backstage api.Backstage) error {
@@ -405,6 +460,16 @@ func (b *BackstageDeployment) mountFilesFrom(containersFilter containersFilter,
 		}
 	}
 
+	// the directory contains only the file if specified
+	if !withSubPath && fileName != "" {
+		items := []corev1.KeyToPath{{Key: fileName, Path: fileName}}
+		if volSrc.ConfigMap != nil {
+			volSrc.ConfigMap.Items = items
+		} else if volSrc.Secret != nil {
+			volSrc.Secret.Items = items
+		}
+	}
+
 	b.podSpec().Volumes = append(b.podSpec().Volumes, corev1.Volume{Name: volName, VolumeSource: volSrc})
 
 	for _, container := range containers {
@@ -457,48 +522,66 @@ func (b *BackstageDeployment) mountFilesFrom(containersFilter containersFilter,
 // objectName - name of source object
 // varName - name of env variable
 func (b *BackstageDeployment) addEnvVarsFrom(containersFilter containersFilter, kind ObjectKind, objectName, varName string) error {
+	return b.addEnvVarsFromRef(containersFilter, kind, api.EnvObjectRef{Name: objectName, Key: varName})
+}
+
+// addEnvVarsFromRef adds the environment variables of the referenced object to specified containers:
+// all the keys with envFrom (with the prefix if specified), or the key or items as individual variables
+// named prefix + item name (the key by default)
+func (b *BackstageDeployment) addEnvVarsFromRef(containersFilter containersFilter, kind ObjectKind, ref api.EnvObjectRef) error {
+
+	if kind != ConfigMapObjectKind && kind != SecretObjectKind {
+		return fmt.Errorf("unknown object kind %s to add env vars from", kind)
+	}
 
 	containers, err := containersFilter.getContainers(b)
 	if err != nil {
-		return fmt.Errorf("can not get containers to add env %s: %w", varName, err)
+		return fmt.Errorf("can not get containers to add env %s: %w", ref.Key, err)
+	}
+
+	type envVarKey struct{ name, key string }
+	var vars []envVarKey
+	if ref.Key != "" {
+		vars = append(vars, envVarKey{name: ref.Prefix + ref.Key, key: ref.Key})
+	}
+	for _, item := range ref.Items {
+		name := item.As
+		if name == "" {
+			name = item.Key
+		}
+		vars = append(vars, envVarKey{name: ref.Prefix + name, key: item.Key})
 	}
 
 	for _, container := range containers {
-		if varName == "" {
-			envFromSrc := corev1.EnvFromSource{}
-			switch kind {
-			case ConfigMapObjectKind:
+		if len(vars) == 0 {
+			envFromSrc := corev1.EnvFromSource{Prefix: ref.Prefix}
+			if kind == ConfigMapObjectKind {
 				envFromSrc.ConfigMapRef = &corev1.ConfigMapEnvSource{
-					LocalObjectReference: corev1.LocalObjectReference{Name: objectName},
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
 				}
-			case SecretObjectKind:
+			} else {
 				envFromSrc.SecretRef = &corev1.SecretEnvSource{
-					LocalObjectReference: corev1.LocalObjectReference{Name: objectName},
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
 				}
-			default:
-				// unknown kind
-				return fmt.Errorf("unknown object kind %s to add env vars from", kind)
 			}
 			container.EnvFrom = append(container.EnvFrom, envFromSrc)
-		} else {
+			continue
+		}
+		for _, v := range vars {
 			envVarSrc := &corev1.EnvVarSource{}
-			switch kind {
-			case ConfigMapObjectKind:
+			if kind == ConfigMapObjectKind {
 				envVarSrc.ConfigMapKeyRef = &corev1.ConfigMapKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{Name: objectName},
-					Key:                  varName,
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Key:                  v.key,
 				}
-			case SecretObjectKind:
+			} else {
 				envVarSrc.SecretKeyRef = &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{Name: objectName},
-					Key:                  varName,
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Key:                  v.key,
 				}
-			default:
-				// unknown kind
-				return fmt.Errorf("unknown object kind %s to add env vars from", kind)
 			}
 			container.Env = append(container.Env, corev1.EnvVar{
-				Name:      varName,
+				Name:      v.name,
 				ValueFrom: envVarSrc,
 			})
 		}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// randomPluginsData generates dynamic-plugins.yaml content with random plugins, includes and pluginConfig
+func randomPluginsData(r *rand.Rand) string {
+	config := DynaPluginsConfig{}
+	for i := 0; i < r.Intn(5); i++ {
+		config.Includes = append(config.Includes, fmt.Sprintf("include-%d.yaml", r.Intn(10)))
+	}
+	for i := 0; i < r.Intn(10); i++ {
+		plugin := DynaPlugin{
+			Package:  fmt.Sprintf("./plugin-%d", r.Intn(15)),
+			Disabled: r.Intn(2) == 0,
+		}
+		if r.Intn(2) == 0 {
+			plugin.PluginConfig = map[string]interface{}{}
+			for j := 0; j < r.Intn(5); j++ {
+				plugin.PluginConfig[fmt.Sprintf("key-%d", r.Intn(10))] = map[string]interface{}{
+					fmt.Sprintf("sub-%d", r.Intn(10)): r.Intn(100),
+				}
+			}
+		}
+		config.Plugins = append(config.Plugins, plugin)
+	}
+	data, _ := yaml.Marshal(config)
+	return string(data)
+}
+
+// TestMergePluginsDataDeterministic checks that merging the same inputs always produces byte-identical output,
+// plugins keep the order they are first seen in and includes are sorted
+func TestMergePluginsDataDeterministic(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 200; i++ {
+		first, second := randomPluginsData(r), randomPluginsData(r)
+
+		expected, err := MergePluginsData(first, second)
+		assert.NoError(t, err)
+		for j := 0; j < 10; j++ {
+			merged, err := MergePluginsData(first, second)
+			assert.NoError(t, err)
+			if !assert.Equal(t, expected, merged, "merge is not deterministic for:\n%s\n---\n%s", first, second) {
+				return
+			}
+		}
+
+		var firstConfig, secondConfig, mergedConfig DynaPluginsConfig
+		assert.NoError(t, yaml.Unmarshal([]byte(first), &firstConfig))
+		assert.NoError(t, yaml.Unmarshal([]byte(second), &secondConfig))
+		assert.NoError(t, yaml.Unmarshal([]byte(expected), &mergedConfig))
+
+		var expectedOrder []string
+		seen := map[string]bool{}
+		for _, p := range append(firstConfig.Plugins, secondConfig.Plugins...) {
+			if !seen[p.Package] {
+				seen[p.Package] = true
+				expectedOrder = append(expectedOrder, p.Package)
+			}
+		}
+		var mergedOrder []string
+		for _, p := range mergedConfig.Plugins {
+			mergedOrder = append(mergedOrder, p.Package)
+		}
+		assert.Equal(t, expectedOrder, mergedOrder)
+		assert.IsNonDecreasing(t, mergedConfig.Includes)
+	}
+}
+
+// TestMergedPluginConfigDeterministic checks that the generated plugins app-config is byte-identical
+// whatever the order of keys in the plugins' pluginConfig maps is
+func TestMergedPluginConfigDeterministic(t *testing.T) {
+	t.Setenv(OperatorDPProcessingEnvVar, "true")
+	r := rand.New(rand.NewSource(7))
+
+	var expected string
+	for i := 0; i < 50; i++ {
+		pluginConfig := map[string]interface{}{}
+		// the same keys inserted in random order
+		for _, k := range r.Perm(20) {
+			pluginConfig[fmt.Sprintf("key-%d", k)] = map[interface{}]interface{}{"value": k}
+		}
+		appConfig := pluginsAppConfig(DynaPlugin{Package: "plugin-a", PluginConfig: pluginConfig})
+		assert.NoError(t, appConfig.addPluginsAppConfig("ns"))
+
+		rendered := appConfig.ConfigMaps.Items[0].(*corev1.ConfigMap).Data[PluginsAppConfigFile]
+		if i == 0 {
+			expected = rendered
+		}
+		assert.Equal(t, expected, rendered)
+	}
+}
+
+func TestEnabledFlavoursOrder(t *testing.T) {
+	createBackstageTest(api.Backstage{}).withConfigPath("./testdata/testflavours")
+
+	flavourNames := func(spec api.BackstageSpec) []string {
+		flavours, err := GetEnabledFlavours(spec, nil)
+		assert.NoError(t, err)
+		names := []string{}
+		for _, f := range flavours {
+			names = append(names, f.name)
+		}
+		return names
+	}
+
+	// enabled by default: ordered by name
+	assert.Equal(t, []string{"flavor1", "flavor3"}, flavourNames(api.BackstageSpec{}))
+
+	// spec order first, then the ones enabled by default ordered by name
+	spec := api.BackstageSpec{Flavours: &[]api.Flavour{
+		{Name: "flavor3", Enabled: true},
+		{Name: "flavor2", Enabled: true},
+	}}
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, []string{"flavor3", "flavor2", "flavor1"}, flavourNames(spec))
+	}
+}
+
+// TestRepeatedRenderByteIdentical checks that rendering the model from the same input
+// always produces byte-identical objects
+func TestRepeatedRenderByteIdentical(t *testing.T) {
+	t.Setenv(OperatorDPProcessingEnvVar, "true")
+
+	bs := testFlavoursBackstage.DeepCopy()
+	bs.Spec.Flavours = &[]api.Flavour{{Name: "flavor2", Enabled: true}}
+
+	render := func() []string {
+		testObj := createBackstageTest(*bs).withConfigPath("./testdata/testflavours").withLocalDb(false)
+		model, err := InitObjects(context.TODO(), testObj.backstage, testObj.externalConfig, platform.Kubernetes, testObj.scheme)
+		assert.NoError(t, err)
+		var rendered []string
+		for _, obj := range model.GetRuntimeObjects() {
+			data, err := sigsyaml.Marshal(obj.Object())
+			assert.NoError(t, err)
+			rendered = append(rendered, string(data))
+		}
+		return rendered
+	}
+
+	expected := render()
+	assert.NotEmpty(t, expected)
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, expected, render())
+	}
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	DockerConfigJsonKey = corev1.DockerConfigJsonKey
+	NpmrcKey            = ".npmrc"
+
+	RegistryAuthFileEnvVar    = "REGISTRY_AUTH_FILE"
+	NpmConfigUserConfigEnvVar = "NPM_CONFIG_USERCONFIG"
+
+	// registry auth files are mounted to the subdirectories of the plugin installer's working directory
+	registryAuthDir = ".dynamic-plugins-auth"
+)
+
+// RegistryAuthSecrets returns the names of the Secrets referenced in spec.application.dynamicPlugins.registryAuth
+func RegistryAuthSecrets(backstage api.Backstage) []string {
+	if backstage.Spec.Application == nil || backstage.Spec.Application.DynamicPlugins == nil ||
+		backstage.Spec.Application.DynamicPlugins.RegistryAuth == nil {
+		return []string{}
+	}
+	auth := backstage.Spec.Application.DynamicPlugins.RegistryAuth
+	secrets := []string{}
+	if auth.DockerConfigSecret != "" {
+		secrets = append(secrets, auth.DockerConfigSecret)
+	}
+	if auth.NpmrcSecret != "" && auth.NpmrcSecret != auth.DockerConfigSecret {
+		secrets = append(secrets, auth.NpmrcSecret)
+	}
+	return secrets
+}
+
+// mountRegistryAuth mounts the registry credentials Secrets into the plugin installer container
+// and points the installer to them with REGISTRY_AUTH_FILE and NPM_CONFIG_USERCONFIG env vars
+func (p *DynamicPlugins) mountRegistryAuth(backstage api.Backstage, deployment *BackstageDeployment, initContainer *corev1.Container) error {
+	if backstage.Spec.Application == nil || backstage.Spec.Application.DynamicPlugins == nil ||
+		backstage.Spec.Application.DynamicPlugins.RegistryAuth == nil {
+		return nil
+	}
+	auth := backstage.Spec.Application.DynamicPlugins.RegistryAuth
+
+	workingDir := initContainer.WorkingDir
+	if workingDir == "" {
+		workingDir = DefaultMountDir
+	}
+
+	files := []struct{ secretName, key, envVar string }{
+		{auth.DockerConfigSecret, DockerConfigJsonKey, RegistryAuthFileEnvVar},
+		{auth.NpmrcSecret, NpmrcKey, NpmConfigUserConfigEnvVar},
+	}
+
+	// the keys to mount by Secret, a Secret holding both files is mounted once
+	var secretNames []string
+	keys := map[string][]string{}
+	for _, f := range files {
+		if f.secretName == "" {
+			continue
+		}
+		if !slices.Contains(p.model.ExternalConfig.RegistryAuthSecretKeys[f.secretName].All(), f.key) {
+			return fmt.Errorf("registry auth secret %s expects '%s' Data key", f.secretName, f.key)
+		}
+		if _, ok := keys[f.secretName]; !ok {
+			secretNames = append(secretNames, f.secretName)
+		}
+		keys[f.secretName] = append(keys[f.secretName], f.key)
+	}
+
+	for _, secretName := range secretNames {
+		mountPath := filepath.Join(workingDir, registryAuthDir, secretName)
+		if err := deployment.mountFilesFrom(containersFilter{names: []string{dynamicPluginInitContainerName}}, SecretObjectKind,
+			secretName, mountPath, "", true, keys[secretName]); err != nil {
+			return fmt.Errorf("failed to mount registry auth secret %s: %w", secretName, err)
+		}
+	}
+	for _, f := range files {
+		if f.secretName != "" {
+			deployment.setOrAppendEnvVar(initContainer, f.envVar, filepath.Join(workingDir, registryAuthDir, f.secretName, f.key))
+		}
+	}
+	return nil
+}
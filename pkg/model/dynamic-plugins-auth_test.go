@@ -0,0 +1,112 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryAuthMounted(t *testing.T) {
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{
+		RegistryAuth: &api.PluginRegistryAuth{
+			DockerConfigSecret: "my-pull-secret",
+			NpmrcSecret:        "my-npmrc",
+		},
+	}
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+	testObj.externalConfig.RegistryAuthSecretKeys = map[string]DataObjectKeys{
+		"my-pull-secret": NewDataObjectKeys(nil, map[string][]byte{DockerConfigJsonKey: []byte("{}")}),
+		"my-npmrc":       NewDataObjectKeys(nil, map[string][]byte{NpmrcKey: []byte("registry=https://npm.local")}),
+	}
+
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	ic := initContainer(model)
+	assert.NotNil(t, ic)
+
+	authFile := findEnvVar(ic.Env, RegistryAuthFileEnvVar)
+	assert.NotNil(t, authFile)
+	mount := findVolumeMountByPath(ic.VolumeMounts, authFile.Value)
+	assert.NotNil(t, mount)
+	assert.Equal(t, DockerConfigJsonKey, mount.SubPath)
+	assert.Equal(t, "my-pull-secret", mount.Name)
+
+	npmrc := findEnvVar(ic.Env, NpmConfigUserConfigEnvVar)
+	assert.NotNil(t, npmrc)
+	assert.Equal(t, "/opt/app-root/src/.dynamic-plugins-auth/my-npmrc/.npmrc", npmrc.Value)
+	mount = findVolumeMountByPath(ic.VolumeMounts, npmrc.Value)
+	assert.NotNil(t, mount)
+	assert.Equal(t, NpmrcKey, mount.SubPath)
+
+	// only installer gets the credentials
+	bsc := model.getDeployment().container()
+	assert.Nil(t, findEnvVar(bsc.Env, RegistryAuthFileEnvVar))
+	assert.Nil(t, findVolumeMountByPath(bsc.VolumeMounts, authFile.Value))
+}
+
+func TestRegistryAuthSameSecret(t *testing.T) {
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{
+		RegistryAuth: &api.PluginRegistryAuth{
+			DockerConfigSecret: "my-auth",
+			NpmrcSecret:        "my-auth",
+		},
+	}
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+	testObj.externalConfig.RegistryAuthSecretKeys = map[string]DataObjectKeys{
+		"my-auth": NewDataObjectKeys(nil, map[string][]byte{DockerConfigJsonKey: []byte("{}"), NpmrcKey: []byte("registry=https://npm.local")}),
+	}
+
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	// the Secret is mounted with a single volume
+	volumes := 0
+	for _, v := range model.getDeployment().podSpec().Volumes {
+		if v.Name == "my-auth" {
+			volumes++
+		}
+	}
+	assert.Equal(t, 1, volumes)
+
+	ic := initContainer(model)
+	assert.NotNil(t, ic)
+	authFile := findEnvVar(ic.Env, RegistryAuthFileEnvVar)
+	assert.Equal(t, "/opt/app-root/src/.dynamic-plugins-auth/my-auth/.dockerconfigjson", authFile.Value)
+	mount := findVolumeMountByPath(ic.VolumeMounts, authFile.Value)
+	assert.NotNil(t, mount)
+	assert.Equal(t, DockerConfigJsonKey, mount.SubPath)
+	npmrc := findEnvVar(ic.Env, NpmConfigUserConfigEnvVar)
+	assert.Equal(t, "/opt/app-root/src/.dynamic-plugins-auth/my-auth/.npmrc", npmrc.Value)
+	mount = findVolumeMountByPath(ic.VolumeMounts, npmrc.Value)
+	assert.NotNil(t, mount)
+	assert.Equal(t, NpmrcKey, mount.SubPath)
+}
+
+func TestRegistryAuthMissingKey(t *testing.T) {
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{
+		RegistryAuth: &api.PluginRegistryAuth{DockerConfigSecret: "my-pull-secret"},
+	}
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+	testObj.externalConfig.RegistryAuthSecretKeys = map[string]DataObjectKeys{
+		"my-pull-secret": NewDataObjectKeys(nil, map[string][]byte{"auth.json": []byte("{}")}),
+	}
+
+	_, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.ErrorContains(t, err, "registry auth secret my-pull-secret expects '.dockerconfigjson' Data key")
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+// dynamicPluginsRootVolumeName is the name of the Pod volume the plugin installer installs plugins to
+// and the Backstage container loads them from
+const dynamicPluginsRootVolumeName = "dynamic-plugins-root"
+
+const defaultPluginCacheSize = "2Gi"
+
+type PluginCacheFactory struct{}
+
+func (f PluginCacheFactory) newBackstageObject() RuntimeObject {
+	return &PluginCache{}
+}
+
+// PluginCache is the PersistentVolumeClaim used as the dynamic plugins root volume
+// if spec.application.dynamicPlugins.cache is specified.
+// It can be customized with the dynamic-plugins-cache.yaml default or raw config,
+// the size, storageClass and accessModes from the spec take precedence.
+type PluginCache struct {
+	pvc   *corev1.PersistentVolumeClaim
+	model *BackstageModel
+}
+
+func init() {
+	registerConfig(DynamicPluginsCacheKey, PluginCacheFactory{}, false, nil)
+}
+
+func PluginCacheDefaultName(backstageName string) string {
+	return utils.GenerateRuntimeObjectName(backstageName, "backstage-dynamic-plugins-cache")
+}
+
+func (c *PluginCache) Object() runtime.Object {
+	if c.pvc == nil {
+		return nil
+	}
+	return c.pvc
+}
+
+// implementation of RuntimeObject interface
+func (c *PluginCache) GetKey() string {
+	return DynamicPluginsCacheKey
+}
+
+func (c *PluginCache) addToModel(model *BackstageModel, backstage api.Backstage, config runtime.Object, scheme *runtime.Scheme) error {
+	c.model = model
+
+	// Always add wrapper to model (unconditional)
+	model.setRuntimeObject(c)
+
+	spec := pluginCacheSpec(backstage)
+	if spec == nil {
+		return nil
+	}
+
+	if config != nil {
+		c.pvc = config.(*corev1.PersistentVolumeClaim)
+	} else {
+		c.pvc = &corev1.PersistentVolumeClaim{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "PersistentVolumeClaim",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			},
+		}
+	}
+
+	if spec.Size != nil {
+		setPvcStorageRequest(c.pvc, *spec.Size)
+	} else if _, ok := c.pvc.Spec.Resources.Requests[corev1.ResourceStorage]; !ok {
+		setPvcStorageRequest(c.pvc, resource.MustParse(defaultPluginCacheSize))
+	}
+	if spec.StorageClass != nil {
+		c.pvc.Spec.StorageClassName = spec.StorageClass
+	}
+	if len(spec.AccessModes) > 0 {
+		c.pvc.Spec.AccessModes = spec.AccessModes
+	}
+
+	c.setMetaInfo(backstage, scheme)
+	return nil
+}
+
+// updateAndValidate replaces the source of the dynamic plugins root volume with the cache PersistentVolumeClaim,
+// so the plugin installer and the Backstage container share the persistent plugins directory
+func (c *PluginCache) updateAndValidate(_ api.Backstage, _ *runtime.Scheme) error {
+	if c.pvc == nil {
+		return nil
+	}
+
+	deployment := c.model.getDeployment()
+	if deployment == nil {
+		return fmt.Errorf("backstage deployment not found in model")
+	}
+
+	for i, v := range deployment.podSpec().Volumes {
+		if v.Name == dynamicPluginsRootVolumeName {
+			deployment.podSpec().Volumes[i].VolumeSource = corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: c.pvc.Name},
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("dynamic plugins cache requires '%s' volume in the deployment", dynamicPluginsRootVolumeName)
+}
+
+func (c *PluginCache) setMetaInfo(backstage api.Backstage, scheme *runtime.Scheme) {
+	c.pvc.SetName(PluginCacheDefaultName(backstage.Name))
+	setMetaInfo(c.pvc, backstage, scheme)
+}
+
+func pluginCacheSpec(backstage api.Backstage) *api.PluginCache {
+	if backstage.Spec.Application == nil || backstage.Spec.Application.DynamicPlugins == nil {
+		return nil
+	}
+	return backstage.Spec.Application.DynamicPlugins.Cache
+}
+
+func setPvcStorageRequest(pvc *corev1.PersistentVolumeClaim, size resource.Quantity) {
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+}
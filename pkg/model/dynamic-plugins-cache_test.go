@@ -0,0 +1,114 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+)
+
+func findVolume(volumes []corev1.Volume, name string) *corev1.Volume {
+	for i := range volumes {
+		if volumes[i].Name == name {
+			return &volumes[i]
+		}
+	}
+	return nil
+}
+
+func TestPluginCacheDisabled(t *testing.T) {
+	bs := testDynamicPluginsBackstage.DeepCopy()
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	assert.Nil(t, model.GetRuntimeObject(DynamicPluginsCacheKey))
+	vol := findVolume(model.getDeployment().podSpec().Volumes, dynamicPluginsRootVolumeName)
+	assert.NotNil(t, vol)
+	assert.NotNil(t, vol.Ephemeral)
+}
+
+func TestPluginCachePvc(t *testing.T) {
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{
+		Cache: &api.PluginCache{
+			Size:         ptr.To(resource.MustParse("5Gi")),
+			StorageClass: ptr.To("fast"),
+			AccessModes:  []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+		},
+	}
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	obj := model.GetRuntimeObject(DynamicPluginsCacheKey)
+	assert.NotNil(t, obj)
+	pvc := obj.Object().(*corev1.PersistentVolumeClaim)
+	assert.Equal(t, PluginCacheDefaultName(bs.Name), pvc.Name)
+	assert.Equal(t, bs.Namespace, pvc.Namespace)
+	assert.Equal(t, "5Gi", ptr.To(pvc.Spec.Resources.Requests[corev1.ResourceStorage]).String())
+	assert.Equal(t, "fast", *pvc.Spec.StorageClassName)
+	assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}, pvc.Spec.AccessModes)
+
+	// the dynamic plugins root volume shared by the installer and backstage container uses the PVC
+	vol := findVolume(model.getDeployment().podSpec().Volumes, dynamicPluginsRootVolumeName)
+	assert.NotNil(t, vol)
+	assert.Nil(t, vol.Ephemeral)
+	assert.Equal(t, pvc.Name, vol.PersistentVolumeClaim.ClaimName)
+
+	ic := initContainer(model)
+	assert.NotNil(t, ic)
+	assert.True(t, hasVolumeMount(ic.VolumeMounts, dynamicPluginsRootVolumeName))
+	assert.True(t, hasVolumeMount(model.getDeployment().container().VolumeMounts, dynamicPluginsRootVolumeName))
+}
+
+func TestPluginCacheDefaultSize(t *testing.T) {
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{Cache: &api.PluginCache{}}
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	pvc := model.GetRuntimeObject(DynamicPluginsCacheKey).Object().(*corev1.PersistentVolumeClaim)
+	assert.Equal(t, defaultPluginCacheSize, ptr.To(pvc.Spec.Resources.Requests[corev1.ResourceStorage]).String())
+	assert.Nil(t, pvc.Spec.StorageClassName)
+	assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}, pvc.Spec.AccessModes)
+}
+
+func TestPluginCacheNoRootVolume(t *testing.T) {
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{Cache: &api.PluginCache{}}
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "multicontainer-deployment.yaml")
+
+	_, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.ErrorContains(t, err, "dynamic plugins cache requires 'dynamic-plugins-root' volume")
+}
+
+func hasVolumeMount(mounts []corev1.VolumeMount, volumeName string) bool {
+	for _, m := range mounts {
+		if m.Name == volumeName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+)
+
+const (
+	CatalogIndexImageEnvVar       = "CATALOG_INDEX_IMAGE"
+	ExtraCatalogIndexImagesEnvVar = "EXTRA_CATALOG_INDEX_IMAGES"
+	// CatalogIndexRelatedImageEnvVar overrides the catalog index image of the default configuration Operator-wide
+	CatalogIndexRelatedImageEnvVar = "RELATED_IMAGE_catalog_index"
+
+	// CatalogIndexDefaultConfigFile is the dynamic plugins configuration shipped in the catalog index image,
+	// included by the default dynamic plugins configuration
+	CatalogIndexDefaultConfigFile = "dynamic-plugins.default.yaml"
+)
+
+// ResolvedCatalogIndex is a catalog index image of spec.application.dynamicPlugins.catalogIndex
+// as resolved by the controller
+type ResolvedCatalogIndex struct {
+	Status api.CatalogIndexStatus
+	// Image passed to the plugin installer, pinned by digest if resolved
+	Image string
+	// Content of CatalogIndexDefaultConfigFile, fetched only if the Operator processes dynamic plugins
+	DefaultConfig string
+}
+
+// specCatalogIndex returns spec.application.dynamicPlugins.catalogIndex, if any
+func specCatalogIndex(backstage api.Backstage) *api.CatalogIndex {
+	if backstage.Spec.Application == nil || backstage.Spec.Application.DynamicPlugins == nil {
+		return nil
+	}
+	return backstage.Spec.Application.DynamicPlugins.CatalogIndex
+}
+
+// CatalogIndexStatus returns the catalog index images configured in the spec and their resolved digests
+func (p *DynamicPlugins) CatalogIndexStatus() []api.CatalogIndexStatus {
+	if p.model == nil {
+		return nil
+	}
+	var statuses []api.CatalogIndexStatus
+	for _, ci := range p.model.ExternalConfig.CatalogIndex {
+		statuses = append(statuses, ci.Status)
+	}
+	return statuses
+}
+
+// setCatalogIndexEnv sets the catalog index images of the plugin installer.
+// The images from the spec take precedence over RELATED_IMAGE_catalog_index,
+// which replaces the CATALOG_INDEX_IMAGE of the default configuration.
+func (p *DynamicPlugins) setCatalogIndexEnv(backstage api.Backstage, deployment *BackstageDeployment, initContainer *corev1.Container) {
+	if specCatalogIndex(backstage) == nil || len(p.model.ExternalConfig.CatalogIndex) == 0 {
+		if image := os.Getenv(CatalogIndexRelatedImageEnvVar); image != "" {
+			for i := range initContainer.Env {
+				if initContainer.Env[i].Name == CatalogIndexImageEnvVar {
+					initContainer.Env[i] = corev1.EnvVar{Name: CatalogIndexImageEnvVar, Value: image}
+				}
+			}
+		}
+		return
+	}
+
+	var extra []string
+	for _, ci := range p.model.ExternalConfig.CatalogIndex {
+		if ci.Status.Name == "" {
+			deployment.setOrAppendEnvVar(initContainer, CatalogIndexImageEnvVar, ci.Image)
+		} else {
+			extra = append(extra, ci.Status.Name+"="+ci.Image)
+		}
+	}
+	if len(extra) > 0 {
+		deployment.setOrAppendEnvVar(initContainer, ExtraCatalogIndexImagesEnvVar, strings.Join(extra, ","))
+	} else {
+		initContainer.Env = slices.DeleteFunc(initContainer.Env, func(e corev1.EnvVar) bool {
+			return e.Name == ExtraCatalogIndexImagesEnvVar
+		})
+	}
+}
+
+// catalogIndexDefaults returns the merged default configurations of the resolved catalog index images,
+// the primary image first. Returns false if the content of any of them is not available.
+func catalogIndexDefaults(catalogIndex []ResolvedCatalogIndex) (string, bool, error) {
+	if len(catalogIndex) == 0 {
+		return "", false, nil
+	}
+	merged := ""
+	for _, ci := range catalogIndex {
+		if ci.DefaultConfig == "" {
+			return "", false, nil
+		}
+		var err error
+		if merged, err = MergePluginsData(merged, ci.DefaultConfig); err != nil {
+			return "", false, fmt.Errorf("failed to merge %s of catalog index %s: %w", CatalogIndexDefaultConfigFile, ci.Status.Image, err)
+		}
+	}
+	return merged, true, nil
+}
+
+// includesCatalogIndex returns whether the dynamic plugins configuration includes CatalogIndexDefaultConfigFile,
+// and whether it clears the includes explicitly with an empty list
+func includesCatalogIndex(data string) (included bool, cleared bool, err error) {
+	var config DynaPluginsConfig
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		return false, false, fmt.Errorf("failed to unmarshal dynamic plugins data: %w", err)
+	}
+	return slices.Contains(config.Includes, CatalogIndexDefaultConfigFile), config.Includes != nil && len(config.Includes) == 0, nil
+}
+
+// catalogIndexToExpand returns the merged catalog index defaults if they are available and
+// the default or the user's dynamic plugins configuration includes them (and the user's one does not clear the includes)
+func (p *DynamicPlugins) catalogIndexToExpand(backstage api.Backstage) (string, error) {
+	defaults, ok, err := catalogIndexDefaults(p.model.ExternalConfig.CatalogIndex)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	included := false
+	if p.ConfigMap != nil {
+		if included, _, err = includesCatalogIndex(p.ConfigMap.Data[DynamicPluginsFile]); err != nil {
+			return "", err
+		}
+	}
+	if backstage.Spec.Application != nil && backstage.Spec.Application.DynamicPluginsConfigMapName != "" {
+		specIncluded, cleared, err := includesCatalogIndex(p.model.ExternalConfig.DynamicPlugins.Data[DynamicPluginsFile])
+		if err != nil {
+			return "", err
+		}
+		if cleared {
+			return "", nil
+		}
+		included = included || specIncluded
+	}
+	if !included {
+		return "", nil
+	}
+	return defaults, nil
+}
+
+// expandCatalogIndex merges the dynamic plugins configuration over the catalog index defaults,
+// so the references (ref://, {{inherit}}) to the catalog index plugins are resolved by the Operator,
+// and removes the include of CatalogIndexDefaultConfigFile as it is processed already
+func expandCatalogIndex(defaults, data string) (string, error) {
+	merged, err := MergePluginsData(defaults, data)
+	if err != nil {
+		return "", err
+	}
+	var config DynaPluginsConfig
+	if err := yaml.Unmarshal([]byte(merged), &config); err != nil {
+		return "", fmt.Errorf("failed to unmarshal dynamic plugins data: %w", err)
+	}
+	config.Includes = slices.DeleteFunc(config.Includes, func(include string) bool {
+		return include == CatalogIndexDefaultConfigFile
+	})
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal dynamic plugins config: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+)
+
+var testCatalogDigest = "sha256:" + strings.Repeat("a", 64)
+
+func envValue(c *corev1.Container, name string) (string, bool) {
+	for _, e := range c.Env {
+		if e.Name == name {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+func TestCatalogIndexEnv(t *testing.T) {
+	bs := testDynamicPluginsBackstage.DeepCopy()
+
+	newTestObj := func() *testBackstageObject {
+		return createBackstageTest(*bs).withDefaultConfig(true).
+			addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+			addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+	}
+
+	// the Operator-wide default replaces the default configuration
+	t.Setenv(CatalogIndexRelatedImageEnvVar, "registry.local/rhdh/plugin-catalog-index:1.9")
+	testObj := newTestObj()
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+	image, _ := envValue(initContainer(model), CatalogIndexImageEnvVar)
+	assert.Equal(t, "registry.local/rhdh/plugin-catalog-index:1.9", image)
+
+	// the spec takes precedence
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{CatalogIndex: &api.CatalogIndex{
+		Image: "quay.io/rhdh/plugin-catalog-index:1.10",
+		Extra: []api.NamedCatalogIndex{
+			{Name: "community", Image: "quay.io/rhdh-community/plugin-catalog-index:1.10"},
+			{Name: "internal", Image: "registry.example.com/catalog:latest"},
+		},
+	}}
+	testObj = newTestObj()
+	testObj.externalConfig.CatalogIndex = []ResolvedCatalogIndex{
+		{Status: api.CatalogIndexStatus{Image: "quay.io/rhdh/plugin-catalog-index:1.10", Digest: testCatalogDigest},
+			Image: "quay.io/rhdh/plugin-catalog-index@" + testCatalogDigest},
+		{Status: api.CatalogIndexStatus{Name: "community", Image: "quay.io/rhdh-community/plugin-catalog-index:1.10", Digest: testCatalogDigest},
+			Image: "quay.io/rhdh-community/plugin-catalog-index@" + testCatalogDigest},
+		{Status: api.CatalogIndexStatus{Name: "internal", Image: "registry.example.com/catalog:latest", Message: "unauthorized"},
+			Image: "registry.example.com/catalog:latest"},
+	}
+	model, err = InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	ic := initContainer(model)
+	image, _ = envValue(ic, CatalogIndexImageEnvVar)
+	assert.Equal(t, "quay.io/rhdh/plugin-catalog-index@"+testCatalogDigest, image)
+	extra, _ := envValue(ic, ExtraCatalogIndexImagesEnvVar)
+	assert.Equal(t, "community=quay.io/rhdh-community/plugin-catalog-index@"+testCatalogDigest+",internal=registry.example.com/catalog:latest", extra)
+
+	statuses := model.GetRuntimeObject(DynamicPluginsKey).(*DynamicPlugins).CatalogIndexStatus()
+	assert.Len(t, statuses, 3)
+	assert.Equal(t, "unauthorized", statuses[2].Message)
+}
+
+func TestCatalogIndexExpansion(t *testing.T) {
+	t.Setenv(OperatorDPProcessingEnvVar, "true")
+
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPluginsConfigMapName = "dplugin"
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{CatalogIndex: &api.CatalogIndex{
+		Image: "quay.io/rhdh/plugin-catalog-index:1.10",
+		Extra: []api.NamedCatalogIndex{{Name: "community", Image: "quay.io/rhdh-community/plugin-catalog-index:1.10"}},
+	}}
+
+	newTestObj := func(userData string) *testBackstageObject {
+		testObj := createBackstageTest(*bs).withDefaultConfig(true).
+			addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+			addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+		testObj.externalConfig.DynamicPlugins = corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "dplugin"},
+			Data:       map[string]string{DynamicPluginsFile: userData},
+		}
+		testObj.externalConfig.CatalogIndex = []ResolvedCatalogIndex{
+			{Image: "quay.io/rhdh/plugin-catalog-index@" + testCatalogDigest, DefaultConfig: `
+plugins:
+  - package: oci://quay.io/rhdh/backstage-plugin-foo@sha256:abc123!backstage-plugin-foo
+    disabled: true
+  - package: oci://quay.io/rhdh/backstage-plugin-bar@sha256:def456!backstage-plugin-bar
+    disabled: true
+`},
+			{Status: api.CatalogIndexStatus{Name: "community"}, Image: "quay.io/rhdh-community/plugin-catalog-index@" + testCatalogDigest, DefaultConfig: `
+plugins:
+  - package: oci://quay.io/rhdh-community/backstage-plugin-baz@sha256:789abc!backstage-plugin-baz
+    disabled: true
+`},
+		}
+		return testObj
+	}
+
+	testObj := newTestObj(`
+plugins:
+  - package: ref://backstage-plugin-foo
+    enabled: true
+  - package: oci://quay.io/rhdh-community/backstage-plugin-baz:{{inherit}}
+    enabled: true
+`)
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	dp := model.GetRuntimeObject(DynamicPluginsKey).(*DynamicPlugins)
+	assert.Equal(t, "oci://quay.io/rhdh/backstage-plugin-foo@sha256:abc123!backstage-plugin-foo\n"+
+		"oci://quay.io/rhdh-community/backstage-plugin-baz@sha256:789abc!backstage-plugin-baz",
+		dp.enabledPluginsCM.Data["packages.txt"])
+	assert.NotContains(t, dp.ConfigMap.Data[DynamicPluginsFile], CatalogIndexDefaultConfigFile)
+
+	// the user's configuration clears the includes
+	testObj = newTestObj(`
+includes: []
+plugins:
+  - package: ref://backstage-plugin-foo
+`)
+	_, err = InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.ErrorContains(t, err, "backstage-plugin-foo")
+
+	// the catalog index content is not available
+	testObj = newTestObj(`
+plugins:
+  - package: oci://quay.io/rhdh/backstage-plugin-bar:1.0
+`)
+	testObj.externalConfig.CatalogIndex[1].DefaultConfig = ""
+	model, err = InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+	dp = model.GetRuntimeObject(DynamicPluginsKey).(*DynamicPlugins)
+	assert.Equal(t, "oci://quay.io/rhdh/backstage-plugin-bar:1.0", dp.enabledPluginsCM.Data["packages.txt"])
+	assert.Contains(t, dp.ConfigMap.Data[DynamicPluginsFile], CatalogIndexDefaultConfigFile)
+}
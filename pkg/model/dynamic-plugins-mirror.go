@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+// PluginMirrorsEnvVar defines Operator-wide registry mirrors for dynamic plugin packages
+// as a comma-separated list of prefix=replacement pairs, for example:
+// PLUGIN_MIRRORS_backstage="quay.io/rhdh=registry.localhost:5000/rhdh,https://registry.npmjs.org=https://npm.local"
+const PluginMirrorsEnvVar = "PLUGIN_MIRRORS_backstage"
+
+// NpmConfigRegistryEnvVar points the plugin installer to the mirror of the npm registry,
+// which the npm package specifiers (e.g. @scope/package@1.2.3) are downloaded from
+const NpmConfigRegistryEnvVar = "NPM_CONFIG_REGISTRY"
+
+const npmRegistry = "https://registry.npmjs.org"
+
+// defaultPluginMirrors returns the Operator-wide registry mirrors defined by PluginMirrorsEnvVar
+func defaultPluginMirrors() ([]api.PluginMirror, error) {
+	mirrors := []api.PluginMirror{}
+	for _, entry := range utils.ParseCommaSeparated(os.Getenv(PluginMirrorsEnvVar)) {
+		prefix, replacement, found := strings.Cut(entry, "=")
+		prefix = strings.TrimSpace(prefix)
+		replacement = strings.TrimSpace(replacement)
+		if !found || prefix == "" || replacement == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected prefix=replacement", PluginMirrorsEnvVar, entry)
+		}
+		mirrors = append(mirrors, api.PluginMirror{Prefix: prefix, Replacement: replacement})
+	}
+	return mirrors, nil
+}
+
+// specPluginMirrors returns the registry mirrors defined in Backstage spec, if any
+func specPluginMirrors(backstage api.Backstage) []api.PluginMirror {
+	if backstage.Spec.Application == nil || backstage.Spec.Application.DynamicPlugins == nil {
+		return nil
+	}
+	return backstage.Spec.Application.DynamicPlugins.Mirrors
+}
+
+// applyMirrors rewrites the package URL using the first set of mirrors containing a matching prefix.
+// Sets are evaluated in order (e.g. spec mirrors first, then Operator-wide defaults),
+// inside a set the longest matching prefix wins.
+// Returns the package URL unchanged if no mirror matches.
+func applyMirrors(packageURL string, mirrorSets ...[]api.PluginMirror) string {
+	for _, mirrors := range mirrorSets {
+		if mirrored, ok := mirrorPackage(packageURL, mirrors); ok {
+			return mirrored
+		}
+	}
+	return packageURL
+}
+
+// npmRegistryMirror returns the mirror of the npm registry, empty if the npm registry is not mirrored.
+// The npm package specifiers carry no registry URL, so they are mirrored by pointing the plugin installer to the mirror.
+func npmRegistryMirror(mirrorSets ...[]api.PluginMirror) string {
+	if mirrored := applyMirrors(npmRegistry, mirrorSets...); mirrored != npmRegistry {
+		return strings.TrimSuffix(mirrored, "/")
+	}
+	return ""
+}
+
+// mirrorPackage replaces the longest mirror prefix matching the package URL.
+// A prefix without scheme (e.g. quay.io/rhdh) is matched against the package URL with its scheme stripped,
+// so it applies to oci://quay.io/rhdh/... as well as to https://quay.io/rhdh/... packages.
+// The prefix has to match a whole path component: quay.io/rhdh does not match quay.io/rhdh-community.
+func mirrorPackage(packageURL string, mirrors []api.PluginMirror) (string, bool) {
+	scheme, rest := "", packageURL
+	if idx := strings.Index(packageURL, "://"); idx != -1 {
+		scheme, rest = packageURL[:idx+3], packageURL[idx+3:]
+	}
+
+	result, matchLen := "", 0
+	for _, m := range mirrors {
+		if m.Prefix == "" || len(m.Prefix) <= matchLen {
+			continue
+		}
+		switch {
+		case strings.Contains(m.Prefix, "://") && hasPathPrefix(packageURL, m.Prefix):
+			result = m.Replacement + strings.TrimPrefix(packageURL, m.Prefix)
+		case !strings.Contains(m.Prefix, "://") && hasPathPrefix(rest, m.Prefix):
+			result = scheme + m.Replacement + strings.TrimPrefix(rest, m.Prefix)
+		default:
+			continue
+		}
+		matchLen = len(m.Prefix)
+	}
+	return result, matchLen > 0
+}
+
+// hasPathPrefix checks if s starts with prefix ending at a path component boundary
+func hasPathPrefix(s, prefix string) bool {
+	if !strings.HasPrefix(s, prefix) {
+		return false
+	}
+	if len(s) == len(prefix) || strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	return strings.ContainsRune("/:@!", rune(s[len(prefix)]))
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyMirrors(t *testing.T) {
+	specMirrors := []api.PluginMirror{
+		{Prefix: "quay.io/rhdh", Replacement: "registry.local:5000/rhdh"},
+		{Prefix: "quay.io/rhdh/special", Replacement: "registry.local:5000/special"},
+		{Prefix: "https://registry.npmjs.org", Replacement: "https://npm.local"},
+	}
+	defaultMirrors := []api.PluginMirror{
+		{Prefix: "quay.io", Replacement: "default.local"},
+		{Prefix: "ghcr.io/org", Replacement: "default.local/org"},
+	}
+
+	tests := []struct {
+		name     string
+		pkg      string
+		expected string
+	}{
+		{
+			name:     "oci package matched without scheme",
+			pkg:      "oci://quay.io/rhdh/plugin-a@sha256:abc!plugin-a",
+			expected: "oci://registry.local:5000/rhdh/plugin-a@sha256:abc!plugin-a",
+		},
+		{
+			name:     "longest prefix wins",
+			pkg:      "oci://quay.io/rhdh/special/plugin-b:1.0",
+			expected: "oci://registry.local:5000/special/plugin-b:1.0",
+		},
+		{
+			name:     "prefix with scheme",
+			pkg:      "https://registry.npmjs.org/plugin-c/-/plugin-c-1.0.0.tgz",
+			expected: "https://npm.local/plugin-c/-/plugin-c-1.0.0.tgz",
+		},
+		{
+			name:     "prefix matches whole path component only",
+			pkg:      "oci://quay.io/rhdh-community/plugin-d:1.0",
+			expected: "oci://default.local/rhdh-community/plugin-d:1.0",
+		},
+		{
+			name:     "spec mirrors take precedence over defaults",
+			pkg:      "oci://quay.io/rhdh/plugin-e:1.0",
+			expected: "oci://registry.local:5000/rhdh/plugin-e:1.0",
+		},
+		{
+			name:     "default mirror used if no spec mirror matches",
+			pkg:      "oci://ghcr.io/org/plugin-f:1.0",
+			expected: "oci://default.local/org/plugin-f:1.0",
+		},
+		{
+			name:     "no match",
+			pkg:      "./dynamic-plugins/dist/plugin-g",
+			expected: "./dynamic-plugins/dist/plugin-g",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, applyMirrors(tt.pkg, specMirrors, defaultMirrors))
+		})
+	}
+}
+
+func TestDefaultPluginMirrors(t *testing.T) {
+	t.Setenv(PluginMirrorsEnvVar, "quay.io/rhdh=registry.local/rhdh, https://registry.npmjs.org=https://npm.local")
+	mirrors, err := defaultPluginMirrors()
+	assert.NoError(t, err)
+	assert.Equal(t, []api.PluginMirror{
+		{Prefix: "quay.io/rhdh", Replacement: "registry.local/rhdh"},
+		{Prefix: "https://registry.npmjs.org", Replacement: "https://npm.local"},
+	}, mirrors)
+
+	t.Setenv(PluginMirrorsEnvVar, "quay.io/rhdh")
+	_, err = defaultPluginMirrors()
+	assert.ErrorContains(t, err, "expected prefix=replacement")
+}
+
+func TestNpmRegistryMirror(t *testing.T) {
+	assert.Empty(t, npmRegistryMirror([]api.PluginMirror{{Prefix: "quay.io", Replacement: "mirror.local"}}))
+	assert.Equal(t, "https://npm.local", npmRegistryMirror(nil,
+		[]api.PluginMirror{{Prefix: "https://registry.npmjs.org", Replacement: "https://npm.local"}}))
+}
+
+func TestMirroredPackagesTxt(t *testing.T) {
+	t.Setenv(OperatorDPProcessingEnvVar, "true")
+	t.Setenv(PluginMirrorsEnvVar, "ghcr.io=mirror.local/ghcr")
+
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPluginsConfigMapName = "dplugin"
+	bs.Spec.Application.DynamicPlugins = &api.DynamicPlugins{
+		Mirrors: []api.PluginMirror{
+			{Prefix: "quay.io/rhdh", Replacement: "registry.local/rhdh"},
+			{Prefix: "registry.npmjs.org", Replacement: "npm.local/npm/"},
+		},
+	}
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "raw-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+
+	testObj.externalConfig.DynamicPlugins = corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "dplugin"},
+		Data: map[string]string{DynamicPluginsFile: `
+plugins:
+  - package: oci://quay.io/rhdh/plugin-a@sha256:abc
+  - package: oci://ghcr.io/org/plugin-b:1.0
+  - package: ./dynamic-plugins/dist/plugin-c
+  - package: "@scope/plugin-e@1.2.3"
+  - package: oci://quay.io/rhdh/plugin-d:1.0
+    disabled: true
+`},
+	}
+
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	dp := model.GetRuntimeObject(DynamicPluginsKey).(*DynamicPlugins)
+	assert.Equal(t, "oci://registry.local/rhdh/plugin-a@sha256:abc\noci://mirror.local/ghcr/org/plugin-b:1.0\n./dynamic-plugins/dist/plugin-c\n@scope/plugin-e@1.2.3",
+		dp.enabledPluginsCM.Data["packages.txt"])
+	assert.Equal(t, []api.PluginPackageStatus{
+		{Package: "oci://registry.local/rhdh/plugin-a@sha256:abc", Original: "oci://quay.io/rhdh/plugin-a@sha256:abc"},
+		{Package: "oci://mirror.local/ghcr/org/plugin-b:1.0", Original: "oci://ghcr.io/org/plugin-b:1.0"},
+		{Package: "./dynamic-plugins/dist/plugin-c"},
+		{Package: "@scope/plugin-e@1.2.3"},
+	}, dp.Packages())
+
+	// the npm package specifiers are downloaded from the mirror of the npm registry
+	registry := findEnvVar(initContainer(model).Env, NpmConfigRegistryEnvVar)
+	assert.NotNil(t, registry)
+	assert.Equal(t, "https://npm.local/npm", registry.Value)
+}
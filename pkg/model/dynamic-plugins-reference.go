@@ -2,16 +2,24 @@ package model
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
 const inheritSuffix = ":{{inherit}}"
+const npmInheritSuffix = "@{{inherit}}"
 const refPrefix = "ref://"
 const ociPrefix = "oci://"
 const httpsPrefix = "https://"
 const httpPrefix = "http://"
 const localPrefix = "./"
 
+// npm package name, optionally scoped: [@scope/]name
+var npmNameRegexp = regexp.MustCompile(`^(@[a-z0-9][a-z0-9._~-]*/)?[a-z0-9][a-z0-9._~-]*$`)
+
+// npm version, dist-tag or whitespace-free version range, e.g. 1.2.3, ^1.2.0, >=1.0.0<2.0.0, 1.x||2.x, latest
+var npmVersionRegexp = regexp.MustCompile(`^[0-9A-Za-z.^~<>=*|+-]+$`)
+
 // resolveReferences resolves all reference types in plugin package URLs.
 //
 // Supported package URL formats:
@@ -29,8 +37,11 @@ const localPrefix = "./"
 //   - https://...: HTTPS URL to plugin archive
 //   - http://...: HTTP URL to plugin archive
 //   - ./path: Local filesystem path
+//   - [@scope/]name[@version]: npm package specifier, the version may be a range or a dist-tag.
+//     Example: @backstage/plugin-catalog@^1.2.0
+//     Its {{inherit}} form looks the package up by name: @backstage/plugin-catalog@{{inherit}}
 //
-// Any other prefix returns an error.
+// Any other format returns an error.
 func resolveReferences(plugins []DynaPlugin, basePlugins []DynaPlugin) ([]DynaPlugin, error) {
 	resolved := make([]DynaPlugin, len(plugins))
 	copy(resolved, plugins)
@@ -47,14 +58,17 @@ func resolveReferences(plugins []DynaPlugin, basePlugins []DynaPlugin) ([]DynaPl
 		case strings.HasPrefix(plugin.Package, refPrefix):
 			// Catalog search by name
 			resolved[i].Package, err = resolveRefReference(plugin.Package, basePlugins)
-		case strings.Contains(plugin.Package, inheritSuffix):
+		case strings.Contains(plugin.Package, inheritSuffix) || strings.HasSuffix(plugin.Package, npmInheritSuffix):
 			// Catalog search by name, inherit version/digest
 			resolved[i].Package, err = resolveInheritReference(plugin.Package, basePlugins)
 		case plugin.IsDirectLink():
 			// Direct link - no resolution needed
 			continue
+		case plugin.IsNpmPackage():
+			// npm package specifier - resolved by the plugin installer
+			continue
 		default:
-			return nil, fmt.Errorf("unsupported package URL format %q: must start with oci://, https://, http://, ./ or use ref:// for catalog lookup", plugin.Package)
+			return nil, fmt.Errorf("unsupported package URL format %q: must start with oci://, https://, http://, ./, be an npm package specifier or use ref:// for catalog lookup", plugin.Package)
 		}
 
 		if err != nil {
@@ -73,12 +87,37 @@ func (p *DynaPlugin) IsDirectLink() bool {
 		strings.HasPrefix(p.Package, localPrefix)
 }
 
+// IsNpmPackage returns true if the package is an npm package specifier: [@scope/]name[@version].
+func (p *DynaPlugin) IsNpmPackage() bool {
+	_, _, ok := parseNpmPackage(p.Package)
+	return ok
+}
+
+// parseNpmPackage splits the npm package specifier into the package name and the version (range or dist-tag),
+// the version is empty if not specified
+func parseNpmPackage(spec string) (name string, version string, ok bool) {
+	name = spec
+	// the leading @ belongs to the scope
+	if idx := strings.LastIndex(spec, "@"); idx > 0 {
+		name, version = spec[:idx], spec[idx+1:]
+		if !npmVersionRegexp.MatchString(version) {
+			return "", "", false
+		}
+	}
+	if !npmNameRegexp.MatchString(name) {
+		return "", "", false
+	}
+	return name, version, true
+}
+
 // resolveInheritReference resolves a single {{inherit}} reference by looking up plugin by name.
 // The registry and path in the user's URL are ignored - only the plugin name (last path component) matters.
+// npm packages are looked up by the package name.
 //
 // Examples:
 //   - oci://any-registry/path/plugin-foo:{{inherit}} matches base plugin oci://quay.io/rhdh/plugin-foo@sha256:abc
 //   - oci://x/plugin-foo:{{inherit}}!custom-path uses base's version but user's plugin-path
+//   - @scope/plugin-foo@{{inherit}} matches base plugin @scope/plugin-foo@1.2.3
 func resolveInheritReference(packageURL string, basePlugins []DynaPlugin) (string, error) {
 	// Parse package to extract !plugin-path suffix if present
 	var pluginPath string
@@ -87,8 +126,11 @@ func resolveInheritReference(packageURL string, basePlugins []DynaPlugin) (strin
 		packageURL = packageURL[:idx]
 	}
 
-	// Extract plugin name from the package URL (strip :{{inherit}} first)
+	// Extract plugin name from the package URL (strip :{{inherit}} or npm's @{{inherit}} first)
 	tempPackage := strings.Replace(packageURL, inheritSuffix, "", 1)
+	if strings.HasSuffix(tempPackage, npmInheritSuffix) {
+		tempPackage = strings.TrimSuffix(tempPackage, npmInheritSuffix)
+	}
 	tempPlugin := DynaPlugin{Package: tempPackage}
 	pluginName := tempPlugin.Name()
 
@@ -152,6 +194,7 @@ func resolveRefReference(packageURL string, basePlugins []DynaPlugin) (string, e
 //   - oci://quay.io/rhdh/backstage-plugin-techdocs:1.0.0 -> backstage-plugin-techdocs
 //   - https://example.com/path/backstage-plugin-foo-1.0.0.tgz -> backstage-plugin-foo
 //   - ./dynamic-plugins/dist/backstage-plugin-techdocs -> backstage-plugin-techdocs
+//   - @backstage/plugin-catalog@^1.2.0 -> @backstage/plugin-catalog
 func (p *DynaPlugin) Name() string {
 	packageURL := p.Package
 
@@ -224,6 +267,11 @@ func (p *DynaPlugin) Name() string {
 		return packageURL
 	}
 
+	// Handle npm package specifiers ([@scope/]name[@version])
+	if name, _, ok := parseNpmPackage(packageURL); ok {
+		return name
+	}
+
 	// Unknown protocol - return empty string
 	return ""
 }
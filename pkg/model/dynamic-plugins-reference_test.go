@@ -71,6 +71,7 @@ func TestResolveReferences(t *testing.T) {
 		{Package: "oci://quay.io/rhdh/plugin-a@sha256:abc123!plugin-a"},
 		{Package: "oci://quay.io/rhdh/plugin-b@sha256:def456"},
 		{Package: "./dynamic-plugins/dist/local-plugin"},
+		{Package: "@backstage/plugin-npm@1.2.3", Integrity: "sha512-abc"},
 	}
 
 	tests := []struct {
@@ -132,9 +133,45 @@ func TestResolveReferences(t *testing.T) {
 			expectError: true,
 		},
 		{
-			name: "npm package not supported - error",
+			name: "npm packages - no resolution",
 			plugins: []DynaPlugin{
 				{Package: "@backstage/plugin-catalog"},
+				{Package: "@backstage/plugin-catalog@^1.2.0"},
+				{Package: "is-odd@3.0.1"},
+				{Package: "backstage-plugin-foo@latest"},
+			},
+			expected: []string{
+				"@backstage/plugin-catalog",
+				"@backstage/plugin-catalog@^1.2.0",
+				"is-odd@3.0.1",
+				"backstage-plugin-foo@latest",
+			},
+		},
+		{
+			name: "resolve npm inherit reference",
+			plugins: []DynaPlugin{
+				{Package: "@backstage/plugin-npm@{{inherit}}"},
+			},
+			expected: []string{"@backstage/plugin-npm@1.2.3"},
+		},
+		{
+			name: "npm inherit reference not found - error",
+			plugins: []DynaPlugin{
+				{Package: "@backstage/plugin-a@{{inherit}}"},
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid npm package - error",
+			plugins: []DynaPlugin{
+				{Package: "@Backstage/Plugin"},
+			},
+			expectError: true,
+		},
+		{
+			name: "npm version range with spaces - error",
+			plugins: []DynaPlugin{
+				{Package: "@backstage/plugin-catalog@>=1.0.0 <2.0.0"},
 			},
 			expectError: true,
 		},
@@ -294,6 +331,26 @@ func TestName(t *testing.T) {
 			package_: "./dynamic-plugins/dist/backstage-plugin-techdocs",
 			expected: "backstage-plugin-techdocs",
 		},
+		{
+			name:     "npm scoped with version range",
+			package_: "@backstage/plugin-catalog@^1.2.0",
+			expected: "@backstage/plugin-catalog",
+		},
+		{
+			name:     "npm scoped without version",
+			package_: "@backstage/plugin-catalog",
+			expected: "@backstage/plugin-catalog",
+		},
+		{
+			name:     "npm unscoped with version",
+			package_: "is-odd@3.0.1",
+			expected: "is-odd",
+		},
+		{
+			name:     "invalid npm package returns empty",
+			package_: "@backstage/plugin@1.0 2.0",
+			expected: "",
+		},
 	}
 
 	for _, tt := range tests {
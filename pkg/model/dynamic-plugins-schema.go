@@ -0,0 +1,200 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+// PluginSchemasEnvVar enables reading the configuration schemas of the plugin packages from their images.
+// It downloads the image layers, so it is disabled by default.
+const PluginSchemasEnvVar = "OPERATOR_DP_PACKAGE_SCHEMAS"
+
+// IsPluginSchemasReading returns true if the Operator processes dynamic plugins and reads the configuration schemas
+// of the plugin packages
+func IsPluginSchemasReading() bool {
+	return IsOperatorDPProcessing() && utils.BoolEnvVar(PluginSchemasEnvVar, false)
+}
+
+// PluginSchemaReader reads the configuration schemas the plugin packages provide
+type PluginSchemaReader interface {
+	// ReadConfigSchema returns the JSON schema of the plugin package, nil if the package provides none
+	ReadConfigSchema(pkg string) (map[string]interface{}, error)
+}
+
+// withPackageSchemas returns the plugins, the ones with no configSchema in their entry completed with the schema
+// their package provides. packages are the (mirrored) packages the plugin installer installs, in the plugins order.
+func withPackageSchemas(plugins []DynaPlugin, packages []api.PluginPackageStatus, reader PluginSchemaReader) ([]DynaPlugin, []string) {
+	if reader == nil {
+		return plugins, nil
+	}
+	var errs []string
+	result := make([]DynaPlugin, len(plugins))
+	for i, plugin := range plugins {
+		result[i] = plugin
+		if len(plugin.ConfigSchema) > 0 {
+			continue
+		}
+		pkg := plugin.Package
+		if i < len(packages) {
+			pkg = packages[i].Package
+		}
+		schema, err := reader.ReadConfigSchema(pkg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to read configSchema of plugin %s: %s", plugin.Package, err))
+			continue
+		}
+		result[i].ConfigSchema = schema
+	}
+	return result, errs
+}
+
+// validatePluginConfigs validates the merged pluginConfig of all enabled plugins
+// against the JSON schemas provided by the plugins (configSchema field of plugin entries or their packages).
+// Like Backstage does with app-config schemas, every plugin schema is applied to the whole merged config,
+// so a schema constrains only the keys it describes.
+func validatePluginConfigs(mergedConfig map[string]interface{}, plugins []DynaPlugin) []string {
+	if len(mergedConfig) == 0 {
+		return nil
+	}
+
+	var data interface{}
+	if err := jsonRoundTrip(mergedConfig, &data); err != nil {
+		return []string{fmt.Sprintf("failed to convert merged pluginConfig: %s", err)}
+	}
+
+	var errs []string
+	for _, plugin := range plugins {
+		if len(plugin.ConfigSchema) == 0 {
+			continue
+		}
+		schema := &spec.Schema{}
+		if err := jsonRoundTrip(plugin.ConfigSchema, schema); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid configSchema of plugin %s: %s", plugin.Package, err))
+			continue
+		}
+		res := validate.NewSchemaValidator(schema, nil, "", strfmt.Default).Validate(data)
+		for _, e := range res.Errors {
+			errs = append(errs, fmt.Sprintf("pluginConfig does not match configSchema of plugin %s: %s", plugin.Package, e))
+		}
+	}
+	return errs
+}
+
+// mergePluginConfigs recursively merges src plugin config into dst. Maps are merged deeply.
+// owners tracks which plugin set every non-map value, setting the same path to a different value
+// by a later plugin overwrites it and is reported as a conflict.
+func mergePluginConfigs(dst, src map[string]interface{}, owners map[string]string, owner, path string) []string {
+	var conflicts []string
+	for _, k := range utils.SortedKeys(src) {
+		srcVal := src[k]
+		keyPath := strings.TrimPrefix(path+"."+k, ".")
+		srcMap := toStringKeyedMap(srcVal)
+		if dstVal, exists := dst[k]; exists {
+			dstMap := toStringKeyedMap(dstVal)
+			if srcMap != nil && dstMap != nil {
+				conflicts = append(conflicts, mergePluginConfigs(dstMap, srcMap, owners, owner, keyPath)...)
+				dst[k] = dstMap
+				continue
+			}
+			if srcMap == nil && dstMap == nil && reflect.DeepEqual(normalizeConfigValue(srcVal), normalizeConfigValue(dstVal)) {
+				continue
+			}
+			conflicts = append(conflicts, fmt.Sprintf("conflicting pluginConfig key '%s' set by plugins %s and %s",
+				keyPath, pathOwner(owners, keyPath), owner))
+			// the values the overwritten map had are not owned anymore
+			for p := range owners {
+				if strings.HasPrefix(p, keyPath+".") {
+					delete(owners, p)
+				}
+			}
+		}
+		// Assign new value, converting maps to string-keyed
+		if srcMap != nil {
+			dst[k] = srcMap
+			setOwner(owners, srcMap, owner, keyPath)
+		} else {
+			dst[k] = srcVal
+			owners[keyPath] = owner
+		}
+	}
+	return conflicts
+}
+
+// setOwner records the owner of every value of a newly added map
+func setOwner(owners map[string]string, m map[string]interface{}, owner, path string) {
+	owners[path] = owner
+	for k, v := range m {
+		if sub := toStringKeyedMap(v); sub != nil {
+			setOwner(owners, sub, owner, path+"."+k)
+		} else {
+			owners[path+"."+k] = owner
+		}
+	}
+}
+
+// pathOwner returns the owner of the path or of its closest parent
+func pathOwner(owners map[string]string, path string) string {
+	for p := path; p != ""; {
+		if o, ok := owners[p]; ok {
+			return o
+		}
+		idx := strings.LastIndex(p, ".")
+		if idx == -1 {
+			break
+		}
+		p = p[:idx]
+	}
+	return "unknown"
+}
+
+// toStringKeyedMap converts map[interface{}]interface{} (from yaml.v2) to map[string]interface{}.
+// Returns the map as-is if already string-keyed, or nil if not a map.
+func toStringKeyedMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{})
+		for key, val := range m {
+			result[fmt.Sprint(key)] = val
+		}
+		return result
+	}
+	return nil
+}
+
+// normalizeConfigValue recursively converts yaml.v2 values to JSON compatible ones
+func normalizeConfigValue(v interface{}) interface{} {
+	if m := toStringKeyedMap(v); m != nil {
+		result := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			result[k] = normalizeConfigValue(val)
+		}
+		return result
+	}
+	if l, ok := v.([]interface{}); ok {
+		result := make([]interface{}, len(l))
+		for i, val := range l {
+			result[i] = normalizeConfigValue(val)
+		}
+		return result
+	}
+	return v
+}
+
+func jsonRoundTrip(in interface{}, out interface{}) error {
+	data, err := json.Marshal(normalizeConfigValue(in))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -31,6 +32,9 @@ const DynamicPluginsFile = "dynamic-plugins.yaml"
 const OperatorDPProcessingEnvVar = "OPERATOR_DP_PROCESSING"
 const InstallDpImageEnvVar = "INSTALL_DP_IMAGE"
 
+// Subresource Integrity of a plugin package, as verified by the plugin installer
+var integrityRegexp = regexp.MustCompile(`^(sha256|sha384|sha512)-[A-Za-z0-9+/]+={0,2}$`)
+
 type DynamicPluginsFactory struct{}
 
 func (f DynamicPluginsFactory) newBackstageObject() RuntimeObject {
@@ -42,6 +46,9 @@ type DynamicPlugins struct {
 	model            *BackstageModel
 	enabledPlugins   []DynaPlugin
 	enabledPluginsCM *corev1.ConfigMap
+	packages         []api.PluginPackageStatus
+	// mirror of the npm registry the plugin installer downloads the npm packages from, if any
+	npmRegistry string
 }
 
 type DynaPluginsConfig struct {
@@ -58,10 +65,14 @@ type DynaPlugin struct {
 	Disabled     bool                   `yaml:"disabled"`
 	PluginConfig map[string]interface{} `yaml:"pluginConfig,omitempty"`
 	Dependencies []PluginDependency     `yaml:"dependencies,omitempty"`
+	// JSON schema of the plugin's configuration, used to validate the merged pluginConfig
+	ConfigSchema map[string]interface{} `yaml:"configSchema,omitempty"`
 }
 
 type PluginDependency struct {
 	Ref string `yaml:"ref"`
+	// Parameters passed to the dependency manifests templates as .Params
+	Parameters map[string]interface{} `yaml:"parameters,omitempty"`
 }
 
 func init() {
@@ -106,6 +117,22 @@ func (p *DynamicPlugins) addToModel(model *BackstageModel, backstage api.Backsta
 		}
 	}
 
+	// the catalog index defaults the configuration is expanded with, if the Operator resolves the references
+	catalogDefaults := ""
+	if IsOperatorDPProcessing() {
+		var err error
+		if catalogDefaults, err = p.catalogIndexToExpand(backstage); err != nil {
+			return err
+		}
+	}
+	if p.ConfigMap != nil && catalogDefaults != "" {
+		expanded, err := expandCatalogIndex(catalogDefaults, p.ConfigMap.Data[DynamicPluginsFile])
+		if err != nil {
+			return fmt.Errorf("failed to merge catalog index dynamic plugins config: %w", err)
+		}
+		p.ConfigMap.Data[DynamicPluginsFile] = expanded
+	}
+
 	if backstage.Spec.Application != nil && backstage.Spec.Application.DynamicPluginsConfigMapName != "" {
 		specPlugins := &p.model.ExternalConfig.DynamicPlugins
 
@@ -118,10 +145,23 @@ func (p *DynamicPlugins) addToModel(model *BackstageModel, backstage api.Backsta
 			// Merge user's config with default config
 			//mergedData, err := p.mergeWith(specPlugins.Data[DynamicPluginsFile])
 			mergedData, err := MergePluginsData(p.ConfigMap.Data[DynamicPluginsFile], specPlugins.Data[DynamicPluginsFile])
+			if err == nil && catalogDefaults != "" {
+				// the catalog index is merged already, drop the include of the user's config
+				mergedData, err = expandCatalogIndex("", mergedData)
+			}
 			if err != nil {
 				return fmt.Errorf("failed to merge dynamic plugins config: %w", err)
 			}
 			p.ConfigMap.Data[DynamicPluginsFile] = mergedData
+		} else if catalogDefaults != "" {
+			expanded, err := expandCatalogIndex(catalogDefaults, specPlugins.Data[DynamicPluginsFile])
+			if err != nil {
+				return fmt.Errorf("failed to merge catalog index dynamic plugins config: %w", err)
+			}
+			p.ConfigMap = &corev1.ConfigMap{
+				Data:       map[string]string{DynamicPluginsFile: expanded},
+				BinaryData: specPlugins.BinaryData,
+			}
 		} else {
 			// No default config - create a fresh ConfigMap copying only Data/BinaryData.
 			// We must NOT reuse the external ConfigMap's ObjectMeta (resourceVersion, uid,
@@ -141,11 +181,27 @@ func (p *DynamicPlugins) addToModel(model *BackstageModel, backstage api.Backsta
 			return err
 		}
 
+		defaultMirrors, err := defaultPluginMirrors()
+		if err != nil {
+			return err
+		}
+		p.npmRegistry = npmRegistryMirror(specPluginMirrors(backstage), defaultMirrors)
+
 		packages := []string{}
+		p.packages = []api.PluginPackageStatus{}
 		for _, plugin := range pluginsData {
 			if !plugin.IsDisabled() {
+				if plugin.Integrity != "" && !integrityRegexp.MatchString(plugin.Integrity) {
+					return fmt.Errorf("invalid integrity %q of plugin %s: expected sha256-, sha384- or sha512- followed by the base64 encoded hash", plugin.Integrity, plugin.Package)
+				}
 				p.enabledPlugins = append(p.enabledPlugins, plugin)
-				packages = append(packages, plugin.Package)
+				pkg := api.PluginPackageStatus{Package: applyMirrors(plugin.Package, specPluginMirrors(backstage), defaultMirrors)}
+				if pkg.Package != plugin.Package {
+					pkg.Original = plugin.Package
+				}
+				p.packages = append(p.packages, pkg)
+				// the plugin installer verifies the optional integrity following the package: "url [integrity]"
+				packages = append(packages, strings.TrimSpace(pkg.Package+" "+plugin.Integrity))
 			}
 		}
 
@@ -190,6 +246,15 @@ func (p *DynamicPlugins) updateAndValidate(backstage api.Backstage, scheme *runt
 		return fmt.Errorf("failed to find initContainer named %s", dynamicPluginInitContainerName)
 	}
 
+	p.setCatalogIndexEnv(backstage, deployment, initContainer)
+
+	if err := p.mountRegistryAuth(backstage, deployment, initContainer); err != nil {
+		return err
+	}
+	if p.npmRegistry != "" {
+		deployment.setOrAppendEnvVar(initContainer, NpmConfigRegistryEnvVar, p.npmRegistry)
+	}
+
 	if p.enabledPluginsCM != nil {
 
 		if err := deployment.mountFilesFrom(containersFilter{names: []string{dynamicPluginInitContainerName}}, ConfigMapObjectKind,
@@ -224,6 +289,12 @@ func (p DynaPlugin) IsDisabled() bool {
 	return p.Disabled
 }
 
+// Packages returns the enabled plugin packages passed to the plugin installer.
+// It is populated only if the Operator processes dynamic plugins.
+func (p *DynamicPlugins) Packages() []api.PluginPackageStatus {
+	return p.packages
+}
+
 // Dependencies returns a list of plugin dependencies
 func (p *DynamicPlugins) Dependencies() ([]PluginDependency, error) {
 	//ps := p.dynaPlugins
@@ -320,9 +391,13 @@ func MergePluginsData(firstData, secondData string) (string, error) {
 		secondPluginsConfig.Plugins = resolvedPlugins
 	}
 
-	// Merge Plugins by package field
+	// Merge Plugins by package field, preserving the order plugins are first seen in
 	pluginMap := make(map[string]DynaPlugin)
+	var order []string
 	for _, plugin := range firstPluginsConfig.Plugins {
+		if _, found := pluginMap[plugin.Package]; !found {
+			order = append(order, plugin.Package)
+		}
 		pluginMap[plugin.Package] = plugin
 	}
 	for _, plugin := range secondPluginsConfig.Plugins {
@@ -342,6 +417,9 @@ func MergePluginsData(firstData, secondData string) (string, error) {
 			if plugin.Integrity != "" {
 				existingPlugin.Integrity = plugin.Integrity
 			}
+			if plugin.ConfigSchema != nil {
+				existingPlugin.ConfigSchema = plugin.ConfigSchema
+			}
 			if plugin.Enabled != nil {
 				existingPlugin.Enabled = plugin.Enabled
 				existingPlugin.Disabled = false
@@ -351,19 +429,20 @@ func MergePluginsData(firstData, secondData string) (string, error) {
 			}
 			pluginMap[plugin.Package] = existingPlugin
 		} else {
+			order = append(order, plugin.Package)
 			pluginMap[plugin.Package] = plugin
 		}
 	}
-	mergedPluginsConfig.Plugins = make([]DynaPlugin, 0, len(pluginMap))
-	for _, plugin := range pluginMap {
-		mergedPluginsConfig.Plugins = append(mergedPluginsConfig.Plugins, plugin)
+	mergedPluginsConfig.Plugins = make([]DynaPlugin, 0, len(order))
+	for _, pkg := range order {
+		mergedPluginsConfig.Plugins = append(mergedPluginsConfig.Plugins, pluginMap[pkg])
 	}
 
 	if secondPluginsConfig.Includes != nil && len(secondPluginsConfig.Includes) == 0 {
 		// if includes is empty explicitly, clean it
 		mergedPluginsConfig.Includes = make([]string, 0)
 	} else {
-		// otherwise merge ensuring uniqueness
+		// otherwise merge ensuring uniqueness, sorted for stable output
 		includeSet := make(map[string]struct{})
 		for _, include := range firstPluginsConfig.Includes {
 			includeSet[include] = struct{}{}
@@ -371,10 +450,7 @@ func MergePluginsData(firstData, secondData string) (string, error) {
 		for _, include := range secondPluginsConfig.Includes {
 			includeSet[include] = struct{}{}
 		}
-		mergedPluginsConfig.Includes = make([]string, 0, len(includeSet))
-		for include := range includeSet {
-			mergedPluginsConfig.Includes = append(mergedPluginsConfig.Includes, include)
-		}
+		mergedPluginsConfig.Includes = utils.SortedKeys(includeSet)
 	}
 
 	// Marshal the merged data back to YAML
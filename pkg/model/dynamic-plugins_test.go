@@ -691,6 +691,34 @@ plugins:
 
 }
 
+func TestMergeKeepsConfigSchema(t *testing.T) {
+	modelData := `
+plugins:
+ - package: "./plugin-a"
+   disabled: true
+   configSchema:
+     type: object
+     properties:
+       key1:
+         type: string
+`
+	specData := `
+plugins:
+ - package: "./plugin-a"
+   disabled: false
+   pluginConfig:
+     key1: "value1"
+`
+	mergedData, err := MergePluginsData(modelData, specData)
+	assert.NoError(t, err)
+
+	var mergedConfig DynaPluginsConfig
+	assert.NoError(t, yaml.Unmarshal([]byte(mergedData), &mergedConfig))
+	assert.Equal(t, 1, len(mergedConfig.Plugins))
+	assert.Equal(t, "object", mergedConfig.Plugins[0].ConfigSchema["type"])
+	assert.Equal(t, "value1", mergedConfig.Plugins[0].PluginConfig["key1"])
+}
+
 // TestExternalConfigMapMetadataNotReused verifies that when DynamicPluginsConfigMapName is set
 // and there is no default dynamic-plugins config, the code creates a fresh ConfigMap
 // and does NOT reuse the external ConfigMap's ObjectMeta (resourceVersion, uid, etc.).
@@ -738,3 +766,40 @@ func TestExternalConfigMapMetadataNotReused(t *testing.T) {
 	// Verify the name is set to the operator-managed name (not the external name)
 	assert.Equal(t, DynamicPluginsDefaultName(bs.Name), dp.ConfigMap.Name, "Name should be set to operator-managed name")
 }
+
+func TestNpmPackagesTxt(t *testing.T) {
+	t.Setenv(OperatorDPProcessingEnvVar, "true")
+
+	bs := testDynamicPluginsBackstage.DeepCopy()
+	bs.Spec.Application.DynamicPluginsConfigMapName = "dplugin"
+
+	testObj := createBackstageTest(*bs).withDefaultConfig(true).
+		addToDefaultConfig("dynamic-plugins.yaml", "npm-dynamic-plugins.yaml").
+		addToDefaultConfig("deployment.yaml", "rhdh-deployment.yaml")
+	testObj.externalConfig.DynamicPlugins = corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "dplugin"},
+		Data: map[string]string{DynamicPluginsFile: `
+plugins:
+  # inherits the version and the integrity of the default configuration
+  - package: "@backstage/plugin-catalog@{{inherit}}"
+    enabled: true
+  - package: "is-odd@^3.0.0"
+  - package: oci://quay.io/rhdh/plugin-a@sha256:abc
+`},
+	}
+
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	dp := model.GetRuntimeObject(DynamicPluginsKey).(*DynamicPlugins)
+	assert.Equal(t, "@backstage/plugin-catalog@1.2.3 sha512-Zm9vYmFy+/w==\nis-odd@^3.0.0\noci://quay.io/rhdh/plugin-a@sha256:abc",
+		dp.enabledPluginsCM.Data["packages.txt"])
+
+	testObj.externalConfig.DynamicPlugins.Data[DynamicPluginsFile] = `
+plugins:
+  - package: "is-odd@3.0.1"
+    integrity: "sha512-abc\nmalicious@1.0.0"
+`
+	_, err = InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.ErrorContains(t, err, "invalid integrity")
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+// EnvCollisions returns the environment variables of the Backstage Pod containers set from different ConfigMaps or Secrets,
+// in which case only one of the values is used. The keys of the objects injected with envFrom are checked if known.
+// Variables with a value override the ones from ConfigMaps and Secrets deliberately and are not reported.
+func (m *BackstageModel) EnvCollisions() []string {
+	podSpec := m.getDeployment().podSpec()
+	var errs []string
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			errs = append(errs, m.containerEnvCollisions(&containers[i])...)
+		}
+	}
+	return errs
+}
+
+// containerEnvCollisions returns the environment variables of the container set from different ConfigMaps or Secrets
+func (m *BackstageModel) containerEnvCollisions(container *corev1.Container) []string {
+	sources := map[string][]string{}
+	addSource := func(name string, kind ObjectKind, objectName string) {
+		source := fmt.Sprintf("%s %s", kind, objectName)
+		if !slices.Contains(sources[name], source) {
+			sources[name] = append(sources[name], source)
+		}
+	}
+
+	for _, from := range container.EnvFrom {
+		var kind ObjectKind
+		var objectName string
+		switch {
+		case from.ConfigMapRef != nil:
+			kind, objectName = ConfigMapObjectKind, from.ConfigMapRef.Name
+		case from.SecretRef != nil:
+			kind, objectName = SecretObjectKind, from.SecretRef.Name
+		default:
+			continue
+		}
+		keys, ok := m.dataObjectKeys(kind, objectName)
+		if !ok {
+			continue
+		}
+		for _, key := range keys {
+			addSource(from.Prefix+key, kind, objectName)
+		}
+	}
+	for _, env := range container.Env {
+		switch {
+		case env.ValueFrom == nil:
+		case env.ValueFrom.ConfigMapKeyRef != nil:
+			addSource(env.Name, ConfigMapObjectKind, env.ValueFrom.ConfigMapKeyRef.Name)
+		case env.ValueFrom.SecretKeyRef != nil:
+			addSource(env.Name, SecretObjectKind, env.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+
+	var errs []string
+	for _, name := range utils.SortedKeys(sources) {
+		if len(sources[name]) > 1 {
+			errs = append(errs, fmt.Sprintf("container %s: %s is set from %s", container.Name, name, strings.Join(sources[name], " and ")))
+		}
+	}
+	return errs
+}
@@ -5,6 +5,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
+// ExtConfigSyncLabel and BackstageNameAnnotation were set on the external config objects by the previous versions
+// of the Operator, they are only removed now
 const ExtConfigSyncLabel = "rhdh.redhat.com/ext-config-sync"
 const BackstageNameAnnotation = "rhdh.redhat.com/backstage-name"
 
@@ -17,6 +19,15 @@ type ExternalConfig struct {
 	ExtraEnvConfigMapKeys  map[string]DataObjectKeys
 	ExtraEnvSecretKeys     map[string]DataObjectKeys
 	ExtraPvcKeys           []string
+	RegistryAuthSecretKeys map[string]DataObjectKeys
+	// AppConfigData holds the data of spec.application.appConfig.configMaps, used to compute the effective app-config
+	AppConfigData map[string]map[string]string
+	// CatalogIndex holds the resolved spec.application.dynamicPlugins.catalogIndex images, the primary one first
+	CatalogIndex []ResolvedCatalogIndex
+	// PluginSchemas reads the configSchema of the plugin packages, the schemas are not read if nil
+	PluginSchemas PluginSchemaReader
+	// UserFlavours are the user flavours the instance may enable
+	UserFlavours UserFlavours
 
 	OpenShiftIngressDomain string
 
@@ -29,11 +40,13 @@ func NewExternalConfig() ExternalConfig {
 		RawConfig:              map[string]string{},
 		DynamicPlugins:         corev1.ConfigMap{},
 		AppConfigKeys:          map[string][]string{},
+		AppConfigData:          map[string]map[string]string{},
 		ExtraFileConfigMapKeys: map[string]DataObjectKeys{},
 		ExtraFileSecretKeys:    map[string]DataObjectKeys{},
 		ExtraEnvConfigMapKeys:  map[string]DataObjectKeys{},
 		ExtraEnvSecretKeys:     map[string]DataObjectKeys{},
 		ExtraPvcKeys:           []string{},
+		RegistryAuthSecretKeys: map[string]DataObjectKeys{},
 
 		WatchingHash: "",
 	}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+// FlavourParameter declares a parameter of the flavour in metadata.yaml,
+// its value is substituted into the flavour config files where {{ params.<name> }} is found
+type FlavourParameter struct {
+	// Name of the parameter
+	Name string `yaml:"name"`
+	// Type of the value: string, integer, number or boolean
+	Type string `yaml:"type"`
+	// Default value of the parameter, if not set the parameter has to be specified in spec.flavours
+	Default *apiextensionsv1.JSON `yaml:"default,omitempty"`
+	// Description of the parameter
+	Description string `yaml:"description,omitempty"`
+}
+
+// flavourParamRegex matches the {{ params.<name> }} placeholders in the flavour config files
+var flavourParamRegex = regexp.MustCompile(`\{\{\s*params\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// flavourParameters validates the parameters specified in spec.flavours against the declared ones
+// and returns the values of all the declared parameters, formatted to be substituted (strings as YAML scalars)
+func flavourParameters(declared []FlavourParameter, specified map[string]apiextensionsv1.JSON) (map[string]string, error) {
+	values := make(map[string]string, len(declared))
+	known := make(map[string]bool, len(declared))
+	var errs []string
+	for _, p := range declared {
+		known[p.Name] = true
+		value := p.Default
+		if v, ok := specified[p.Name]; ok {
+			value = &v
+		}
+		if value == nil {
+			errs = append(errs, fmt.Sprintf("parameter %s is required", p.Name))
+			continue
+		}
+		formatted, err := parameterValue(p.Type, value.Raw)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("parameter %s %s", p.Name, err))
+			continue
+		}
+		values[p.Name] = formatted
+	}
+	for _, name := range utils.SortedKeys(specified) {
+		if !known[name] {
+			errs = append(errs, fmt.Sprintf("unknown parameter %s", name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, ", "))
+	}
+	return values, nil
+}
+
+// sampleParameters returns the values the flavour config files are validated with:
+// the default values of the parameters, or the zero values of their types
+func sampleParameters(declared []FlavourParameter) map[string]string {
+	values := make(map[string]string, len(declared))
+	for _, p := range declared {
+		if p.Default != nil {
+			if value, err := parameterValue(p.Type, p.Default.Raw); err == nil {
+				values[p.Name] = value
+				continue
+			}
+		}
+		switch p.Type {
+		case "integer", "number":
+			values[p.Name] = "0"
+		case "boolean":
+			values[p.Name] = "false"
+		default:
+			values[p.Name] = `""`
+		}
+	}
+	return values
+}
+
+// parameterValue checks the JSON value is of the parameter type and formats it to be substituted
+func parameterValue(paramType string, raw []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("is not a valid value: %w", err)
+	}
+
+	switch paramType {
+	case "string":
+		if s, ok := value.(string); ok {
+			return yamlString(s)
+		}
+	case "integer":
+		if n, ok := value.(json.Number); ok {
+			if _, err := n.Int64(); err == nil {
+				return n.String(), nil
+			}
+		}
+	case "number":
+		if n, ok := value.(json.Number); ok {
+			return n.String(), nil
+		}
+	case "boolean":
+		if b, ok := value.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+	default:
+		return "", fmt.Errorf("has unsupported type '%s'", paramType)
+	}
+	return "", fmt.Errorf("must be of type %s", paramType)
+}
+
+// yamlString formats the string as a single line YAML scalar, quoted and escaped if needed,
+// so the value can not change the structure of the document it is substituted into
+func yamlString(s string) (string, error) {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("can not be formatted: %w", err)
+	}
+	scalar := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(scalar, "\n") {
+		// block scalars depend on the indentation, a JSON string is a valid double-quoted YAML scalar
+		out, err = json.Marshal(s)
+		if err != nil {
+			return "", fmt.Errorf("can not be formatted: %w", err)
+		}
+		scalar = string(out)
+	}
+	return scalar, nil
+}
+
+// substituteParameters replaces the {{ params.<name> }} placeholders in the flavour config file with the parameter values,
+// referencing an undeclared parameter is an error
+func substituteParameters(content []byte, values map[string]string) ([]byte, error) {
+	var unknown []string
+	result := flavourParamRegex.ReplaceAllFunc(content, func(placeholder []byte) []byte {
+		name := string(flavourParamRegex.FindSubmatch(placeholder)[1])
+		value, ok := values[name]
+		if !ok {
+			unknown = append(unknown, name)
+			return placeholder
+		}
+		return []byte(value)
+	})
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("undeclared parameters referenced: %s", strings.Join(unknown, ", "))
+	}
+	return result, nil
+}
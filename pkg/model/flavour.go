@@ -1,82 +1,354 @@
 package model
 
 import (
+	"cmp"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"golang.org/x/mod/semver"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/yaml"
 
 	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
 )
 
+// FlavourMetadataFile describes the flavour, in the flavour directory or the user flavour ConfigMap
+const FlavourMetadataFile = "metadata.yaml"
+
+// FlavourLabel marks the ConfigMaps in the Operator namespace providing user flavours,
+// the name of the ConfigMap is the name of the flavour
+const FlavourLabel = "rhdh.redhat.com/flavour"
+
 // FlavourMetadata represents the metadata.yaml file in a flavour directory
 type FlavourMetadata struct {
 	// EnabledByDefault controls whether this flavour is enabled when spec.flavours is not specified
 	EnabledByDefault bool `yaml:"enabledByDefault"`
+	// RequiredInfrastructure lists the plugin infrastructure components (see plugin-infra directory) the flavour requires
+	RequiredInfrastructure []string `yaml:"requiredInfrastructure,omitempty"`
+	// Requires lists the flavours enabled together with this flavour, unless explicitly disabled in spec.flavours
+	Requires []string `yaml:"requires,omitempty"`
+	// ConflictsWith lists the flavours which can not be enabled together with this flavour
+	ConflictsWith []string `yaml:"conflictsWith,omitempty"`
+	// Priority defines the merge order of the flavour configs, the flavours with higher priority are merged later
+	// and override the ones with lower priority. Flavours with the same priority are ordered by spec, then by name.
+	Priority int `yaml:"priority,omitempty"`
+	// MinOperatorVersion is the minimum Operator version (semver) supporting the flavour
+	MinOperatorVersion string `yaml:"minOperatorVersion,omitempty"`
+	// Parameters the flavour config files are parameterized with
+	Parameters []FlavourParameter `yaml:"parameters,omitempty"`
 }
 
+// OperatorVersion is the version of the Operator the flavours' minOperatorVersion is checked against,
+// set at build time with -ldflags "-X github.com/redhat-developer/rhdh-operator/pkg/model.OperatorVersion=<version>".
+// Empty for development builds, which support all the flavours.
+var OperatorVersion = ""
+
 // enabledFlavour represents a flavour that is enabled for this Backstage instance
 type enabledFlavour struct {
-	name     string
-	basePath string
+	name                   string
+	basePath               string
+	requiredInfrastructure []string
+	priority               int
+	// values of the flavour parameters by name
+	parameters map[string]string
+	// files of the user flavour by key, nil for the flavours read from basePath
+	files map[string][]byte
+	// configMap the user flavour is loaded from
+	configMap string
+}
+
+// status returns the status of the flavour reported in the Backstage status
+func (f enabledFlavour) status() api.FlavourStatus {
+	return api.FlavourStatus{Name: f.name, ConfigMap: f.configMap}
+}
+
+// UserFlavour is a flavour loaded from a ConfigMap labeled with FlavourLabel in the Operator namespace.
+// It has the same layout as the flavour directories: the metadata.yaml key and a key per config file.
+type UserFlavour struct {
+	// Name of the flavour, the name of the ConfigMap
+	Name string
+	// Err is the reason the flavour is invalid, an invalid flavour can not be enabled
+	Err error
+
+	metadata FlavourMetadata
+	files    map[string][]byte
+}
+
+// UserFlavours are the user flavours available to the Backstage instances, by name
+type UserFlavours map[string]UserFlavour
+
+// NewUserFlavour loads and validates the user flavour from the ConfigMap:
+// the keys have to be the flavoured config files (e.g. app-config.yaml, deployment.yaml) containing valid objects,
+// and the flavour can not replace a flavour shipped with the Operator
+func NewUserFlavour(cm *corev1.ConfigMap, scheme runtime.Scheme) UserFlavour {
+	flavour := UserFlavour{Name: cm.Name, files: map[string][]byte{}}
+
+	builtIn, err := loadAllFlavours(FlavoursDir())
+	if err != nil {
+		flavour.Err = err
+		return flavour
+	}
+	if _, ok := builtIn[cm.Name]; ok {
+		flavour.Err = fmt.Errorf("flavour '%s' is shipped with the Operator and can not be replaced", cm.Name)
+		return flavour
+	}
+
+	if data, ok := cm.Data[FlavourMetadataFile]; ok {
+		if err := yaml.Unmarshal([]byte(data), &flavour.metadata); err != nil {
+			flavour.Err = fmt.Errorf("failed to parse %s: %w", FlavourMetadataFile, err)
+			return flavour
+		}
+	}
+
+	for _, key := range utils.SortedKeys(cm.Data) {
+		if key == FlavourMetadataFile {
+			continue
+		}
+		if !isFlavouredConfig(key) {
+			flavour.Err = fmt.Errorf("%s is not a config file supported by flavours", key)
+			return flavour
+		}
+		content, err := substituteParameters([]byte(cm.Data[key]), sampleParameters(flavour.metadata.Parameters))
+		if err != nil {
+			flavour.Err = fmt.Errorf("invalid %s: %w", key, err)
+			return flavour
+		}
+		if _, err := utils.ReadYamls(content, nil, scheme); err != nil {
+			flavour.Err = fmt.Errorf("failed to parse %s: %w", key, err)
+			return flavour
+		}
+		flavour.files[key] = []byte(cm.Data[key])
+	}
+	if len(cm.BinaryData) > 0 {
+		flavour.Err = fmt.Errorf("binaryData is not supported")
+	}
+	return flavour
+}
+
+// isFlavouredConfig returns whether the config file with the key can be extended by flavours
+func isFlavouredConfig(key string) bool {
+	for _, conf := range runtimeConfig {
+		if conf.Key == key {
+			return conf.MergeFunc != nil
+		}
+	}
+	return false
+}
+
+// FlavoursDir returns the directory the flavours shipped with the Operator are read from
+func FlavoursDir() string {
+	return filepath.Join(os.Getenv("LOCALBIN"), "default-config", "flavours")
 }
 
 // GetEnabledFlavours determines which flavours should be enabled based on the BackstageSpec.
 // Algorithm:
-// 1. Load all available flavours with their enabledByDefault status from metadata.yaml
+// 1. Load all available flavours with their enabledByDefault status from metadata.yaml,
+// the ones shipped with the Operator and the valid user flavours
 // 2. Override enabled status with values from spec.Flavours (if provided)
-// 3. Return only the enabled flavours
+// 3. Enable the flavours required by the enabled ones, check conflicts, the minimum Operator version and the parameters
+// 4. Return only the enabled flavours, ordered by priority, then by spec, then by name
 //
+// Resolution failures are reported for every flavour in the returned error.
 // This should be called once per Backstage reconciliation and the result reused for all config files.
-func GetEnabledFlavours(spec api.BackstageSpec) ([]enabledFlavour, error) {
+func GetEnabledFlavours(spec api.BackstageSpec, userFlavours UserFlavours) ([]enabledFlavour, error) {
 
 	// if explicit empty array specified - disable all
 	if spec.Flavours != nil && len(*spec.Flavours) == 0 {
 		return []enabledFlavour{}, nil
 	}
 
-	localBin := os.Getenv("LOCALBIN")
-	flavoursDir := filepath.Join(localBin, "default-config", "flavours")
+	flavoursDir := FlavoursDir()
 
 	// Step 1: Load all flavours with their default enabled status
 	allFlavours, err := loadAllFlavours(flavoursDir)
 	if err != nil {
 		return nil, err
 	}
+	invalid := map[string]error{}
+	for name, uf := range userFlavours {
+		if _, ok := allFlavours[name]; ok {
+			// validated on load, not expected unless the ConfigMap was loaded before the flavour was shipped
+			continue
+		}
+		if uf.Err != nil {
+			invalid[name] = uf.Err
+			continue
+		}
+		allFlavours[name] = flavourInfo{
+			enabled:   uf.metadata.EnabledByDefault,
+			metadata:  uf.metadata,
+			files:     uf.files,
+			configMap: name,
+		}
+	}
 
 	// Step 2: Override enabled status from spec
+	disabled := map[string]bool{}
 	if spec.Flavours != nil {
 		flavours := *spec.Flavours
 
 		for _, f := range flavours {
 			flavour, exists := allFlavours[f.Name]
 			if !exists {
-				return nil, fmt.Errorf("flavour '%s' not found in %s", f.Name, flavoursDir)
+				if err, ok := invalid[f.Name]; ok {
+					if !f.Enabled {
+						continue
+					}
+					return nil, fmt.Errorf("flavour '%s' is invalid: %w", f.Name, err)
+				}
+				return nil, fmt.Errorf("flavour '%s' not found in %s or the flavour ConfigMaps", f.Name, flavoursDir)
 			}
 			flavour.enabled = f.Enabled
+			flavour.specParameters = f.Parameters
 			allFlavours[f.Name] = flavour
+			disabled[f.Name] = !f.Enabled
 		}
 	}
 
-	// Step 3: Collect enabled flavours
+	// Step 3: Resolve the requirements, conflicts and minimum Operator version
+	if err := resolveFlavours(allFlavours, disabled); err != nil {
+		return nil, err
+	}
+
+	// Step 4: Collect enabled flavours, the ones listed in spec first (in spec order),
+	// then the other enabled ones ordered by name, and sort them by priority.
+	// The order defines the merge precedence of flavour configs, so it has to be stable.
 	var result []enabledFlavour
-	for name, flavour := range allFlavours {
-		if flavour.enabled {
-			result = append(result, enabledFlavour{
-				name:     name,
-				basePath: flavour.basePath,
-			})
+	added := make(map[string]bool)
+	if spec.Flavours != nil {
+		for _, f := range *spec.Flavours {
+			if flavour := allFlavours[f.Name]; flavour.enabled && !added[f.Name] {
+				result = append(result, flavour.enabledFlavour(f.Name))
+				added[f.Name] = true
+			}
+		}
+	}
+	for _, name := range utils.SortedKeys(allFlavours) {
+		if flavour := allFlavours[name]; flavour.enabled && !added[name] {
+			result = append(result, flavour.enabledFlavour(name))
 		}
 	}
+	slices.SortStableFunc(result, func(a, b enabledFlavour) int {
+		return cmp.Compare(a.priority, b.priority)
+	})
 
 	return result, nil
 }
 
+// resolveFlavours enables the flavours required by the enabled ones, unless explicitly disabled in the spec,
+// and checks the enabled flavours do not conflict, support the Operator version and are given valid parameters.
+// The returned error reports the failures of all the flavours.
+func resolveFlavours(flavours map[string]flavourInfo, disabled map[string]bool) error {
+	failures := map[string][]string{}
+	fail := func(name string, format string, args ...any) {
+		failures[name] = append(failures[name], fmt.Sprintf(format, args...))
+	}
+
+	// enable the requirements until no more flavours are enabled
+	for changed := true; changed; {
+		changed = false
+		for _, name := range utils.SortedKeys(flavours) {
+			if !flavours[name].enabled {
+				continue
+			}
+			for _, req := range flavours[name].metadata.Requires {
+				if required, ok := flavours[req]; ok && !required.enabled && !disabled[req] {
+					required.enabled = true
+					flavours[req] = required
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, name := range utils.SortedKeys(flavours) {
+		flavour := flavours[name]
+		if !flavour.enabled {
+			continue
+		}
+		for _, req := range flavour.metadata.Requires {
+			if required, ok := flavours[req]; !ok {
+				fail(name, "requires unknown flavour '%s'", req)
+			} else if !required.enabled {
+				fail(name, "requires flavour '%s' disabled in spec.flavours", req)
+			}
+		}
+		for _, other := range utils.SortedKeys(flavours) {
+			if other == name || !flavours[other].enabled {
+				continue
+			}
+			if slices.Contains(flavour.metadata.ConflictsWith, other) || slices.Contains(flavours[other].metadata.ConflictsWith, name) {
+				fail(name, "conflicts with flavour '%s'", other)
+			}
+		}
+		if ok, err := supportsOperatorVersion(flavour.metadata.MinOperatorVersion); err != nil {
+			fail(name, "%s", err.Error())
+		} else if !ok {
+			fail(name, "requires Operator version %s or later, the Operator version is %s", flavour.metadata.MinOperatorVersion, OperatorVersion)
+		}
+		if values, err := flavourParameters(flavour.metadata.Parameters, flavour.specParameters); err != nil {
+			fail(name, "%s", err.Error())
+		} else {
+			flavour.parameters = values
+			flavours[name] = flavour
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, name := range utils.SortedKeys(failures) {
+		msgs = append(msgs, fmt.Sprintf("flavour '%s' %s", name, strings.Join(failures[name], ", ")))
+	}
+	return fmt.Errorf("failed to resolve flavours: %s", strings.Join(msgs, "; "))
+}
+
+// supportsOperatorVersion returns whether the Operator version is at least the minimum version,
+// always true if either of them is not specified or the Operator version is not a release one (e.g. a development build)
+func supportsOperatorVersion(minVersion string) (bool, error) {
+	if minVersion == "" {
+		return true, nil
+	}
+	minimum := "v" + strings.TrimPrefix(minVersion, "v")
+	if !semver.IsValid(minimum) {
+		return false, fmt.Errorf("invalid minOperatorVersion %s", minVersion)
+	}
+	current := "v" + strings.TrimPrefix(OperatorVersion, "v")
+	if !semver.IsValid(current) {
+		return true, nil
+	}
+	return semver.Compare(current, minimum) >= 0, nil
+}
+
 // flavourInfo holds information about a discovered flavour
 type flavourInfo struct {
-	basePath string
-	enabled  bool
+	basePath  string
+	enabled   bool
+	metadata  FlavourMetadata
+	files     map[string][]byte
+	configMap string
+	// parameters specified in spec.flavours and their resolved values
+	specParameters map[string]apiextensionsv1.JSON
+	parameters     map[string]string
+}
+
+func (f flavourInfo) enabledFlavour(name string) enabledFlavour {
+	return enabledFlavour{
+		name:                   name,
+		basePath:               f.basePath,
+		requiredInfrastructure: f.metadata.RequiredInfrastructure,
+		priority:               f.metadata.Priority,
+		parameters:             f.parameters,
+		files:                  f.files,
+		configMap:              f.configMap,
+	}
 }
 
 // loadAllFlavours loads all available flavours from the flavours directory
@@ -109,6 +381,7 @@ func loadAllFlavours(flavoursDir string) (map[string]flavourInfo, error) {
 		flavours[flavourName] = flavourInfo{
 			basePath: flavourPath,
 			enabled:  metadata.EnabledByDefault,
+			metadata: *metadata,
 		}
 	}
 
@@ -117,7 +390,7 @@ func loadAllFlavours(flavoursDir string) (map[string]flavourInfo, error) {
 
 // loadFlavourMetadata loads metadata.yaml from a flavour directory
 func loadFlavourMetadata(flavourPath string) (*FlavourMetadata, error) {
-	metadataPath := filepath.Join(flavourPath, "metadata.yaml")
+	metadataPath := filepath.Join(flavourPath, FlavourMetadataFile)
 
 	data, err := os.ReadFile(metadataPath)
 	if err != nil {
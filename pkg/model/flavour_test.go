@@ -8,7 +8,10 @@ import (
 	"github.com/redhat-developer/rhdh-operator/pkg/platform"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 var testFlavoursBackstage = api.Backstage{
@@ -107,9 +110,8 @@ func TestFlavoursWithExplicitEnabled(t *testing.T) {
 	container := model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment).container()
 	assert.NotNil(t, findEnvVar(container.Env, "FLAVOR1_ENABLED"), "deployment should have FLAVOR1_ENABLED env var")
 	assert.NotNil(t, findEnvVar(container.Env, "FLAVOR2_ENABLED"), "deployment should have FLAVOR2_ENABLED env var")
-	// Don't assert which flavor label wins - merge order between flavours is not guaranteed
-	// Just verify that a flavor label exists (from one of the flavours)
-	assert.NotEmpty(t, model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment).deployable.GetObject().GetLabels()["flavor"], "deployment should have a flavor label")
+	// flavours are merged in spec order, then by name: flavor2 (spec), flavor1 (default), so flavor1 wins
+	assert.Equal(t, "flavor1", model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment).deployable.GetObject().GetLabels()["flavor"], "deployment should have the last merged flavor label")
 
 	// Verify dynamic-plugins: should have all three
 	var dpConfig DynaPluginsConfig
@@ -217,3 +219,224 @@ func TestFlavoursWithEmptyArray(t *testing.T) {
 	assert.Nil(t, findConfigMapBySource(model.GetRuntimeObject(AppConfigKey).(*AppConfig).ConfigMaps.Items, "flavour-flavor2"), "flavor2 should NOT exist")
 
 }
+
+func testUserFlavours(flavours ...UserFlavour) UserFlavours {
+	result := UserFlavours{}
+	for _, f := range flavours {
+		result[f.Name] = f
+	}
+	return result
+}
+
+func TestUserFlavours(t *testing.T) {
+	bs := testFlavoursBackstage.DeepCopy()
+
+	testObj := createBackstageTest(*bs).withConfigPath("./testdata/testflavours").withLocalDb(false)
+	bs = testObj.backstage.DeepCopy()
+
+	userFlavour := func(name string, data map[string]string) UserFlavour {
+		return NewUserFlavour(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "operator-ns", Labels: map[string]string{FlavourLabel: ""}},
+			Data:       data,
+		}, *testObj.scheme)
+	}
+
+	golden := userFlavour("golden", map[string]string{
+		FlavourMetadataFile: "enabledByDefault: true",
+		AppConfigKey: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: golden-app-config
+data:
+  golden.app-config.yaml: |
+    app:
+      title: Golden Backstage
+`,
+	})
+	assert.NoError(t, golden.Err)
+
+	// invalid flavours
+	shipped := userFlavour("flavor1", map[string]string{})
+	assert.ErrorContains(t, shipped.Err, "is shipped with the Operator")
+	unsupported := userFlavour("unsupported", map[string]string{ServiceKey: "apiVersion: v1\nkind: Service"})
+	assert.ErrorContains(t, unsupported.Err, "service.yaml is not a config file supported by flavours")
+	malformed := userFlavour("malformed", map[string]string{AppConfigKey: "kind: [ConfigMap"})
+	assert.ErrorContains(t, malformed.Err, "failed to parse app-config.yaml")
+
+	testObj.externalConfig.UserFlavours = testUserFlavours(golden, shipped, unsupported, malformed)
+
+	// enabled by default, after the shipped ones
+	model, err := InitObjects(context.TODO(), testObj.backstage, testObj.externalConfig, platform.Kubernetes, testObj.scheme)
+	assert.NoError(t, err)
+	assert.NotNil(t, findConfigMapBySource(model.GetRuntimeObject(AppConfigKey).(*AppConfig).ConfigMaps.Items, "flavour-golden"), "golden flavour app-config should exist")
+	assert.Equal(t, []api.FlavourStatus{{Name: "flavor1"}, {Name: "flavor3"}, {Name: "golden", ConfigMap: "golden"}}, model.FlavoursStatus())
+
+	// disabled invalid flavour is ignored
+	bs.Spec.Flavours = &[]api.Flavour{{Name: "golden", Enabled: false}, {Name: "malformed", Enabled: false}}
+	model, err = InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Kubernetes, testObj.scheme)
+	assert.NoError(t, err)
+	assert.Nil(t, findConfigMapBySource(model.GetRuntimeObject(AppConfigKey).(*AppConfig).ConfigMaps.Items, "flavour-golden"), "golden flavour app-config should NOT exist")
+
+	// enabled invalid flavour fails
+	bs.Spec.Flavours = &[]api.Flavour{{Name: "malformed", Enabled: true}}
+	_, err = InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Kubernetes, testObj.scheme)
+	assert.ErrorContains(t, err, "flavour 'malformed' is invalid: failed to parse app-config.yaml")
+}
+
+func TestFlavoursResolution(t *testing.T) {
+	createBackstageTest(testFlavoursBackstage).withConfigPath("./testdata/testflavours")
+
+	metadataFlavour := func(name, metadata string) UserFlavour {
+		return NewUserFlavour(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data:       map[string]string{FlavourMetadataFile: metadata},
+		}, runtime.Scheme{})
+	}
+	userFlavours := testUserFlavours(
+		metadataFlavour("a", "requires: [b]\npriority: 10"),
+		metadataFlavour("b", "requires: [c]"),
+		metadataFlavour("c", "priority: -1"),
+		metadataFlavour("d", "conflictsWith: [a]"),
+		metadataFlavour("e", "minOperatorVersion: 2.1.0"),
+		metadataFlavour("f", "requires: [unknown]\nminOperatorVersion: latest"),
+	)
+
+	names := func(flavours []enabledFlavour) []string {
+		var result []string
+		for _, f := range flavours {
+			result = append(result, f.name)
+		}
+		return result
+	}
+
+	// the requirements are enabled, ordered by priority, then spec, then name
+	flavours, err := GetEnabledFlavours(api.BackstageSpec{Flavours: &[]api.Flavour{{Name: "flavor2", Enabled: true}, {Name: "a", Enabled: true}}}, userFlavours)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "flavor2", "b", "flavor1", "flavor3", "a"}, names(flavours))
+
+	// required flavour disabled explicitly
+	_, err = GetEnabledFlavours(api.BackstageSpec{Flavours: &[]api.Flavour{{Name: "a", Enabled: true}, {Name: "c", Enabled: false}}}, userFlavours)
+	assert.EqualError(t, err, "failed to resolve flavours: flavour 'b' requires flavour 'c' disabled in spec.flavours")
+
+	// failures of every flavour are reported
+	_, err = GetEnabledFlavours(api.BackstageSpec{Flavours: &[]api.Flavour{{Name: "a", Enabled: true}, {Name: "d", Enabled: true}, {Name: "f", Enabled: true}}}, userFlavours)
+	assert.EqualError(t, err, "failed to resolve flavours: flavour 'a' conflicts with flavour 'd'; flavour 'd' conflicts with flavour 'a'; "+
+		"flavour 'f' requires unknown flavour 'unknown', invalid minOperatorVersion latest")
+
+	// minimum Operator version, not checked for development builds
+	_, err = GetEnabledFlavours(api.BackstageSpec{Flavours: &[]api.Flavour{{Name: "e", Enabled: true}}}, userFlavours)
+	assert.NoError(t, err)
+	OperatorVersion = "2.0.3"
+	defer func() { OperatorVersion = "" }()
+	_, err = GetEnabledFlavours(api.BackstageSpec{Flavours: &[]api.Flavour{{Name: "e", Enabled: true}}}, userFlavours)
+	assert.EqualError(t, err, "failed to resolve flavours: flavour 'e' requires Operator version 2.1.0 or later, the Operator version is 2.0.3")
+	OperatorVersion = "v2.1.0"
+	_, err = GetEnabledFlavours(api.BackstageSpec{Flavours: &[]api.Flavour{{Name: "e", Enabled: true}}}, userFlavours)
+	assert.NoError(t, err)
+}
+
+func TestSubstituteParameters(t *testing.T) {
+	values, err := flavourParameters([]FlavourParameter{{Name: "plain", Type: "string"}, {Name: "quoted", Type: "string"},
+		{Name: "multiline", Type: "string"}, {Name: "reserved", Type: "string"}, {Name: "count", Type: "integer"}},
+		map[string]apiextensionsv1.JSON{
+			"plain":     {Raw: []byte(`"quay.io/my-org/image:1.0"`)},
+			"quoted":    {Raw: []byte(`"it's \"quoted\""`)},
+			"multiline": {Raw: []byte(`"line 1\nline 2: value"`)},
+			"reserved":  {Raw: []byte(`"true"`)},
+			"count":     {Raw: []byte(`3`)},
+		})
+	assert.NoError(t, err)
+
+	content, err := substituteParameters([]byte(`plain: {{ params.plain }}
+quoted: {{ params.quoted }}
+multiline: {{ params.multiline }}
+reserved: {{ params.reserved }}
+count: {{ params.count }}
+`), values)
+	assert.NoError(t, err)
+	assert.Equal(t, `plain: quay.io/my-org/image:1.0
+quoted: it's "quoted"
+multiline: "line 1\nline 2: value"
+reserved: "true"
+count: 3
+`, string(content))
+
+	var parsed map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(content, &parsed))
+	assert.Equal(t, map[string]interface{}{"plain": "quay.io/my-org/image:1.0", "quoted": `it's "quoted"`,
+		"multiline": "line 1\nline 2: value", "reserved": "true", "count": 3}, parsed)
+}
+
+func TestFlavourParameters(t *testing.T) {
+	bs := testFlavoursBackstage.DeepCopy()
+	testObj := createBackstageTest(*bs).withConfigPath("./testdata/testflavours").withLocalDb(false)
+
+	params := NewUserFlavour(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "params"},
+		Data: map[string]string{
+			FlavourMetadataFile: `parameters:
+  - name: image
+    type: string
+  - name: replicas
+    type: integer
+    default: 2
+  - name: debug
+    type: boolean
+    default: false
+`,
+			DeploymentKey: `apiVersion: apps/v1
+kind: Deployment
+spec:
+  replicas: {{ params.replicas }}
+  template:
+    spec:
+      containers:
+        - name: backstage-backend
+          image: {{params.image}}
+          env:
+            - name: DEBUG
+              value: "{{ params.debug }}"
+`,
+		},
+	}, *testObj.scheme)
+	assert.NoError(t, params.Err)
+	// the placeholders have to reference declared parameters
+	undeclared := NewUserFlavour(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "undeclared"},
+		Data:       map[string]string{AppConfigKey: "apiVersion: v1\nkind: ConfigMap\ndata:\n  key: '{{ params.unknown }}'"},
+	}, *testObj.scheme)
+	assert.EqualError(t, undeclared.Err, "invalid app-config.yaml: undeclared parameters referenced: unknown")
+	testObj.externalConfig.UserFlavours = testUserFlavours(params)
+
+	flavour := func(name string, parameters map[string]string) api.Flavour {
+		f := api.Flavour{Name: name, Enabled: true, Parameters: map[string]apiextensionsv1.JSON{}}
+		for k, v := range parameters {
+			f.Parameters[k] = apiextensionsv1.JSON{Raw: []byte(v)}
+		}
+		return f
+	}
+
+	// substituted with the specified and default values
+	bs = testObj.backstage.DeepCopy()
+	bs.Spec.Flavours = &[]api.Flavour{flavour("params", map[string]string{"image": `"my-image:1.0"`, "debug": "true"})}
+	model, err := InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Kubernetes, testObj.scheme)
+	assert.NoError(t, err)
+	deployment := model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment)
+	assert.Equal(t, int32(2), *deployment.deployable.SpecReplicas())
+	assert.Equal(t, "my-image:1.0", deployment.container().Image)
+	assert.Equal(t, "true", findEnvVar(deployment.container().Env, "DEBUG").Value)
+
+	// the string values are inserted as YAML scalars, whatever they contain
+	bs.Spec.Flavours = &[]api.Flavour{flavour("params", map[string]string{"image": `"it's: \"my\"\nimage # 1.0"`})}
+	model, err = InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Kubernetes, testObj.scheme)
+	assert.NoError(t, err)
+	deployment = model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment)
+	assert.Equal(t, "it's: \"my\"\nimage # 1.0", deployment.container().Image)
+	assert.Equal(t, "false", findEnvVar(deployment.container().Env, "DEBUG").Value)
+
+	// the specified parameters are validated
+	bs.Spec.Flavours = &[]api.Flavour{flavour("params", map[string]string{"replicas": "1.5", "debug": `"yes"`, "other": "1"})}
+	_, err = InitObjects(context.TODO(), *bs, testObj.externalConfig, platform.Kubernetes, testObj.scheme)
+	assert.ErrorContains(t, err, "flavour 'params' parameter image is required, parameter replicas must be of type integer, "+
+		"parameter debug must be of type boolean, unknown parameter other")
+}
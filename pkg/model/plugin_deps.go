@@ -1,12 +1,23 @@
 package model
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+	"gopkg.in/yaml.v2"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
@@ -15,7 +26,76 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-func GetPluginDeps(backstage api.Backstage, plugins DynamicPlugins, scheme *runtime.Scheme) ([]*unstructured.Unstructured, error) {
+// PluginDepsTemplateData is the data model plugin dependency manifests are rendered with.
+// Plugin dependency files are Go text/template templates, for example:
+//
+//	metadata:
+//	  name: my-secret-{{ .Backstage.Name }}
+//	  namespace: {{ index .Params "namespace" | default .Backstage.Namespace }}
+//	{{- if .Platform.IsOpenShift }}
+//	...
+//	{{- end }}
+//
+// Referencing a parameter which is not passed with .Params.<name> fails the rendering,
+// optional parameters are referenced with index: {{ index .Params "name" }}.
+// The legacy {{backstage-name}} and {{backstage-ns}} placeholders are still supported.
+type PluginDepsTemplateData struct {
+	// Backstage instance the dependencies are applied for
+	Backstage PluginDepsBackstage
+	// Platform the Operator runs on
+	Platform PluginDepsPlatform
+	// Names of the flavours enabled for the Backstage instance
+	Flavours []string
+	// Parameters of the dependency, passed with dependencies[].parameters of the plugin in dynamic-plugins.yaml
+	Params map[string]interface{}
+}
+
+type PluginDepsBackstage struct {
+	Name      string
+	Namespace string
+}
+
+type PluginDepsPlatform struct {
+	Name        string
+	IsOpenShift bool
+}
+
+// pluginDepMetadataFile is the optional file in a dependency directory describing the dependency
+const pluginDepMetadataFile = "metadata.yaml"
+
+// SharedPluginDepLabel marks objects of shared plugin dependencies, its value is the dependency reference
+const SharedPluginDepLabel = "rhdh.redhat.com/shared-plugin-dep"
+
+// PluginDepOwnerLabelPrefix prefixes the labels of shared plugin dependency objects,
+// one per Backstage instance using the object
+const PluginDepOwnerLabelPrefix = "rhdh.redhat.com/owner-"
+
+// PluginDepMetadata represents the metadata.yaml file in a plugin dependency directory
+type PluginDepMetadata struct {
+	// Order in which the dependency is applied, dependencies with lower order are applied first
+	Order int `yaml:"order,omitempty"`
+	// RequiredCRDs lists names of the CRDs (e.g. sonataflowplatforms.sonataflow.org) which have to be installed
+	// in the cluster before the dependency is applied
+	RequiredCRDs []string `yaml:"requiredCRDs,omitempty"`
+	// Platforms the dependency applies to (e.g. OpenShift, Kubernetes), all if empty.
+	// Kubernetes matches any platform but OpenShift.
+	Platforms []string `yaml:"platforms,omitempty"`
+	// Shared dependencies can be used by several Backstage instances. Their objects are not owned
+	// by a Backstage instance but labeled with the set of instances using them, and deleted
+	// when the last of these instances stops using them. Required for cluster-scoped objects.
+	Shared bool `yaml:"shared,omitempty"`
+}
+
+// PluginDep is a plugin dependency resolved from the plugin-deps directory
+type PluginDep struct {
+	Ref      string
+	Metadata PluginDepMetadata
+	Objects  []*unstructured.Unstructured
+}
+
+// GetPluginDeps resolves the dependencies of enabled plugins, with the flavours the instance enables
+// among the shipped and the user flavours, ordered the way they have to be applied
+func GetPluginDeps(backstage api.Backstage, userFlavours UserFlavours, plugins DynamicPlugins, platform platform.Platform, scheme *runtime.Scheme) ([]PluginDep, error) {
 
 	dir, ok := os.LookupEnv("PLUGIN_DEPS_DIR_backstage")
 	if !ok {
@@ -27,100 +107,246 @@ func GetPluginDeps(backstage api.Backstage, plugins DynamicPlugins, scheme *runt
 		return nil, fmt.Errorf("failed to get plugin dependencies: %w", err)
 	}
 
-	//get refs from enabled
-	var refs []string
-	for _, dep := range pdeps {
-		if dep.Ref != "" {
-			refs = append(refs, dep.Ref)
-		}
+	flavours, err := GetEnabledFlavours(backstage.Spec, userFlavours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine enabled flavours: %w", err)
+	}
+	data := PluginDepsTemplateData{
+		Backstage: PluginDepsBackstage{Name: backstage.Name, Namespace: backstage.Namespace},
+		Platform:  PluginDepsPlatform{Name: platform.Name, IsOpenShift: platform.IsOpenshift()},
+		Flavours:  make([]string, 0, len(flavours)),
+	}
+	for _, f := range flavours {
+		data.Flavours = append(data.Flavours, f.name)
 	}
 
-	objs, err := ReadPluginDeps(dir, backstage.Name, backstage.Namespace, refs)
+	deps, err := ReadPluginDeps(dir, data, pdeps)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read plugin dependencies: %w", err)
 	}
 
-	for _, obj := range objs {
-		if obj.GetNamespace() == "" {
-			obj.SetNamespace(backstage.Namespace)
-		}
-		err = controllerutil.SetControllerReference(&backstage, obj, scheme)
-		if err != nil {
-			return nil, fmt.Errorf("failed to set controller reference for plugin dependency %s: %w", obj.GetName(), err)
+	for _, dep := range deps {
+		for _, obj := range dep.Objects {
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(backstage.Namespace)
+			}
+			if dep.Metadata.Shared {
+				labels := obj.GetLabels()
+				if labels == nil {
+					labels = map[string]string{}
+				}
+				labels[SharedPluginDepLabel] = dep.Ref
+				labels[PluginDepOwnerLabel(backstage)] = "true"
+				obj.SetLabels(labels)
+				continue
+			}
+			err = controllerutil.SetControllerReference(&backstage, obj, scheme)
+			if err != nil {
+				return nil, fmt.Errorf("failed to set controller reference for plugin dependency %s: %w", obj.GetName(), err)
+			}
 		}
 	}
 
-	return objs, nil
+	return deps, nil
 }
 
-// ReadPluginDeps reads the plugin dependencies from the specified directory
-// and returns a slice of unstructured.Unstructured objects.
-func ReadPluginDeps(rootDir, bsName, bsNamespace string, enabled []string) ([]*unstructured.Unstructured, error) {
-
-	if !utils.DirectoryExists(rootDir) {
-		return []*unstructured.Unstructured{}, nil
-	}
-
-	var objects []*unstructured.Unstructured
+// PluginDepOwnerLabel returns the label marking the Backstage instance as a user of shared plugin dependency objects.
+// As namespace and name do not fit label restrictions, the label name is derived from their hash.
+func PluginDepOwnerLabel(backstage api.Backstage) string {
+	hash := sha256.Sum256([]byte(backstage.Namespace + "/" + backstage.Name))
+	return PluginDepOwnerLabelPrefix + hex.EncodeToString(hash[:])[:16]
+}
 
-	// Read the directory tree
-	files, err := getDepsFiles(rootDir, enabled)
+// ReadPluginDeps reads the plugin dependencies from the specified directory,
+// renders them with the data and the parameters of each dependency
+// and returns them ordered by metadata order, then by the order they are referenced in.
+// A dependency is either the <ref>.yaml (or .yml) file or the <ref> directory,
+// referencing a dependency which does not exist is an error.
+// Dependencies which do not apply to the platform are skipped.
+func ReadPluginDeps(rootDir string, data PluginDepsTemplateData, deps []PluginDependency) ([]PluginDep, error) {
 
+	deps, err := uniqueDependencies(deps)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, file := range files {
-		if !utils.IsYamlFile(file) {
+	result := make([]PluginDep, 0, len(deps))
+	for _, dep := range deps {
+		files, metadata, err := getDepFiles(rootDir, dep.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if !depAppliesTo(metadata, data.Platform) {
 			continue
 		}
 
-		// Read file content
-		content, err := os.ReadFile(filepath.Clean(file))
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file %s: %w", file, err)
+		depData := data
+		depData.Params = dep.Parameters
+		if depData.Params == nil {
+			depData.Params = map[string]interface{}{}
 		}
 
-		// Perform substitutions
-		modifiedContent := strings.ReplaceAll(string(content), "{{backstage-name}}", bsName)
-		modifiedContent = strings.ReplaceAll(modifiedContent, "{{backstage-ns}}", bsNamespace)
+		pluginDep := PluginDep{Ref: dep.Ref, Metadata: *metadata}
+		for _, file := range files {
+			// Read file content
+			content, err := os.ReadFile(filepath.Clean(file))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file %s: %w", file, err)
+			}
 
-		// Parse the modified content
-		objs, err := utils.ReadYamlContent(modifiedContent)
+			rendered, err := renderPluginDep(file, string(content), depData)
+			if err != nil {
+				return nil, err
+			}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to read YAML file %s: %w", file, err)
+			// Parse the rendered content
+			objs, err := utils.ReadYamlContent(rendered)
+
+			if err != nil {
+				return nil, fmt.Errorf("failed to read YAML file %s: %w", file, err)
+			}
+			pluginDep.Objects = append(pluginDep.Objects, objs...)
 		}
-		objects = append(objects, objs...)
+		result = append(result, pluginDep)
 	}
 
-	return objects, nil
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Metadata.Order < result[j].Metadata.Order
+	})
+
+	return result, nil
 }
 
-func getDepsFiles(root string, enabledPrefixes []string) ([]string, error) {
-	var files []string
+// renderPluginDep renders the plugin dependency file content as a Go template.
+// Template errors are reported as "template: <file>:<line>: ...".
+func renderPluginDep(file, content string, data PluginDepsTemplateData) (string, error) {
+	// legacy placeholders, not valid Go template actions
+	content = strings.ReplaceAll(content, "{{backstage-name}}", data.Backstage.Name)
+	content = strings.ReplaceAll(content, "{{backstage-ns}}", data.Backstage.Namespace)
 
-	// Read the directory contents
-	entries, err := os.ReadDir(root)
+	tmpl, err := template.New(file).Option("missingkey=error").Funcs(pluginDepsTemplateFuncs).Parse(content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory %s: %w", root, err)
+		return "", fmt.Errorf("failed to parse plugin dependency template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render plugin dependency template: %w", err)
+	}
+	return out.String(), nil
+}
+
+var pluginDepsTemplateFuncs = template.FuncMap{
+	// default returns the value, or def if the value is empty: {{ index .Params "ns" | default "my-ns" }}
+	"default": func(def interface{}, value ...interface{}) interface{} {
+		if len(value) == 0 || value[0] == nil || value[0] == "" {
+			return def
+		}
+		return value[0]
+	},
+	// required fails rendering if the value is empty: {{ required "secret param is required" (index .Params "secret") }}
+	"required": func(msg string, value interface{}) (interface{}, error) {
+		if value == nil || value == "" {
+			return nil, errors.New(msg)
+		}
+		return value, nil
+	},
+	// quote returns the value as a double-quoted YAML string
+	"quote": func(value interface{}) string {
+		return strconv.Quote(fmt.Sprint(value))
+	},
+	// has reports whether the list contains the element: {{ if has "orchestrator" .Flavours }}
+	"has": func(element string, list []string) bool {
+		return slices.Contains(list, element)
+	},
+}
+
+// uniqueDependencies removes duplicated references to the same dependency,
+// the same dependency referenced with different parameters is an error as it would produce conflicting objects
+func uniqueDependencies(deps []PluginDependency) ([]PluginDependency, error) {
+	result := make([]PluginDependency, 0, len(deps))
+	seen := make(map[string]PluginDependency)
+	for _, dep := range deps {
+		if dep.Ref == "" {
+			continue
+		}
+		if prev, ok := seen[dep.Ref]; ok {
+			if !reflect.DeepEqual(normalizeConfigValue(prev.Parameters), normalizeConfigValue(dep.Parameters)) {
+				return nil, fmt.Errorf("plugin dependency %s is referenced with different parameters", dep.Ref)
+			}
+			continue
+		}
+		seen[dep.Ref] = dep
+		result = append(result, dep)
+	}
+	return result, nil
+}
+
+// getDepFiles returns the manifest files of the dependency and its metadata.
+// For a <ref> directory these are the YAML files of the directory tree ordered by path,
+// hidden files and directories (like ..data of mounted ConfigMaps) are ignored.
+func getDepFiles(root, ref string) ([]string, *PluginDepMetadata, error) {
+	metadata := &PluginDepMetadata{}
+
+	if ref != filepath.Base(ref) || strings.HasPrefix(ref, ".") {
+		return nil, nil, fmt.Errorf("invalid plugin dependency reference %q, it must be a file or directory name", ref)
 	}
 
-	// Iterate over the entries and filter by prefixes
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue // Skip directories
+	depDir := filepath.Join(root, ref)
+	if utils.DirectoryExists(depDir) {
+		var files []string
+		err := filepath.WalkDir(depDir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != depDir && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || !utils.IsYamlFile(path) {
+				return nil
+			}
+			if filepath.Dir(path) == depDir && d.Name() == pluginDepMetadataFile {
+				return nil
+			}
+			files = append(files, path)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read plugin dependency directory %s: %w", depDir, err)
 		}
 
-		// Check if the file name starts with any of the enabled prefixes
-		for _, prefix := range enabledPrefixes {
-			if strings.HasPrefix(entry.Name(), prefix) {
-				files = append(files, filepath.Join(root, entry.Name()))
-				break
+		content, err := os.ReadFile(filepath.Join(depDir, pluginDepMetadataFile))
+		if err == nil {
+			if err = yaml.Unmarshal(content, metadata); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse %s of plugin dependency %s: %w", pluginDepMetadataFile, ref, err)
 			}
+		} else if !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("failed to read %s of plugin dependency %s: %w", pluginDepMetadataFile, ref, err)
 		}
+		return files, metadata, nil
+	}
 
+	for _, ext := range []string{".yaml", ".yml"} {
+		file := filepath.Join(root, ref+ext)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return []string{file}, metadata, nil
+		}
 	}
 
-	return files, nil
+	return nil, nil, fmt.Errorf("plugin dependency %s not found: neither %s directory nor %s.yaml file exists", ref, depDir, depDir)
+}
+
+// depAppliesTo checks whether the dependency applies to the platform
+func depAppliesTo(metadata *PluginDepMetadata, p PluginDepsPlatform) bool {
+	if len(metadata.Platforms) == 0 {
+		return true
+	}
+	for _, name := range metadata.Platforms {
+		if strings.EqualFold(name, p.Name) || (strings.EqualFold(name, platform.Kubernetes.Name) && !p.IsOpenShift) {
+			return true
+		}
+	}
+	return false
 }
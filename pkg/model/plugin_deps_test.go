@@ -3,15 +3,18 @@ package model
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 
 	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -34,13 +37,15 @@ func TestReadPluginDeps(t *testing.T) {
 	err = os.WriteFile(file4, []byte("some unrelated content"), 0644)
 	assert.NoError(t, err)
 
-	objects, err := ReadPluginDeps(dir, "", "", []string{"sonata"})
+	deps, err := ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{{Ref: "sonata"}})
 	assert.NoError(t, err)
-	assert.Len(t, objects, 2)
+	assert.Len(t, deps, 1)
+	assert.Equal(t, "sonata", deps[0].Ref)
 
-	// Verify the names of the objects
+	// only the exact-named file, not sonata-config.yaml
+	objects := depObjects(deps)
+	assert.Len(t, objects, 1)
 	assert.Equal(t, "sonata", objects[0].GetName())
-	assert.Equal(t, "sonata", objects[1].GetName())
 }
 
 func TestReadPluginDepsSubstitutions(t *testing.T) {
@@ -60,8 +65,9 @@ metadata:
 
 	bsName := "test-name"
 	bsNamespace := "test-namespace"
-	objects, err := ReadPluginDeps(dir, bsName, bsNamespace, []string{"file1"})
+	deps, err := ReadPluginDeps(dir, PluginDepsTemplateData{Backstage: PluginDepsBackstage{Name: bsName, Namespace: bsNamespace}}, []PluginDependency{{Ref: "file1"}})
 	assert.NoError(t, err)
+	objects := depObjects(deps)
 	assert.Len(t, objects, 1)
 
 	assert.Equal(t, bsName, objects[0].GetName())
@@ -126,8 +132,10 @@ plugins:
 	}
 	sc := runtime.NewScheme()
 	utilruntime.Must(api.AddToScheme(sc))
-	objects, err := GetPluginDeps(bs, dynaPlugins, sc)
+	deps, err := GetPluginDeps(bs, nil, dynaPlugins, platform.Kubernetes, sc)
 	assert.NoError(t, err)
+	assert.Len(t, deps, 2)
+	objects := depObjects(deps)
 	assert.Len(t, objects, 2)
 
 	// Verify the returned objects
@@ -147,8 +155,254 @@ plugins:
 func TestReadPluginDepsNoFiles(t *testing.T) {
 	dir := t.TempDir()
 
-	// Call ReadPluginDeps with an empty directory
-	objects, err := ReadPluginDeps(dir, "", "", []string{"sonata"})
+	// no dependencies referenced
+	deps, err := ReadPluginDeps(filepath.Join(dir, "not-existing"), PluginDepsTemplateData{}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, deps, 0)
+
+	// referenced dependency does not exist
+	_, err = ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{{Ref: "sonata"}})
+	assert.ErrorContains(t, err, "plugin dependency sonata not found")
+
+	_, err = ReadPluginDeps(filepath.Join(dir, "not-existing"), PluginDepsTemplateData{}, []PluginDependency{{Ref: "sonata"}})
+	assert.ErrorContains(t, err, "plugin dependency sonata not found")
+
+	// references are names, not paths
+	_, err = ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{{Ref: "../sonata"}})
+	assert.ErrorContains(t, err, "invalid plugin dependency reference")
+}
+
+func TestReadPluginDepsTemplate(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "dep.yaml"), []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Backstage.Name }}-dep
+  namespace: {{ index .Params "namespace" | default .Backstage.Namespace }}
+data:
+  platform: {{ .Platform.Name }}
+{{- if .Platform.IsOpenShift }}
+  route: "true"
+{{- end }}
+{{- if has "orchestrator" .Flavours }}
+  orchestrator: "true"
+{{- end }}
+  replicas: {{ .Params.replicas | quote }}
+`), 0644)
+	assert.NoError(t, err)
+
+	data := PluginDepsTemplateData{
+		Backstage: PluginDepsBackstage{Name: "bs", Namespace: "bs-ns"},
+		Platform:  PluginDepsPlatform{Name: platform.OpenShift.Name, IsOpenShift: true},
+		Flavours:  []string{"orchestrator"},
+	}
+	deps, err := ReadPluginDeps(dir, data, []PluginDependency{
+		{Ref: "dep", Parameters: map[string]interface{}{"replicas": 3}},
+	})
+	assert.NoError(t, err)
+	objects := depObjects(deps)
+	assert.Len(t, objects, 1)
+	assert.Equal(t, "bs-dep", objects[0].GetName())
+	assert.Equal(t, "bs-ns", objects[0].GetNamespace())
+	cmData, _, _ := unstructured.NestedStringMap(objects[0].Object, "data")
+	assert.Equal(t, map[string]string{
+		"platform":     platform.OpenShift.Name,
+		"route":        "true",
+		"orchestrator": "true",
+		"replicas":     "3",
+	}, cmData)
+
+	// parameter overrides the default
+	deps, err = ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{
+		{Ref: "dep", Parameters: map[string]interface{}{"namespace": "other-ns", "replicas": 1}},
+	})
+	assert.NoError(t, err)
+	objects = depObjects(deps)
+	assert.Equal(t, "other-ns", objects[0].GetNamespace())
+	_, ok, _ := unstructured.NestedString(objects[0].Object, "data", "route")
+	assert.False(t, ok)
+}
+
+func TestReadPluginDepsTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "missing.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Params.name }}
+`), 0644)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "required.yaml"), []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: {{ required "parameter 'name' is required" (index .Params "name") }}
+`), 0644)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "syntax.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Backstage.Name
+`), 0644)
+	assert.NoError(t, err)
+
+	// missing keys are errors, reported with the file and line
+	_, err = ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{{Ref: "missing"}})
+	assert.ErrorContains(t, err, filepath.Join(dir, "missing.yaml")+":4:")
+	assert.ErrorContains(t, err, "map has no entry for key \"name\"")
+
+	_, err = ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{{Ref: "required"}})
+	assert.ErrorContains(t, err, filepath.Join(dir, "required.yaml")+":4:")
+	assert.ErrorContains(t, err, "parameter 'name' is required")
+
+	_, err = ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{{Ref: "syntax"}})
+	assert.ErrorContains(t, err, "failed to parse plugin dependency template")
+	assert.ErrorContains(t, err, filepath.Join(dir, "syntax.yaml")+":4")
+}
+
+func TestReadPluginDepsDuplicatedRefs(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "dep.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ index .Params "name" | default "dep" }}
+`), 0644)
+	assert.NoError(t, err)
+
+	// the same dependency referenced by several plugins is rendered once
+	deps, err := ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{
+		{Ref: "dep"}, {Ref: "dep"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, deps, 1)
+
+	// with conflicting parameters it is an error
+	_, err = ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{
+		{Ref: "dep", Parameters: map[string]interface{}{"name": "a"}},
+		{Ref: "dep", Parameters: map[string]interface{}{"name": "b"}},
+	})
+	assert.ErrorContains(t, err, "plugin dependency dep is referenced with different parameters")
+}
+
+func TestReadPluginDepsDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(path, content string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	configMap := func(name string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name
+	}
+
+	writeFile("first/metadata.yaml", `
+order: 10
+requiredCRDs:
+  - sonataflowplatforms.sonataflow.org
+`)
+	writeFile("first/b.yaml", configMap("first-b"))
+	writeFile("first/a.yaml", configMap("first-a"))
+	writeFile("first/nested/c.yml", configMap("first-c"))
+	writeFile("first/README.md", "not a manifest")
+	// mounted ConfigMap internals
+	writeFile("first/..data/a.yaml", configMap("hidden"))
+	writeFile("second/metadata.yaml", "order: -1")
+	writeFile("second/dep.yaml", configMap("second"))
+	writeFile("openshift-only/metadata.yaml", "platforms: [OpenShift]")
+	writeFile("openshift-only/dep.yaml", configMap("openshift-only"))
+	writeFile("k8s-only/metadata.yaml", "platforms: [Kubernetes]")
+	writeFile("k8s-only/dep.yaml", configMap("k8s-only"))
+	writeFile("file.yml", configMap("file"))
+
+	refs := []PluginDependency{{Ref: "first"}, {Ref: "file"}, {Ref: "second"}, {Ref: "openshift-only"}, {Ref: "k8s-only"}}
+
+	deps, err := ReadPluginDeps(dir, PluginDepsTemplateData{Platform: PluginDepsPlatform{Name: platform.EKS.Name}}, refs)
+	assert.NoError(t, err)
+
+	// ordered by metadata order, then by reference order; openshift-only skipped
+	var refNames []string
+	for _, d := range deps {
+		refNames = append(refNames, d.Ref)
+	}
+	assert.Equal(t, []string{"second", "file", "k8s-only", "first"}, refNames)
+
+	first := deps[3]
+	assert.Equal(t, []string{"sonataflowplatforms.sonataflow.org"}, first.Metadata.RequiredCRDs)
+	var names []string
+	for _, obj := range first.Objects {
+		names = append(names, obj.GetName())
+	}
+	assert.Equal(t, []string{"first-a", "first-b", "first-c"}, names)
+
+	deps, err = ReadPluginDeps(dir, PluginDepsTemplateData{Platform: PluginDepsPlatform{Name: platform.OpenShift.Name, IsOpenShift: true}}, refs)
+	assert.NoError(t, err)
+	refNames = nil
+	for _, d := range deps {
+		refNames = append(refNames, d.Ref)
+	}
+	assert.Equal(t, []string{"second", "file", "openshift-only", "first"}, refNames)
+
+	writeFile("broken/metadata.yaml", "order: [")
+	_, err = ReadPluginDeps(dir, PluginDepsTemplateData{}, []PluginDependency{{Ref: "broken"}})
+	assert.ErrorContains(t, err, "failed to parse metadata.yaml of plugin dependency broken")
+}
+
+func depObjects(deps []PluginDep) []*unstructured.Unstructured {
+	var objects []*unstructured.Unstructured
+	for _, d := range deps {
+		objects = append(objects, d.Objects...)
+	}
+	return objects
+}
+
+func TestGetPluginDepsShared(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("LOCALBIN", tempDir)
+
+	depDir := filepath.Join(tempDir, "plugin-deps", "tekton")
+	assert.NoError(t, os.MkdirAll(depDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(depDir, "metadata.yaml"), []byte("shared: true"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(depDir, "task.yaml"), []byte(`
+apiVersion: tekton.dev/v1
+kind: Task
+metadata:
+  name: task
+`), 0644))
+
+	dynaPlugins := DynamicPlugins{
+		ConfigMap: &corev1.ConfigMap{
+			Data: map[string]string{
+				"dynamic-plugins.yaml": `
+plugins:
+  - package: './dynamic-plugins/dist/test'
+    disabled: false
+    dependencies:
+      - ref: tekton
+`,
+			},
+		},
+	}
+
+	sc := runtime.NewScheme()
+	utilruntime.Must(api.AddToScheme(sc))
+
+	bs1 := api.Backstage{ObjectMeta: metav1.ObjectMeta{Name: "bs1", Namespace: "ns"}}
+	bs2 := api.Backstage{ObjectMeta: metav1.ObjectMeta{Name: "bs2", Namespace: "ns"}}
+	assert.NotEqual(t, PluginDepOwnerLabel(bs1), PluginDepOwnerLabel(bs2))
+	assert.LessOrEqual(t, len(strings.TrimPrefix(PluginDepOwnerLabel(bs1), "rhdh.redhat.com/")), 63)
+
+	deps, err := GetPluginDeps(bs1, nil, dynaPlugins, platform.Kubernetes, sc)
 	assert.NoError(t, err)
-	assert.Len(t, objects, 0)
+	assert.Len(t, deps, 1)
+	assert.True(t, deps[0].Metadata.Shared)
+
+	task := deps[0].Objects[0]
+	assert.Empty(t, task.GetOwnerReferences())
+	assert.Equal(t, "ns", task.GetNamespace())
+	assert.Equal(t, map[string]string{
+		SharedPluginDepLabel:     "tekton",
+		PluginDepOwnerLabel(bs1): "true",
+	}, task.GetLabels())
 }
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/utils"
+)
+
+// PluginInfraIndexFile is the file in the plugin-infra directory describing the infrastructure components
+const PluginInfraIndexFile = "components.yaml"
+
+// PluginInfraLabel marks objects applied for the plugin infrastructure, its value is the component name
+const PluginInfraLabel = "rhdh.redhat.com/plugin-infra"
+
+// PluginInfraComponent is an infrastructure component (e.g. an Operator installed with an OLM Subscription)
+// plugins require to be installed in the cluster. Components are shared by all Backstage instances
+// and are never deleted by the Operator.
+type PluginInfraComponent struct {
+	// Name the component is required with, in spec.requiredInfrastructure or flavour metadata
+	Name string `yaml:"name"`
+	// Manifest files of the component, relative to the plugin-infra directory, applied in order
+	Files []string `yaml:"files"`
+	// Requires lists the components which have to be ready before the component is applied
+	Requires []string `yaml:"requires,omitempty"`
+	// RequiredCRDs lists names of the CRDs which have to be established before the component is applied
+	RequiredCRDs []string `yaml:"requiredCRDs,omitempty"`
+	// Platforms the component applies to (e.g. OpenShift, Kubernetes), all if empty.
+	// Components which do not apply to the platform are considered ready.
+	Platforms []string `yaml:"platforms,omitempty"`
+
+	Objects []*unstructured.Unstructured `yaml:"-"`
+}
+
+type pluginInfraIndex struct {
+	Components []PluginInfraComponent `yaml:"components"`
+}
+
+// PluginInfraDir returns the directory the plugin infrastructure is read from
+func PluginInfraDir() string {
+	dir, ok := os.LookupEnv("PLUGIN_INFRA_DIR_backstage")
+	if !ok {
+		dir = filepath.Join(os.Getenv("LOCALBIN"), "plugin-infra")
+	}
+	return dir
+}
+
+// RequiredInfrastructure returns the names of the infrastructure components required by the Backstage instance,
+// directly with spec.requiredInfrastructure or by its enabled flavours, sorted
+func RequiredInfrastructure(spec api.BackstageSpec, userFlavours UserFlavours) ([]string, error) {
+	required := map[string]bool{}
+	for _, name := range spec.RequiredInfrastructure {
+		required[name] = true
+	}
+
+	flavours, err := GetEnabledFlavours(spec, userFlavours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine enabled flavours: %w", err)
+	}
+	for _, f := range flavours {
+		for _, name := range f.requiredInfrastructure {
+			required[name] = true
+		}
+	}
+	return utils.SortedKeys(required), nil
+}
+
+// ReadPluginInfra reads the components with the given names, and the components they require,
+// from the plugin-infra directory and returns the ones which apply to the platform,
+// ordered the way they have to be applied (required components first).
+// Unknown components and cyclic requirements are errors.
+func ReadPluginInfra(dir string, names []string, p PluginDepsPlatform) ([]PluginInfraComponent, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, PluginInfraIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin infrastructure index %s: %w", PluginInfraIndexFile, err)
+	}
+	var index pluginInfraIndex
+	if err := yaml.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin infrastructure index %s: %w", PluginInfraIndexFile, err)
+	}
+	components := make(map[string]PluginInfraComponent, len(index.Components))
+	for _, c := range index.Components {
+		if _, ok := components[c.Name]; ok {
+			return nil, fmt.Errorf("plugin infrastructure component %s is defined more than once", c.Name)
+		}
+		components[c.Name] = c
+	}
+
+	var result []PluginInfraComponent
+	// visiting components are on the current requirement path, visited ones are resolved
+	visiting, visited := map[string]bool{}, map[string]bool{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		if visited[name] {
+			return nil
+		}
+		path = append(path, name)
+		if visiting[name] {
+			return fmt.Errorf("cyclic plugin infrastructure requirement: %s", strings.Join(path, " -> "))
+		}
+		c, ok := components[name]
+		if !ok {
+			return fmt.Errorf("plugin infrastructure component %s is not defined in %s", name, PluginInfraIndexFile)
+		}
+		visiting[name] = true
+		requires := append([]string{}, c.Requires...)
+		sort.Strings(requires)
+		for _, r := range requires {
+			if err := visit(r, path); err != nil {
+				return err
+			}
+		}
+		visiting[name], visited[name] = false, true
+
+		if !depAppliesTo(&PluginDepMetadata{Platforms: c.Platforms}, p) {
+			return nil
+		}
+		for _, file := range c.Files {
+			if file != filepath.Base(file) {
+				return fmt.Errorf("invalid file %q of plugin infrastructure component %s, it must be a file name", file, name)
+			}
+			objs, err := utils.ReadYamlFile(filepath.Join(dir, file))
+			if err != nil {
+				return fmt.Errorf("failed to read file %s of plugin infrastructure component %s: %w", file, name, err)
+			}
+			for _, obj := range objs {
+				labels := obj.GetLabels()
+				if labels == nil {
+					labels = map[string]string{}
+				}
+				labels[PluginInfraLabel] = name
+				obj.SetLabels(labels)
+			}
+			c.Objects = append(c.Objects, objs...)
+		}
+		result = append(result, c)
+		return nil
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/redhat-developer/rhdh-operator/api"
+	"github.com/redhat-developer/rhdh-operator/pkg/platform"
+)
+
+func writePluginInfra(t *testing.T, dir, index string) {
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, PluginInfraIndexFile), []byte(index), 0644))
+	for _, name := range []string{"a", "b", "c", "k8s"} {
+		content := "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: " + name
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(content), 0644))
+	}
+}
+
+func componentNames(components []PluginInfraComponent) []string {
+	var names []string
+	for _, c := range components {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestReadPluginInfra(t *testing.T) {
+	dir := t.TempDir()
+	writePluginInfra(t, dir, `
+components:
+  - name: c
+    requires: [b, k8s]
+    files: [c.yaml]
+  - name: b
+    requires: [a]
+    files: [b.yaml]
+    requiredCRDs: [as.example.com]
+    platforms: [OpenShift]
+  - name: a
+    files: [a.yaml]
+    platforms: [OpenShift]
+  - name: k8s
+    files: [k8s.yaml]
+    platforms: [Kubernetes]
+`)
+
+	openshift := PluginDepsPlatform{Name: platform.OpenShift.Name, IsOpenShift: true}
+	components, err := ReadPluginInfra(dir, []string{"c"}, openshift)
+	assert.NoError(t, err)
+	// required components first, the ones which do not apply to the platform skipped
+	assert.Equal(t, []string{"a", "b", "c"}, componentNames(components))
+	assert.Equal(t, []string{"as.example.com"}, components[1].RequiredCRDs)
+	assert.Len(t, components[0].Objects, 1)
+	assert.Equal(t, "a", components[0].Objects[0].GetLabels()[PluginInfraLabel])
+
+	components, err = ReadPluginInfra(dir, []string{"c", "a"}, PluginDepsPlatform{Name: platform.EKS.Name})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"k8s", "c"}, componentNames(components))
+
+	components, err = ReadPluginInfra(dir, nil, openshift)
+	assert.NoError(t, err)
+	assert.Empty(t, components)
+
+	_, err = ReadPluginInfra(dir, []string{"unknown"}, openshift)
+	assert.EqualError(t, err, "plugin infrastructure component unknown is not defined in components.yaml")
+}
+
+func TestReadPluginInfraErrors(t *testing.T) {
+	dir := t.TempDir()
+	p := PluginDepsPlatform{Name: platform.OpenShift.Name, IsOpenShift: true}
+
+	_, err := ReadPluginInfra(dir, []string{"a"}, p)
+	assert.ErrorContains(t, err, "failed to read plugin infrastructure index components.yaml")
+
+	writePluginInfra(t, dir, `
+components:
+  - name: a
+    requires: [b]
+    files: [a.yaml]
+  - name: b
+    requires: [a]
+    files: [b.yaml]
+`)
+	_, err = ReadPluginInfra(dir, []string{"a"}, p)
+	assert.EqualError(t, err, "cyclic plugin infrastructure requirement: a -> b -> a")
+
+	writePluginInfra(t, dir, `
+components:
+  - name: a
+    files: [../a.yaml]
+`)
+	_, err = ReadPluginInfra(dir, []string{"a"}, p)
+	assert.ErrorContains(t, err, "invalid file \"../a.yaml\" of plugin infrastructure component a")
+}
+
+func TestRequiredInfrastructure(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOCALBIN", dir)
+	flavourDir := filepath.Join(dir, "default-config", "flavours", "orchestrator")
+	assert.NoError(t, os.MkdirAll(flavourDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(flavourDir, "metadata.yaml"), []byte(`
+enabledByDefault: true
+requiredInfrastructure: [serverless-logic, knative]
+`), 0644))
+
+	names, err := RequiredInfrastructure(api.BackstageSpec{RequiredInfrastructure: []string{"pipelines", "knative"}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"knative", "pipelines", "serverless-logic"}, names)
+
+	names, err = RequiredInfrastructure(api.BackstageSpec{Flavours: &[]api.Flavour{{Name: "orchestrator", Enabled: false}}}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+}
@@ -46,6 +46,8 @@ const (
 	ConfigMapEnvsKey  = "configmap-envs.yaml"
 	ConfigMapFilesKey = "configmap-files.yaml"
 	PvcsKey           = "pvcs.yaml"
+
+	DynamicPluginsCacheKey = "dynamic-plugins-cache.yaml"
 )
 
 // Backstage configuration scaffolding with empty BackstageObjects.
@@ -60,6 +62,18 @@ type BackstageModel struct {
 	RuntimeObjects []RuntimeObject
 
 	ExternalConfig ExternalConfig
+
+	// flavours enabled for the instance, in merge order
+	flavours []enabledFlavour
+}
+
+// FlavoursStatus returns the status of the flavours enabled for the instance
+func (m *BackstageModel) FlavoursStatus() []api.FlavourStatus {
+	var result []api.FlavourStatus
+	for _, f := range m.flavours {
+		result = append(result, f.status())
+	}
+	return result
 }
 
 // setRuntimeObject adds an object to the model.
@@ -140,10 +154,11 @@ func InitObjects(ctx context.Context, backstage api.Backstage, externalConfig Ex
 	}
 
 	// Get enabled flavours once for all configs
-	flavours, err := GetEnabledFlavours(backstage.Spec)
+	flavours, err := GetEnabledFlavours(backstage.Spec, externalConfig.UserFlavours)
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine enabled flavours: %w", err)
 	}
+	model.flavours = flavours
 	if len(flavours) > 0 {
 		for _, flavour := range flavours {
 			lg.Info("found enabled flavour", "flavour:", flavour.name)
@@ -206,6 +221,13 @@ func InitObjects(ctx context.Context, backstage api.Backstage, externalConfig Ex
 		}
 	}
 
+	// Phase 3: objects derived from the final configuration of the others
+	if appConfig, ok := model.GetRuntimeObject(AppConfigKey).(*AppConfig); ok {
+		if err := appConfig.addEffectiveAppConfig(backstage, scheme); err != nil {
+			return nil, fmt.Errorf("failed to compute effective app-config, reason: %w", err)
+		}
+	}
+
 	return model, nil
 }
 
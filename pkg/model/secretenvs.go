@@ -78,7 +78,7 @@ func (p *SecretEnvs) updateAndValidate(backstage api.Backstage, scheme *runtime.
 	// Process secrets from CR spec (formerly addExternalConfig)
 	if backstage.Spec.Application != nil && backstage.Spec.Application.ExtraEnvs != nil && backstage.Spec.Application.ExtraEnvs.Secrets != nil {
 		for _, specSec := range backstage.Spec.Application.ExtraEnvs.Secrets {
-			err := deployment.addEnvVarsFrom(containersFilter{names: specSec.Containers}, SecretObjectKind, specSec.Name, specSec.Key)
+			err := deployment.addEnvVarsFromRef(containersFilter{names: specSec.Containers}, SecretObjectKind, specSec)
 			if err != nil {
 				return fmt.Errorf("failed to add env vars on secret %s: %w", specSec.Name, err)
 			}
@@ -101,6 +101,81 @@ func TestSpecifiedSecretEnvs(t *testing.T) {
 
 }
 
+func TestSecretEnvsPrefixAndRename(t *testing.T) {
+
+	bs := *secretEnvsTestBackstage.DeepCopy()
+	bs.Spec.Application = &api.Application{
+		ExtraEnvs: &api.ExtraEnvs{
+			Secrets: []api.EnvObjectRef{
+				{Name: "github", Prefix: "GITHUB_"},
+				{Name: "gitlab", Prefix: "GITLAB_", Items: []api.EnvObjectItem{{Key: "CLIENT_ID"}, {Key: "secret", As: "CLIENT_SECRET"}}},
+				{Name: "token", Prefix: "MY_", Key: "TOKEN"},
+			},
+		},
+	}
+
+	testObj := createBackstageTest(bs).withDefaultConfig(true)
+
+	model, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	bscontainer := model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment).container()
+
+	// the whole object with envFrom
+	assert.Equal(t, 1, len(bscontainer.EnvFrom))
+	assert.Equal(t, "GITHUB_", bscontainer.EnvFrom[0].Prefix)
+	assert.Equal(t, "github", bscontainer.EnvFrom[0].SecretRef.Name)
+
+	// the items and key as individual variables
+	assert.Equal(t, 3, len(bscontainer.Env))
+	assert.Equal(t, "GITLAB_CLIENT_ID", bscontainer.Env[0].Name)
+	assert.Equal(t, "CLIENT_ID", bscontainer.Env[0].ValueFrom.SecretKeyRef.Key)
+	assert.Equal(t, "GITLAB_CLIENT_SECRET", bscontainer.Env[1].Name)
+	assert.Equal(t, "secret", bscontainer.Env[1].ValueFrom.SecretKeyRef.Key)
+	assert.Equal(t, "gitlab", bscontainer.Env[1].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, "MY_TOKEN", bscontainer.Env[2].Name)
+	assert.Equal(t, "TOKEN", bscontainer.Env[2].ValueFrom.SecretKeyRef.Key)
+}
+
+func TestEnvCollisions(t *testing.T) {
+
+	bs := *secretEnvsTestBackstage.DeepCopy()
+	bs.Spec.Application = &api.Application{
+		ExtraEnvs: &api.ExtraEnvs{
+			ConfigMaps: []api.EnvObjectRef{{Name: "github"}},
+			Secrets: []api.EnvObjectRef{
+				{Name: "gitlab"},
+				{Name: "token", Items: []api.EnvObjectItem{{Key: "TOKEN", As: "CLIENT_ID"}}},
+			},
+			Envs: []api.Env{{Name: "URL", Value: "https://my.url"}},
+		},
+	}
+
+	testObj := createBackstageTest(bs).withDefaultConfig(true)
+	testObj.externalConfig.ExtraEnvConfigMapKeys = map[string]DataObjectKeys{
+		"github": NewDataObjectKeys(map[string]string{"CLIENT_ID": "", "URL": ""}, nil),
+	}
+	testObj.externalConfig.ExtraEnvSecretKeys = map[string]DataObjectKeys{
+		"gitlab": NewDataObjectKeys(nil, map[string][]byte{"CLIENT_ID": nil, "CLIENT_SECRET": nil}),
+		"token":  NewDataObjectKeys(nil, map[string][]byte{"TOKEN": nil}),
+	}
+
+	model, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+
+	// the variable with a value overrides the others deliberately
+	assert.Equal(t, []string{
+		"container backstage-backend: CLIENT_ID is set from ConfigMap github and Secret gitlab and Secret token",
+	}, model.EnvCollisions())
+
+	// prefixed, they do not collide
+	bs.Spec.Application.ExtraEnvs.ConfigMaps[0].Prefix = "GITHUB_"
+	bs.Spec.Application.ExtraEnvs.Secrets[0].Prefix = "GITLAB_"
+	model, err = InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
+	assert.Empty(t, model.EnvCollisions())
+}
+
 func TestSpecifiedSecretEnvsWithContainers(t *testing.T) {
 
 	bs := *secretEnvsTestBackstage.DeepCopy()
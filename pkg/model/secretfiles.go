@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/redhat-developer/rhdh-operator/pkg/model/multiobject"
 	"github.com/redhat-developer/rhdh-operator/pkg/utils"
@@ -85,16 +86,18 @@ func (p *SecretFiles) updateAndValidate(backstage api.Backstage, scheme *runtime
 	if backstage.Spec.Application != nil && backstage.Spec.Application.ExtraFiles != nil && backstage.Spec.Application.ExtraFiles.Secrets != nil {
 		for _, specSec := range backstage.Spec.Application.ExtraFiles.Secrets {
 
-			if specSec.MountPath == "" && specSec.Key == "" {
-				return fmt.Errorf("key or mountPath has to be specified for secret %s", specSec.Name)
+			mp, wSubpath := deployment.fileObjectMountPath(backstage.Spec, specSec, backstage.Spec.Application.ExtraFiles.MountPath)
+			if wSubpath && specSec.Key == "" {
+				// the whole Secret is mounted as a directory, so the Operator does not need to list its keys
+				mp, wSubpath = filepath.Join(mp, specSec.Name), false
 			}
-			mp, wSubpath := deployment.mountPath(specSec.MountPath, specSec.Key, backstage.Spec.Application.ExtraFiles.MountPath)
 			keys := p.model.ExternalConfig.ExtraFileSecretKeys[specSec.Name].All()
 			err := deployment.mountFilesFrom(containersFilter{names: specSec.Containers}, SecretObjectKind,
 				specSec.Name, mp, specSec.Key, wSubpath, keys)
 			if err != nil {
 				return fmt.Errorf("failed to mount files on secret %s: %w", specSec.Name, err)
 			}
+			deployment.setFileObjectVolume(specSec)
 		}
 	}
 
@@ -13,6 +13,7 @@ import (
 
 	"github.com/redhat-developer/rhdh-operator/api"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/stretchr/testify/assert"
@@ -133,15 +134,33 @@ func TestSpecifiedSecretFiles(t *testing.T) {
 
 }
 
-func TestFailedValidation(t *testing.T) {
+func TestWholeSecretFiles(t *testing.T) {
 	bs := *secretFilesTestBackstage.DeepCopy()
 	sf := &bs.Spec.Application.ExtraFiles.Secrets
 	*sf = append(*sf, api.FileObjectRef{Name: "secret1"})
+	*sf = append(*sf, api.FileObjectRef{Name: "secret2", DefaultMode: ptr.To(int32(0400)),
+		Items: []api.FileObjectItem{{Key: "tls.crt", Path: "certs/server.crt"}, {Key: "tls.key", Mode: ptr.To(int32(0600))}}})
 
 	testObj := createBackstageTest(bs).withDefaultConfig(true)
-	_, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
-	assert.ErrorContains(t, err, "key or mountPath has to be specified for secret secret1")
+	model, err := InitObjects(context.TODO(), bs, testObj.externalConfig, platform.Default, testObj.scheme)
+	assert.NoError(t, err)
 
+	deployment := model.GetRuntimeObject(DeploymentKey).(*BackstageDeployment)
+
+	// mounted as directories, without listing the keys
+	assert.Equal(t, 2, len(deployment.container().VolumeMounts))
+	assert.Equal(t, "/my/path/secret1", deployment.container().VolumeMounts[0].MountPath)
+	assert.Empty(t, deployment.container().VolumeMounts[0].SubPath)
+	assert.Equal(t, "/my/path/secret2", deployment.container().VolumeMounts[1].MountPath)
+	assert.Empty(t, deployment.container().VolumeMounts[1].SubPath)
+
+	assert.Empty(t, deployment.podSpec().Volumes[0].Secret.Items)
+	assert.Equal(t, ptr.To(int32(420)), deployment.podSpec().Volumes[0].Secret.DefaultMode)
+	assert.Equal(t, []corev1.KeyToPath{
+		{Key: "tls.crt", Path: "certs/server.crt"},
+		{Key: "tls.key", Path: "tls.key", Mode: ptr.To(int32(0600))},
+	}, deployment.podSpec().Volumes[1].Secret.Items)
+	assert.Equal(t, ptr.To(int32(0400)), deployment.podSpec().Volumes[1].Secret.DefaultMode)
 }
 
 func TestDefaultAndSpecifiedSecretFiles(t *testing.T) {
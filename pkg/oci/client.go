@@ -0,0 +1,348 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	mediaTypeOCIIndex         = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest      = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList       = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest   = "application/vnd.docker.distribution.manifest.v2+json"
+	maxManifestSize           = 4 << 20
+	maxLayerSize              = 256 << 20
+	maxFileSize               = 16 << 20
+	headerDockerContentDigest = "Docker-Content-Digest"
+)
+
+// ErrNotFound is returned if the file is not found in the image
+var ErrNotFound = errors.New("not found")
+
+// Client is a minimal OCI distribution API client, reading manifests and files of images
+type Client struct {
+	httpClient  *http.Client
+	credentials map[string]credential
+	// bearer tokens per registry host and repository
+	tokens map[string]string
+}
+
+type credential struct {
+	username string
+	password string
+}
+
+// NewClient creates a client authenticating with the credentials of the docker config JSON
+// (the .dockerconfigjson key of kubernetes.io/dockerconfigjson Secrets), anonymous if empty
+func NewClient(httpClient *http.Client, dockerConfigJSON []byte) (*Client, error) {
+	c := &Client{httpClient: httpClient, credentials: map[string]credential{}, tokens: map[string]string{}}
+	if len(dockerConfigJSON) == 0 {
+		return c, nil
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(dockerConfigJSON, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config: %w", err)
+	}
+	for server, auth := range config.Auths {
+		cred := credential{username: auth.Username, password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode docker config auth of %s: %w", server, err)
+			}
+			user, pass, _ := strings.Cut(string(decoded), ":")
+			cred = credential{username: user, password: pass}
+		}
+		c.credentials[registryHost(server)] = cred
+	}
+	return c, nil
+}
+
+// registryHost normalizes the docker config server key (e.g. https://index.docker.io/v1/) to the registry host
+func registryHost(server string) string {
+	host := server
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host, _, _ = strings.Cut(host, "/")
+	switch host {
+	case "index.docker.io", dockerHub:
+		return dockerHubRegistry
+	}
+	return host
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// Resolve returns the digest of the image manifest (or index) the reference points to
+func (c *Client) Resolve(ctx context.Context, ref Reference) (string, error) {
+	_, digest, err := c.manifest(ctx, ref, ref.manifestRef())
+	return digest, err
+}
+
+// ReadFile reads the file from the image filesystem. Manifest lists are resolved to the linux/amd64 image,
+// or the first one if there is no such image. ErrNotFound is returned if no layer contains the file.
+func (c *Client) ReadFile(ctx context.Context, ref Reference, name string) ([]byte, error) {
+	m, _, err := c.manifest(ctx, ref, ref.manifestRef())
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Manifests) > 0 {
+		selected := m.Manifests[0]
+		for _, d := range m.Manifests {
+			if d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == "amd64" {
+				selected = d
+				break
+			}
+		}
+		if m, _, err = c.manifest(ctx, ref, selected.Digest); err != nil {
+			return nil, err
+		}
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	// the last layer containing the file wins
+	for i := len(m.Layers) - 1; i >= 0; i-- {
+		content, err := c.readLayerFile(ctx, ref, m.Layers[i], name)
+		if err == nil {
+			return content, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("file %s %w in image %s", name, ErrNotFound, ref)
+}
+
+func (c *Client) manifest(ctx context.Context, ref Reference, reference string) (*manifest, string, error) {
+	resp, err := c.get(ctx, ref, "manifests/"+reference,
+		[]string{mediaTypeOCIIndex, mediaTypeDockerList, mediaTypeOCIManifest, mediaTypeDockerManifest})
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read manifest of %s: %w", ref, err)
+	}
+	sum := sha256.Sum256(body)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if strings.HasPrefix(reference, "sha256:") && digest != reference {
+		return nil, "", fmt.Errorf("manifest of %s does not match digest %s", ref, reference)
+	}
+	if header := resp.Header.Get(headerDockerContentDigest); header != "" && header != digest {
+		return nil, "", fmt.Errorf("manifest of %s does not match the %s header %s", ref, headerDockerContentDigest, header)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, "", fmt.Errorf("failed to parse manifest of %s: %w", ref, err)
+	}
+	return &m, digest, nil
+}
+
+// readLayerFile streams the layer, keeping only the content of the file in memory.
+// The rest of the layer is read to verify its digest.
+func (c *Client) readLayerFile(ctx context.Context, ref Reference, layer descriptor, name string) ([]byte, error) {
+	if layer.Size > maxLayerSize {
+		return nil, fmt.Errorf("layer %s of %s exceeds the maximum size", layer.Digest, ref)
+	}
+	resp, err := c.get(ctx, ref, "blobs/"+layer.Digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	hash := sha256.New()
+	blob := bufio.NewReader(io.TeeReader(io.LimitReader(resp.Body, maxLayerSize), hash))
+	var r io.Reader = blob
+	if magic, err := blob.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress layer %s of %s: %w", layer.Digest, ref, err)
+		}
+		r = gz
+	}
+
+	content, err := readTarFile(tar.NewReader(r), name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to read layer %s of %s: %w", layer.Digest, ref, err)
+	}
+	if _, err := io.Copy(io.Discard, blob); err != nil {
+		return nil, fmt.Errorf("failed to read layer %s of %s: %w", layer.Digest, ref, err)
+	}
+	if "sha256:"+hex.EncodeToString(hash.Sum(nil)) != layer.Digest {
+		return nil, fmt.Errorf("layer of %s does not match digest %s", ref, layer.Digest)
+	}
+	return content, err
+}
+
+// readTarFile reads the regular file from the tar stream, ErrNotFound if the stream has no such file
+func readTarFile(tr *tar.Reader, name string) ([]byte, error) {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg || strings.TrimPrefix(path.Clean("/"+hdr.Name), "/") != name {
+			continue
+		}
+		if hdr.Size > maxFileSize {
+			return nil, fmt.Errorf("file %s exceeds the maximum size", name)
+		}
+		return io.ReadAll(tr)
+	}
+}
+
+// get requests the registry API of the repository, authenticating if the registry requires it
+func (c *Client) get(ctx context.Context, ref Reference, apiPath string, accept []string) (*http.Response, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s", ref.host(), ref.Repository, apiPath)
+	tokenKey := ref.host() + "/" + ref.Repository
+
+	do := func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return c.httpClient.Do(req)
+	}
+
+	authorization := ""
+	if token, ok := c.tokens[tokenKey]; ok {
+		authorization = "Bearer " + token
+	}
+	resp, err := do(authorization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", u, err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+		if authorization, err = c.authorize(ctx, ref, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = do(authorization); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", u, err)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("failed to get %s: %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+// authorize answers the registry authentication challenge, fetching a bearer token if required
+func (c *Client) authorize(ctx context.Context, ref Reference, challenge string) (string, error) {
+	cred, hasCred := c.credentials[ref.host()]
+	scheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCred {
+			return "", fmt.Errorf("registry %s requires credentials", ref.Registry)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(cred.username+":"+cred.password)), nil
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return "", fmt.Errorf("registry %s returned an invalid authentication challenge %q", ref.Registry, challenge)
+		}
+		q := realm.Query()
+		if params["service"] != "" {
+			q.Set("service", params["service"])
+		}
+		q.Set("scope", "repository:"+ref.Repository+":pull")
+		realm.RawQuery = q.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if hasCred {
+			req.SetBasicAuth(cred.username, cred.password)
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to get token of registry %s: %w", ref.Registry, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to get token of registry %s: %s", ref.Registry, resp.Status)
+		}
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&token); err != nil {
+			return "", fmt.Errorf("failed to parse token of registry %s: %w", ref.Registry, err)
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		c.tokens[ref.host()+"/"+ref.Repository] = token.Token
+		return "Bearer " + token.Token, nil
+	}
+	return "", fmt.Errorf("registry %s requires unsupported authentication %q", ref.Registry, challenge)
+}
+
+// parseChallenge parses the WWW-Authenticate header, e.g. Bearer realm="https://auth.example.com/token",service="registry"
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return scheme, params
+}
@@ -0,0 +1,197 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		image    string
+		expected Reference
+		str      string
+	}{
+		{"quay.io/rhdh/plugin-catalog-index:1.10", Reference{Registry: "quay.io", Repository: "rhdh/plugin-catalog-index", Tag: "1.10"}, "quay.io/rhdh/plugin-catalog-index:1.10"},
+		{"oci://quay.io/rhdh/index@" + digest, Reference{Registry: "quay.io", Repository: "rhdh/index", Digest: digest}, "quay.io/rhdh/index@" + digest},
+		{"localhost:5000/index:1.0@" + digest, Reference{Registry: "localhost:5000", Repository: "index", Tag: "1.0", Digest: digest}, "localhost:5000/index@" + digest},
+		{"busybox", Reference{Registry: "docker.io", Repository: "library/busybox", Tag: "latest"}, "docker.io/library/busybox:latest"},
+		{"user/image:1", Reference{Registry: "docker.io", Repository: "user/image", Tag: "1"}, "docker.io/user/image:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			ref, err := ParseReference(tt.image)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ref)
+			assert.Equal(t, tt.str, ref.String())
+		})
+	}
+
+	_, err := ParseReference("quay.io/rhdh/index@sha256:abc")
+	assert.ErrorContains(t, err, "unsupported digest")
+	_, err = ParseReference("quay.io/RHDH/index")
+	assert.Error(t, err)
+}
+
+type testRegistry struct {
+	blobs    map[string][]byte
+	tags     map[string]string
+	user     string
+	password string
+}
+
+func (r *testRegistry) add(content []byte) string {
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	r.blobs[digest] = content
+	return digest
+}
+
+func (r *testRegistry) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		user, pass, ok := req.BasicAuth()
+		if r.user != "" && (!ok || user != r.user || pass != r.password) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "repository:rhdh/index:pull", req.URL.Query().Get("scope"))
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "secret-token"})
+	})
+	mux.HandleFunc("/v2/rhdh/index/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://`+req.Host+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		kind, ref, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/v2/rhdh/index/"), "/")
+		if d, ok := r.tags[ref]; ok && kind == "manifests" {
+			ref = d
+		}
+		content, ok := r.blobs[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if kind == "manifests" {
+			w.Header().Set(headerDockerContentDigest, ref)
+		}
+		_, _ = w.Write(content)
+	})
+	return mux
+}
+
+func layer(t *testing.T, files map[string]string, compress bool) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	if !compress {
+		return buf.Bytes()
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write(buf.Bytes())
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	return gz.Bytes()
+}
+
+func TestClientReadFile(t *testing.T) {
+	reg := &testRegistry{blobs: map[string][]byte{}, tags: map[string]string{}, user: "user", password: "pass"}
+	srv := httptest.NewTLSServer(reg.handler(t))
+	defer srv.Close()
+
+	layer1 := layer(t, map[string]string{"./dynamic-plugins.default.yaml": "old", "catalog-entities/a.yaml": "a"}, true)
+	layer2 := layer(t, map[string]string{"dynamic-plugins.default.yaml": "plugins: []"}, false)
+	d1, d2 := reg.add(layer1), reg.add(layer2)
+	imageManifest, _ := json.Marshal(map[string]interface{}{
+		"mediaType": mediaTypeOCIManifest,
+		"layers": []map[string]interface{}{
+			{"digest": d1, "size": len(layer1)},
+			{"digest": d2, "size": len(layer2)},
+		},
+	})
+	md := reg.add(imageManifest)
+	index, _ := json.Marshal(map[string]interface{}{
+		"mediaType": mediaTypeOCIIndex,
+		"manifests": []map[string]interface{}{
+			{"digest": "sha256:" + strings.Repeat("0", 64), "platform": map[string]string{"os": "linux", "architecture": "arm64"}},
+			{"digest": md, "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+		},
+	})
+	indexDigest := reg.add(index)
+	reg.tags["1.10"] = indexDigest
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	ref, err := ParseReference(host + "/rhdh/index:1.10")
+	assert.NoError(t, err)
+
+	// anonymous access is refused
+	c, err := NewClient(srv.Client(), nil)
+	assert.NoError(t, err)
+	_, err = c.Resolve(context.TODO(), ref)
+	assert.ErrorContains(t, err, "failed to get token")
+
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	c, err = NewClient(srv.Client(), []byte(`{"auths":{"https://`+host+`":{"auth":"`+auth+`"}}}`))
+	assert.NoError(t, err)
+
+	digest, err := c.Resolve(context.TODO(), ref)
+	assert.NoError(t, err)
+	assert.Equal(t, indexDigest, digest)
+
+	content, err := c.ReadFile(context.TODO(), ref.Pinned(digest), "dynamic-plugins.default.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "plugins: []", string(content))
+
+	content, err = c.ReadFile(context.TODO(), ref, "/catalog-entities/a.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+
+	_, err = c.ReadFile(context.TODO(), ref, "missing.yaml")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// the digest of the streamed layer is verified, even once the file is found
+	tampered := layer(t, map[string]string{"dynamic-plugins.default.yaml": "tampered", "other.yaml": "other"}, true)
+	td := "sha256:" + strings.Repeat("2", 64)
+	reg.blobs[td] = tampered
+	tamperedManifest, _ := json.Marshal(map[string]interface{}{
+		"mediaType": mediaTypeOCIManifest,
+		"layers":    []map[string]interface{}{{"digest": td, "size": len(tampered)}},
+	})
+	reg.tags["tampered"] = reg.add(tamperedManifest)
+	tamperedRef, err := ParseReference(host + "/rhdh/index:tampered")
+	assert.NoError(t, err)
+	_, err = c.ReadFile(context.TODO(), tamperedRef, "dynamic-plugins.default.yaml")
+	assert.ErrorContains(t, err, "does not match digest")
+
+	_, err = c.Resolve(context.TODO(), ref.Pinned("sha256:"+strings.Repeat("1", 64)))
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a:pull"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:a:pull",
+	}, params)
+}
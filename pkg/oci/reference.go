@@ -0,0 +1,92 @@
+package oci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	dockerHub         = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// Reference is a parsed OCI image reference, e.g. quay.io/rhdh/plugin-catalog-index:1.10
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses the image reference. The docker:// and oci:// prefixes are accepted,
+// images without registry are Docker Hub images and images without tag or digest use the latest tag.
+func ParseReference(image string) (Reference, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(image, "docker://"), "oci://")
+	var ref Reference
+
+	if i := strings.Index(s, "@"); i >= 0 {
+		s, ref.Digest = s[:i], s[i+1:]
+		if !digestRegexp.MatchString(ref.Digest) {
+			return Reference{}, fmt.Errorf("invalid image reference %q: unsupported digest %q", image, ref.Digest)
+		}
+	}
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		s, ref.Tag = s[:i], s[i+1:]
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry, ref.Repository = parts[0], parts[1]
+	} else {
+		ref.Registry, ref.Repository = dockerHub, s
+		if len(parts) == 1 {
+			ref.Repository = "library/" + s
+		}
+	}
+
+	if ref.Repository == "" || strings.HasSuffix(ref.Repository, "/") || ref.Repository != strings.ToLower(ref.Repository) {
+		return Reference{}, fmt.Errorf("invalid image reference %q", image)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Name returns the image name without tag and digest
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the reference, with the digest only if it is set
+func (r Reference) String() string {
+	if r.Digest != "" {
+		return r.Name() + "@" + r.Digest
+	}
+	return r.Name() + ":" + r.Tag
+}
+
+// Pinned returns the reference pinned to the digest
+func (r Reference) Pinned(digest string) Reference {
+	r.Tag, r.Digest = "", digest
+	return r
+}
+
+// host returns the host the registry API is served on
+func (r Reference) host() string {
+	if r.Registry == dockerHub {
+		return dockerHubRegistry
+	}
+	return r.Registry
+}
+
+// manifestRef returns the digest, or the tag if the reference is not pinned
+func (r Reference) manifestRef() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
@@ -17,6 +17,17 @@
 # Where integrity is optional (sha512-..., sha384-..., or sha256-...)
 # For OCI URLs, integrity is ignored (digest in URL provides verification)
 #
+# Plugin cache:
+#   Every installed plugin is recorded in OUTPUT_DIR/.plugin-refs/<plugin_name> with its input line.
+#   If OUTPUT_DIR is persistent (e.g. a PersistentVolumeClaim), a plugin pinned by OCI digest
+#   or integrity is skipped when the same reference is already installed.
+#   Unpinned plugins are always re-installed.
+#   Installed plugins no longer listed in input_file are removed (set PRUNE_PLUGINS=false to keep them).
+#   Plugins are installed to a staging directory of OUTPUT_DIR and moved in place when complete, so the
+#   Backstage containers sharing OUTPUT_DIR never load a partially installed plugin. Replacing an installed
+#   plugin takes two renames, during which its directory is briefly missing. The installers sharing
+#   OUTPUT_DIR are serialized by a lock file recording the Pod (host) holding it, refreshed for every plugin.
+#
 # Catalog Index (Extensions UI):
 #   Set CATALOG_INDEX_IMAGE to extract catalog-entities from an OCI image.
 #   Entities are copied to CATALOG_ENTITIES_EXTRACT_DIR/catalog-entities.
@@ -34,6 +45,16 @@ INPUT_FILE="${1:-${INPUT_FILE:-/input/packages.txt}}"
 OUTPUT_DIR="${2:-${OUTPUT_DIR:-/dynamic-plugins-root}}"
 PARALLEL_JOBS="${3:-${PARALLEL_JOBS:-4}}"
 LOCK_FILE="${OUTPUT_DIR}/install-dynamic-plugins.lock"
+# Age after which a lock held by another Pod is considered stale (its process cannot be checked)
+LOCK_STALE_SECONDS="${LOCK_STALE_SECONDS:-900}"
+LOCK_OWNER="${HOSTNAME:-localhost} $$"
+# Staging directories of this installer, the ones of the other installers sharing OUTPUT_DIR are never touched
+STAGING_PREFIX="${OUTPUT_DIR}/.install-${HOSTNAME:-localhost}_"
+STAGING_DIR="${STAGING_PREFIX}$$"
+
+# Directory recording the references of installed plugins (plugin cache)
+PLUGIN_REFS_DIR="${PLUGIN_REFS_DIR:-${OUTPUT_DIR}/.plugin-refs}"
+PRUNE_PLUGINS="${PRUNE_PLUGINS:-true}"
 
 # Termination message file for Kubernetes to read on container exit
 TERMINATION_LOG="${TERMINATION_LOG:-/dev/termination-log}"
@@ -90,35 +111,50 @@ create_lock() {
 
     while true; do
         # Try to create lock file exclusively (fails if exists)
-        if (set -o noclobber; echo $$ > "${LOCK_FILE}") 2>/dev/null; then
+        if (set -o noclobber; echo "${LOCK_OWNER}" > "${LOCK_FILE}") 2>/dev/null; then
             echo "======= Created lock file: ${LOCK_FILE}"
             return 0
         fi
 
-        # Lock exists - check if holding process is still alive
-        local lock_pid
-        lock_pid=$(cat "${LOCK_FILE}" 2>/dev/null || echo "")
-        if [[ -n "${lock_pid}" ]] && ! kill -0 "${lock_pid}" 2>/dev/null; then
+        # Lock exists - check if holding process is still alive.
+        # The process of another Pod sharing the output directory cannot be checked, its lock expires instead.
+        local lock_host lock_pid lock_age
+        read -r lock_host lock_pid < "${LOCK_FILE}" 2>/dev/null || true
+        if [[ "${lock_host}" == "${HOSTNAME:-localhost}" && -n "${lock_pid}" ]] && ! kill -0 "${lock_pid}" 2>/dev/null; then
             # Stale lock - process no longer exists
             echo "======= Removing stale lock (PID ${lock_pid} not found)"
             rm -f "${LOCK_FILE}"
             continue
         fi
+        lock_age=$(( $(date +%s) - $(stat -c %Y "${LOCK_FILE}" 2>/dev/null || date +%s) ))
+        if [[ "${lock_host}" != "${HOSTNAME:-localhost}" && ${lock_age} -ge ${LOCK_STALE_SECONDS} ]]; then
+            echo "======= Removing stale lock (held by ${lock_host:-unknown}, not refreshed for ${lock_age}s)"
+            rm -f "${LOCK_FILE}"
+            continue
+        fi
 
-        echo "======= Waiting for lock release (held by PID ${lock_pid:-unknown})..."
+        echo "======= Waiting for lock release (held by ${lock_host:-unknown} PID ${lock_pid:-unknown})..."
         sleep 1
     done
 }
 
 remove_lock() {
-    if [[ -f "${LOCK_FILE}" ]]; then
+    # Remove the lock only if still held by this process
+    if [[ -f "${LOCK_FILE}" && "$(cat "${LOCK_FILE}" 2>/dev/null)" == "${LOCK_OWNER}" ]]; then
         rm -f "${LOCK_FILE}"
         echo "======= Removed lock file: ${LOCK_FILE}"
     fi
 }
 
-# Ensure lock is removed on exit (normal, error, or signal)
-trap 'remove_lock' EXIT
+refresh_lock() {
+    # Keep the lock held by this process from expiring for the other Pods
+    if [[ "$(cat "${LOCK_FILE}" 2>/dev/null)" == "${LOCK_OWNER}" ]]; then
+        touch "${LOCK_FILE}"
+    fi
+}
+
+# Ensure lock and staging directories are removed on exit (normal, error, or signal)
+trap 'rm -rf "${STAGING_DIR}"; remove_lock' EXIT
 
 # ============================================================================
 # Tool Detection
@@ -350,6 +386,11 @@ parse_npmrc() {
             NPM_AUTH_TOKEN="${token}"
         fi
     fi
+
+    # NPM_CONFIG_REGISTRY (e.g. the registry mirror set by the Operator) overrides the .npmrc registry, as with npm
+    if [[ -n "${NPM_CONFIG_REGISTRY:-}" ]]; then
+        NPM_REGISTRY="${NPM_CONFIG_REGISTRY%/}"
+    fi
 }
 
 # URL-encode a string (for scoped package names)
@@ -623,6 +664,67 @@ extract_catalog_entities() {
     rm -rf "${EXTRACT_DIR}"
 }
 
+# ============================================================================
+# Plugin cache - installed plugin references
+# ============================================================================
+
+# Extract plugin name (installation directory) from URL
+plugin_name_from_url() {
+    local url="$1"
+    # Scoped npm package: @scope/name[@version] -> scope-name, as named by npm pack
+    if [[ "${url}" =~ ^@([^/@]+)/([^/@]+) ]]; then
+        echo "${BASH_REMATCH[1]}-${BASH_REMATCH[2]}"
+        return
+    fi
+    echo "${url}" | sed 's|oci://||' | sed 's|https\?://||' | sed 's|file://||' | sed 's|file:||' | sed 's|@sha256:.*||' | sed 's|@.*||' | awk -F'/' '{print $NF}'
+}
+
+# A reference is pinned if it has an OCI digest or an integrity,
+# so the same reference always resolves to the same content
+is_pinned_ref() {
+    local url="$1"
+    local integrity="$2"
+    [[ "${url}" == *@sha256:* || -n "${integrity}" ]]
+}
+
+# Check if the plugin is already installed from the same pinned reference
+is_cached() {
+    local plugin_name="$1"
+    local plugin_dir="$2"
+    local ref="$3"
+    local ref_file="${PLUGIN_REFS_DIR}/${plugin_name}"
+
+    [[ -f "${ref_file}" && "$(cat "${ref_file}")" == "${ref}" ]] && \
+        [[ -d "${plugin_dir}" && -n "$(ls -A "${plugin_dir}" 2>/dev/null)" ]]
+}
+
+# Remove installed plugins which are no longer listed in the input file.
+# Only directories recorded in PLUGIN_REFS_DIR are removed, anything else in the output directory is kept.
+prune_plugins() {
+    local input_file="$1"
+    local output_dir="$2"
+
+    if [[ ! -d "${PLUGIN_REFS_DIR}" ]]; then
+        return 0
+    fi
+
+    local expected
+    expected=$(grep -v '^#' "${input_file}" | grep -v '^$' | awk '{print $1}' | while read -r url; do
+        plugin_name_from_url "${url}"
+    done)
+
+    local ref_file plugin_name
+    for ref_file in "${PLUGIN_REFS_DIR}"/*; do
+        [[ -f "${ref_file}" ]] || continue
+        plugin_name=$(basename "${ref_file}")
+        if ! grep -qxF "${plugin_name}" <<< "${expected}"; then
+            echo "[PRUNE] ${plugin_name}"
+            rm -rf "${output_dir:?}/${plugin_name}"
+            rm -f "${ref_file}"
+        fi
+    done
+}
+
 # ============================================================================
 # Main download router
 # ============================================================================
@@ -635,17 +737,28 @@ download_plugin() {
     url=$(echo "${input_line}" | awk '{print $1}')
     integrity=$(echo "${input_line}" | awk '{print $2}')
 
-    # Extract plugin name from URL
     local plugin_name
-    plugin_name=$(echo "${url}" | sed 's|oci://||' | sed 's|https\?://||' | sed 's|file://||' | sed 's|file:||' | sed 's|@sha256:.*||' | sed 's|@.*||' | awk -F'/' '{print $NF}')
+    plugin_name=$(plugin_name_from_url "${url}")
 
     local plugin_dir="${output_dir}/${plugin_name}"
+    local ref="${url} ${integrity}"
+    ref="${ref% }"
 
-    if [[ -d "${plugin_dir}" && -n "$(ls -A "${plugin_dir}" 2>/dev/null)" ]]; then
-        echo "[SKIP] ${plugin_name} (exists)"
+    if is_pinned_ref "${url}" "${integrity}" && is_cached "${plugin_name}" "${plugin_dir}" "${ref}"; then
+        echo "[SKIP] ${plugin_name} (cached)"
         return 0
     fi
 
+    refresh_lock
+
+    # Not installed, unpinned or installed from another reference: (re)install.
+    # The plugin is installed to a staging directory of the output directory (same filesystem)
+    # and moved in place when complete, so the previous installation stays usable until then.
+    mkdir -p "${STAGING_DIR}"
+    local staging_dir
+    staging_dir=$(mktemp -d "${STAGING_DIR}/${plugin_name}.XXXXXX")
+    local install_dir="${staging_dir}/${plugin_name}"
+
     echo "[DOWN] ${url}" # ${plugin_name}"
 
     # Route based on URL prefix
@@ -653,41 +766,51 @@ download_plugin() {
     case "${url}" in
         oci://*)
             # OCI uses digest in URL for verification, integrity ignored
-            download_oci "${url}" "${plugin_name}" "${plugin_dir}" || result=$?
+            download_oci "${url}" "${plugin_name}" "${install_dir}" || result=$?
             ;;
         http://*|https://*)
-            download_http "${url}" "${plugin_name}" "${plugin_dir}" "${integrity}" || result=$?
+            download_http "${url}" "${plugin_name}" "${install_dir}" "${integrity}" || result=$?
             ;;
         @*)
             # Scoped npm package: @scope/package or @scope/package@version
-            download_npm "${url}" "${plugin_name}" "${plugin_dir}" "${integrity}" || result=$?
+            download_npm "${url}" "${plugin_name}" "${install_dir}" "${integrity}" || result=$?
             ;;
         ./*)
-            download_local "${url}" "${plugin_name}" "${plugin_dir}" || result=$?
+            download_local "${url}" "${plugin_name}" "${install_dir}" || result=$?
             ;;
         file:*)
-            download_file "${url}" "${plugin_name}" "${plugin_dir}" || result=$?
+            download_file "${url}" "${plugin_name}" "${install_dir}" || result=$?
             ;;
         *@*)
             # Unscoped npm package with version: package@version
-            download_npm "${url}" "${plugin_name}" "${plugin_dir}" "${integrity}" || result=$?
+            download_npm "${url}" "${plugin_name}" "${install_dir}" "${integrity}" || result=$?
             ;;
         *)
             # Assume unscoped npm package without version, or fail
             if [[ "${url}" =~ ^[a-zA-Z0-9._-]+$ ]]; then
-                download_npm "${url}" "${plugin_name}" "${plugin_dir}" "${integrity}" || result=$?
+                download_npm "${url}" "${plugin_name}" "${install_dir}" "${integrity}" || result=$?
             else
                 echo "[FAIL] ${plugin_name}: unknown URL format: ${url}" >&2
+                rm -rf "${staging_dir}"
                 return 1
             fi
             ;;
     esac
 
     if [[ ${result} -eq 0 ]]; then
+        # Move the new installation in place, the previous one is removed with the staging directory.
+        # The plugin directory is missing between the two renames.
+        if [[ -e "${plugin_dir}" ]]; then
+            mv "${plugin_dir}" "${staging_dir}/previous"
+        fi
+        mv "${install_dir}" "${plugin_dir}"
+        mkdir -p "${PLUGIN_REFS_DIR}"
+        echo "${ref}" > "${PLUGIN_REFS_DIR}/${plugin_name}"
         echo "[DONE] ${plugin_name}"
     else
         record_failure "${plugin_name}" "download failed from ${url}"
     fi
+    rm -rf "${staging_dir}"
     return ${result}
 }
 
@@ -713,6 +836,9 @@ detect_oci_tool
 # Create output directory (may already exist from create_lock)
 mkdir -p "${OUTPUT_DIR}"
 
+# Remove the staging directories left by an interrupted installation of this Pod
+rm -rf "${STAGING_PREFIX}"*
+
 # Extract catalog entities from catalog index image (for Extensions UI)
 if [[ -n "${CATALOG_INDEX_IMAGE}" ]]; then
     extract_catalog_entities "${CATALOG_INDEX_IMAGE}" "${CATALOG_ENTITIES_EXTRACT_DIR}"
@@ -720,8 +846,8 @@ else
     echo "=== CATALOG_INDEX_IMAGE not set, skipping catalog entities extraction"
 fi
 
-export -f download_plugin extract_oci_image validate_plugin_artifact download_oci download_http download_npm download_local download_file detect_oci_tool parse_npmrc url_encode verify_integrity record_failure
-export OUTPUT_DIR OCI_TOOL NPM_REGISTRY NPM_AUTH_TOKEN FAILURE_LOG
+export -f download_plugin refresh_lock plugin_name_from_url is_pinned_ref is_cached extract_oci_image validate_plugin_artifact download_oci download_http download_npm download_local download_file detect_oci_tool parse_npmrc url_encode verify_integrity record_failure
+export OUTPUT_DIR OCI_TOOL NPM_REGISTRY NPM_AUTH_TOKEN FAILURE_LOG PLUGIN_REFS_DIR LOCK_FILE LOCK_OWNER STAGING_DIR
 
 total=$(grep -cv '^#\|^$' "${INPUT_FILE}")
 
@@ -749,8 +875,12 @@ if [[ -f "${FAILURE_LOG}" ]]; then
     exit 1
 fi
 
+if [[ "${PRUNE_PLUGINS}" == "true" ]]; then
+    prune_plugins "${INPUT_FILE}" "${OUTPUT_DIR}"
+fi
+
 echo "=== Complete ==="
 echo "Plugins in ${OUTPUT_DIR}:"
-find "${OUTPUT_DIR}" -mindepth 1 -maxdepth 1 -type d -exec basename {} \; 2>/dev/null | head -20
+find "${OUTPUT_DIR}" -mindepth 1 -maxdepth 1 -type d ! -name ".*" -exec basename {} \; 2>/dev/null | head -20
 echo ""
 echo "Elapsed time: ${ELAPSED}s"
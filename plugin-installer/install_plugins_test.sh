@@ -9,6 +9,8 @@
 #   - verify_integrity(): SHA256/384/512 verification
 #   - url_encode(): Scoped package name encoding
 #   - download_npm(): Integration test with real registry (optional)
+#   - plugin cache: skipping pinned plugins already installed and pruning removed ones
+#   - concurrent installations: staged plugin installation and lock of other Pods
 
 set -euo pipefail
 
@@ -49,6 +51,9 @@ source_functions() {
     # Extract url_encode function
     eval "$(awk '/^url_encode\(\)/{found=1} found{print; if(/^}$/){found=0}}' "$script")"
 
+    # Extract plugin_name_from_url function
+    eval "$(awk '/^plugin_name_from_url\(\)/{found=1} found{print; if(/^}$/){found=0}}' "$script")"
+
     # Extract verify_integrity function
     eval "$(awk '/^verify_integrity\(\)/{found=1} found{print; if(/^}$/){found=0}}' "$script")"
 
@@ -223,6 +228,21 @@ EOF
     assert_equals "https://npm.fromfile.com" "${NPM_REGISTRY}" "file registry overrides env"
 }
 
+test_parse_npmrc_config_registry_overrides_file() {
+    local npmrc="${TEST_TMP_DIR}/.npmrc"
+
+    cat > "${npmrc}" << 'EOF'
+registry=https://npm.fromfile.com
+EOF
+
+    export NPM_CONFIG_USERCONFIG="${npmrc}"
+    local NPM_CONFIG_REGISTRY="https://npm.mirror.com/"
+
+    parse_npmrc
+
+    assert_equals "https://npm.mirror.com" "${NPM_REGISTRY}" "NPM_CONFIG_REGISTRY overrides file registry"
+}
+
 # ============================================================================
 # Tests: url_encode()
 # ============================================================================
@@ -248,6 +268,23 @@ test_url_encode_nested_scope() {
     assert_equals "%40org%2fsub%2fpackage" "${result}" "nested path encoded"
 }
 
+# ============================================================================
+# Tests: plugin_name_from_url()
+# ============================================================================
+
+test_plugin_name_scoped_npm() {
+    assert_equals "backstage-plugin-catalog" "$(plugin_name_from_url "@backstage/plugin-catalog@^1.2.0")" "scoped package with version" && \
+    assert_equals "backstage-plugin-catalog" "$(plugin_name_from_url "@backstage/plugin-catalog")" "scoped package without version"
+}
+
+test_plugin_name_unscoped_npm() {
+    assert_equals "is-odd" "$(plugin_name_from_url "is-odd@3.0.1")" "unscoped package with version"
+}
+
+test_plugin_name_oci() {
+    assert_equals "plugin-a" "$(plugin_name_from_url "oci://quay.io/rhdh/plugin-a@sha256:abc!plugin-a")" "oci package"
+}
+
 # ============================================================================
 # Tests: verify_integrity()
 # ============================================================================
@@ -522,6 +559,170 @@ test_download_npm_latest() {
     fi
 }
 
+# ============================================================================
+# Tests: plugin cache
+# ============================================================================
+
+# Runs install_plugins.sh with file: plugins, so no registry access is needed
+run_installer() {
+    INPUT_FILE="${TEST_TMP_DIR}/cache/packages.txt" \
+    OUTPUT_DIR="${TEST_TMP_DIR}/cache/root" \
+    TERMINATION_LOG="${TEST_TMP_DIR}/cache/termination.log" \
+    FAILURE_LOG="${TEST_TMP_DIR}/cache/failures.log" \
+    OCI_TOOL=skopeo \
+    env "$@" bash "${SCRIPT_DIR}/install_plugins.sh" 2>&1
+}
+
+setup_cache_plugins() {
+    rm -rf "${TEST_TMP_DIR}/cache"
+    mkdir -p "${TEST_TMP_DIR}/cache/src/plugin-a" "${TEST_TMP_DIR}/cache/src/plugin-b"
+    echo "a" > "${TEST_TMP_DIR}/cache/src/plugin-a/index.js"
+    echo "b" > "${TEST_TMP_DIR}/cache/src/plugin-b/index.js"
+}
+
+test_cache_skips_pinned_plugin() {
+    setup_cache_plugins
+    local src="${TEST_TMP_DIR}/cache/src"
+    echo "file:${src}/plugin-a sha256-pinned" > "${TEST_TMP_DIR}/cache/packages.txt"
+    echo "file:${src}/plugin-b" >> "${TEST_TMP_DIR}/cache/packages.txt"
+
+    run_installer > /dev/null || return 1
+
+    echo "changed" > "${src}/plugin-a/index.js"
+    echo "changed" > "${src}/plugin-b/index.js"
+
+    local output
+    output=$(run_installer) || { echo "${output}"; return 1; }
+
+    assert_equals "1" "$(grep -c '\[SKIP\] plugin-a (cached)' <<< "${output}")" "pinned plugin skipped" && \
+    assert_equals "a" "$(cat "${TEST_TMP_DIR}/cache/root/plugin-a/index.js")" "pinned plugin kept" && \
+    assert_equals "changed" "$(cat "${TEST_TMP_DIR}/cache/root/plugin-b/index.js")" "unpinned plugin re-installed"
+}
+
+test_cache_reinstalls_changed_ref() {
+    setup_cache_plugins
+    local src="${TEST_TMP_DIR}/cache/src"
+    echo "file:${src}/plugin-a sha256-first" > "${TEST_TMP_DIR}/cache/packages.txt"
+    run_installer > /dev/null || return 1
+
+    echo "changed" > "${src}/plugin-a/index.js"
+    echo "file:${src}/plugin-a sha256-second" > "${TEST_TMP_DIR}/cache/packages.txt"
+    run_installer > /dev/null || return 1
+
+    assert_equals "changed" "$(cat "${TEST_TMP_DIR}/cache/root/plugin-a/index.js")" "plugin re-installed" && \
+    assert_equals "file:${src}/plugin-a sha256-second" "$(cat "${TEST_TMP_DIR}/cache/root/.plugin-refs/plugin-a")" "reference updated"
+}
+
+test_cache_prunes_removed_plugin() {
+    setup_cache_plugins
+    local src="${TEST_TMP_DIR}/cache/src"
+    printf "file:%s/plugin-a\nfile:%s/plugin-b\n" "${src}" "${src}" > "${TEST_TMP_DIR}/cache/packages.txt"
+    run_installer > /dev/null || return 1
+    mkdir -p "${TEST_TMP_DIR}/cache/root/not-a-plugin"
+
+    echo "file:${src}/plugin-a" > "${TEST_TMP_DIR}/cache/packages.txt"
+    local output
+    output=$(run_installer) || { echo "${output}"; return 1; }
+
+    if [[ -d "${TEST_TMP_DIR}/cache/root/plugin-b" || -f "${TEST_TMP_DIR}/cache/root/.plugin-refs/plugin-b" ]]; then
+        echo "plugin-b should be pruned"
+        return 1
+    fi
+    if [[ ! -d "${TEST_TMP_DIR}/cache/root/not-a-plugin" ]]; then
+        echo "directory not installed by the script should be kept"
+        return 1
+    fi
+    assert_file_exists "${TEST_TMP_DIR}/cache/root/plugin-a/index.js" "plugin-a kept"
+}
+
+test_cache_prune_disabled() {
+    setup_cache_plugins
+    local src="${TEST_TMP_DIR}/cache/src"
+    printf "file:%s/plugin-a\nfile:%s/plugin-b\n" "${src}" "${src}" > "${TEST_TMP_DIR}/cache/packages.txt"
+    run_installer > /dev/null || return 1
+
+    echo "file:${src}/plugin-a" > "${TEST_TMP_DIR}/cache/packages.txt"
+    run_installer PRUNE_PLUGINS=false > /dev/null || return 1
+
+    assert_file_exists "${TEST_TMP_DIR}/cache/root/plugin-b/index.js" "plugin-b kept"
+}
+
+test_cache_failed_reinstall_keeps_plugin() {
+    setup_cache_plugins
+    local src="${TEST_TMP_DIR}/cache/src"
+    echo "file:${src}/plugin-a" > "${TEST_TMP_DIR}/cache/packages.txt"
+    run_installer > /dev/null || return 1
+
+    rm -rf "${src}/plugin-a"
+    if run_installer > /dev/null; then
+        echo "re-installing a missing plugin should fail"
+        return 1
+    fi
+
+    if compgen -G "${TEST_TMP_DIR}/cache/root/.install-*" > /dev/null; then
+        echo "staging directories should be removed"
+        return 1
+    fi
+    assert_equals "a" "$(cat "${TEST_TMP_DIR}/cache/root/plugin-a/index.js")" "previous installation kept"
+}
+
+test_lock_of_other_pod_expires() {
+    setup_cache_plugins
+    local src="${TEST_TMP_DIR}/cache/src"
+    echo "file:${src}/plugin-a" > "${TEST_TMP_DIR}/cache/packages.txt"
+    mkdir -p "${TEST_TMP_DIR}/cache/root"
+    echo "other-pod 1" > "${TEST_TMP_DIR}/cache/root/install-dynamic-plugins.lock"
+    touch -d "-1 hour" "${TEST_TMP_DIR}/cache/root/install-dynamic-plugins.lock"
+
+    local output
+    output=$(run_installer) || { echo "${output}"; return 1; }
+
+    assert_equals "1" "$(grep -c 'Removing stale lock (held by other-pod' <<< "${output}")" "stale lock of other Pod removed" && \
+    assert_file_exists "${TEST_TMP_DIR}/cache/root/plugin-a/index.js" "plugin-a installed"
+}
+
+test_staging_of_other_pod_kept() {
+    setup_cache_plugins
+    local src="${TEST_TMP_DIR}/cache/src"
+    echo "file:${src}/plugin-a" > "${TEST_TMP_DIR}/cache/packages.txt"
+    mkdir -p "${TEST_TMP_DIR}/cache/root/.install-other-pod_1/plugin-a.abc" \
+        "${TEST_TMP_DIR}/cache/root/.install-this-pod_1/plugin-a.abc"
+
+    local output
+    output=$(run_installer HOSTNAME=this-pod) || { echo "${output}"; return 1; }
+
+    if [[ -d "${TEST_TMP_DIR}/cache/root/.install-this-pod_1" ]]; then
+        echo "staging directory left by this Pod should be removed"
+        return 1
+    fi
+    if [[ ! -d "${TEST_TMP_DIR}/cache/root/.install-other-pod_1/plugin-a.abc" ]]; then
+        echo "staging directory of other Pod should be kept"
+        return 1
+    fi
+    assert_file_exists "${TEST_TMP_DIR}/cache/root/plugin-a/index.js" "plugin-a installed"
+}
+
+test_refresh_lock() {
+    eval "$(awk '/^refresh_lock\(\)/{found=1} found{print; if(/^}$/){found=0}}' "${SCRIPT_DIR}/install_plugins.sh")"
+    local LOCK_FILE="${TEST_TMP_DIR}/refresh.lock" LOCK_OWNER="this-pod 1"
+
+    echo "other-pod 1" > "${LOCK_FILE}"
+    touch -d "-1 hour" "${LOCK_FILE}"
+    refresh_lock
+    if [[ $(( $(date +%s) - $(stat -c %Y "${LOCK_FILE}") )) -lt 3600 ]]; then
+        echo "lock of other Pod should not be refreshed"
+        return 1
+    fi
+
+    echo "${LOCK_OWNER}" > "${LOCK_FILE}"
+    touch -d "-1 hour" "${LOCK_FILE}"
+    refresh_lock
+    if [[ $(( $(date +%s) - $(stat -c %Y "${LOCK_FILE}") )) -ge 60 ]]; then
+        echo "lock held by this process should be refreshed"
+        return 1
+    fi
+}
+
 # ============================================================================
 # Main
 # ============================================================================
@@ -545,6 +746,7 @@ main() {
     run_test "registry and auth" test_parse_npmrc_registry_and_auth
     run_test "quoted values" test_parse_npmrc_quoted_values
     run_test "file overrides env" test_parse_npmrc_file_overrides_env
+    run_test "NPM_CONFIG_REGISTRY overrides file" test_parse_npmrc_config_registry_overrides_file
     echo ""
 
     # url_encode tests
@@ -554,6 +756,13 @@ main() {
     run_test "nested scope" test_url_encode_nested_scope
     echo ""
 
+    # plugin_name_from_url tests
+    echo "--- plugin_name_from_url() tests ---"
+    run_test "scoped npm package" test_plugin_name_scoped_npm
+    run_test "unscoped npm package" test_plugin_name_unscoped_npm
+    run_test "oci package" test_plugin_name_oci
+    echo ""
+
     # verify_integrity tests
     echo "--- verify_integrity() tests ---"
     run_test "sha256 valid" test_verify_integrity_sha256_valid
@@ -578,6 +787,18 @@ main() {
     run_test "rejects non-plugin image (alpine)" test_validate_rejects_non_plugin_image
     echo ""
 
+    # Plugin cache tests
+    echo "--- Plugin cache tests ---"
+    run_test "skips cached pinned plugin" test_cache_skips_pinned_plugin
+    run_test "re-installs changed reference" test_cache_reinstalls_changed_ref
+    run_test "prunes removed plugin" test_cache_prunes_removed_plugin
+    run_test "prune disabled" test_cache_prune_disabled
+    run_test "failed re-install keeps plugin" test_cache_failed_reinstall_keeps_plugin
+    run_test "lock of other Pod expires" test_lock_of_other_pod_expires
+    run_test "staging directory of other Pod kept" test_staging_of_other_pod_kept
+    run_test "lock refreshed" test_refresh_lock
+    echo ""
+
     # Integration tests
     echo "--- Integration tests ---"
     run_test "npm with explicit version" test_download_npm_with_version